@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/mikahozz/gohome/integrations/shelly"
+	"github.com/mikahozz/gohome/integrations/spot"
+	"github.com/rs/zerolog/log"
+)
+
+// costSlot is the length of the consumption slots priced separately. It
+// matches 15 minute spot prices; hourly prices cover four slots.
+const costSlot = 15 * time.Minute
+
+type deviceCosts struct {
+	Device  string             `json:"device"`
+	Periods []spot.CostSummary `json:"periods"`
+}
+
+type costsResponse struct {
+	Start   string        `json:"start"`
+	End     string        `json:"end"`
+	Group   string        `json:"group"`
+	Tariff  spot.Tariff   `json:"tariff"`
+	Devices []deviceCosts `json:"devices"`
+}
+
+// getElectricityCosts prices the energy used by metered Shelly devices with
+// the spot prices and tariff. Params: start and end (YYYY-MM-DD, inclusive,
+// default yesterday), group (hour, day or month, default day) and device
+// (default all devices).
+func getElectricityCosts(registry *shelly.Registry, power *shelly.PowerRepository, prices *spot.PriceRepository, tariff spot.Tariff) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if registry == nil || power == nil || prices == nil {
+			http.Error(w, "Electricity costs need Shelly devices and a database", http.StatusServiceUnavailable)
+			return
+		}
+		q := r.URL.Query()
+		y, m, d := time.Now().In(apiZone).Date()
+		yesterday := time.Date(y, m, d-1, 0, 0, 0, 0, apiZone)
+		start, end := yesterday, yesterday
+		var err error
+		if s := q.Get("start"); s != "" {
+			if start, err = time.ParseInLocation("2006-01-02", s, apiZone); err != nil {
+				http.Error(w, "Invalid start date format. Use YYYY-MM-DD.", http.StatusBadRequest)
+				return
+			}
+			end = start
+		}
+		if s := q.Get("end"); s != "" {
+			if end, err = time.ParseInLocation("2006-01-02", s, apiZone); err != nil {
+				http.Error(w, "Invalid end date format. Use YYYY-MM-DD.", http.StatusBadRequest)
+				return
+			}
+		}
+		if end.Before(start) {
+			http.Error(w, "end must not be before start", http.StatusBadRequest)
+			return
+		}
+		group := spot.CostPeriodDay
+		if g := q.Get("group"); g != "" {
+			group = spot.CostPeriod(g)
+		}
+		if err := group.Validate(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		devices := []string{q.Get("device")}
+		if devices[0] == "" {
+			devices = devices[:0]
+			for _, dev := range registry.Devices() {
+				devices = append(devices, dev.Name)
+			}
+		} else if !registry.Has(devices[0]) {
+			http.Error(w, fmt.Sprintf("unknown device %q", devices[0]), http.StatusNotFound)
+			return
+		}
+
+		periodStart := start
+		periodEnd := time.Date(end.Year(), end.Month(), end.Day()+1, 0, 0, 0, 0, apiZone)
+		priceList, err := prices.GetPrices(r.Context(), periodStart, periodEnd, apiZone)
+		if err != nil {
+			log.Error().Err(err).Msg("Error getting spot prices for costs")
+			http.Error(w, "Error occurred fetching spot prices", http.StatusInternalServerError)
+			return
+		}
+
+		result := costsResponse{
+			Start:   start.Format("2006-01-02"),
+			End:     end.Format("2006-01-02"),
+			Group:   string(group),
+			Tariff:  tariff,
+			Devices: []deviceCosts{},
+		}
+		for _, device := range devices {
+			// One slot earlier so the first slot's energy has a starting reading
+			readings, err := power.Readings(r.Context(), device, periodStart.Add(-costSlot), periodEnd)
+			if err != nil {
+				log.Error().Err(err).Str("device", device).Msg("Error reading power readings for costs")
+				http.Error(w, "Error occurred reading power readings", http.StatusInternalServerError)
+				return
+			}
+			var consumption []spot.Consumption
+			for _, use := range shelly.EnergyUseBy(readings, costSlot) {
+				if !use.Start.Before(periodStart) && use.Start.Before(periodEnd) {
+					consumption = append(consumption, spot.Consumption{Start: use.Start, Wh: use.Wh})
+				}
+			}
+			if len(consumption) == 0 {
+				continue
+			}
+			costs, unpriced := tariff.Costs(consumption, priceList.Prices)
+			summaries, err := spot.SummarizeCosts(costs, unpriced, group, apiZone)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			result.Devices = append(result.Devices, deviceCosts{Device: device, Periods: summaries})
+		}
+		writeJSON(w, result)
+	}
+}
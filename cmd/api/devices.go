@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/mikahozz/gohome/integrations/shelly"
+	"github.com/rs/zerolog/log"
+)
+
+// apiZone is the timezone daily energy is summed in.
+var apiZone, _ = time.LoadLocation("Europe/Helsinki")
+
+type deviceStatus struct {
+	shelly.Device
+	Output *bool  `json:"output,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// registerDeviceHandlers exposes the Shelly device registry:
+//
+//	GET  /api/devices              - configured devices
+//	GET  /api/devices/{name}       - device with its current switch state
+//	POST /api/devices/{name}/on    - switch on (.../off to switch off)
+//	GET  /api/devices/{name}/power - stored power readings and daily kWh
+//
+// power may be nil when the database is not available.
+func registerDeviceHandlers(mux *http.ServeMux, registry *shelly.Registry, power *shelly.PowerRepository) {
+	mux.HandleFunc("GET /api/devices", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, registry.Devices())
+	})
+	mux.HandleFunc("GET /api/devices/{name}", func(w http.ResponseWriter, r *http.Request) {
+		name := r.PathValue("name")
+		c, err := registry.Client(name)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		var device shelly.Device
+		for _, d := range registry.Devices() {
+			if d.Name == name {
+				device = d
+			}
+		}
+		ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+		defer cancel()
+		st := deviceStatus{Device: device}
+		status, err := c.GetStatus(ctx)
+		if err != nil {
+			log.Error().Err(err).Str("device", name).Msg("Error getting Shelly status")
+			st.Error = err.Error()
+		} else {
+			st.Output = &status.Output
+		}
+		writeJSON(w, st)
+	})
+	mux.HandleFunc("POST /api/devices/{name}/on", setDevice(registry, true))
+	mux.HandleFunc("POST /api/devices/{name}/off", setDevice(registry, false))
+	mux.HandleFunc("GET /api/devices/{name}/power", getDevicePower(registry, power))
+}
+
+type devicePower struct {
+	Device   string                `json:"device"`
+	Latest   *shelly.PowerReading  `json:"latest"`
+	Readings []shelly.PowerReading `json:"readings"`
+	Daily    []shelly.DailyEnergy  `json:"daily"`
+}
+
+// getDevicePower returns the readings of the last hours (default 24) and the
+// energy used per day for the last days (default 7, including today).
+func getDevicePower(registry *shelly.Registry, power *shelly.PowerRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := r.PathValue("name")
+		if !registry.Has(name) {
+			http.Error(w, fmt.Sprintf("unknown device %q", name), http.StatusNotFound)
+			return
+		}
+		if power == nil {
+			http.Error(w, "Power readings are not available without a database", http.StatusServiceUnavailable)
+			return
+		}
+		hours, err := positiveIntParam(r, "hours", 24)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		days, err := positiveIntParam(r, "days", 7)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		now := time.Now().In(apiZone)
+		recentStart := now.Add(-time.Duration(hours) * time.Hour)
+		y, m, d := now.Date()
+		dailyStart := time.Date(y, m, d-days+1, 0, 0, 0, 0, apiZone)
+		// Read one hour before the first day so its first reading has a predecessor
+		start := dailyStart.Add(-time.Hour)
+		if recentStart.Before(start) {
+			start = recentStart
+		}
+		readings, err := power.Readings(r.Context(), name, start, now)
+		if err != nil {
+			log.Error().Err(err).Str("device", name).Msg("Error reading power readings")
+			http.Error(w, "Error occurred reading power readings", http.StatusInternalServerError)
+			return
+		}
+
+		result := devicePower{Device: name, Readings: []shelly.PowerReading{}, Daily: []shelly.DailyEnergy{}}
+		for _, reading := range readings {
+			if !reading.Time.Before(recentStart) {
+				result.Readings = append(result.Readings, reading)
+			}
+		}
+		if len(readings) > 0 {
+			result.Latest = &readings[len(readings)-1]
+		}
+		for _, day := range shelly.DailyEnergyUse(readings, apiZone) {
+			if day.Date >= dailyStart.Format("2006-01-02") {
+				result.Daily = append(result.Daily, day)
+			}
+		}
+		writeJSON(w, result)
+	}
+}
+
+func positiveIntParam(r *http.Request, name string, def int) (int, error) {
+	s := r.URL.Query().Get(name)
+	if s == "" {
+		return def, nil
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil || v <= 0 {
+		return 0, fmt.Errorf("invalid %s, use a positive integer", name)
+	}
+	return v, nil
+}
+
+func setDevice(registry *shelly.Registry, on bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 15*time.Second)
+		defer cancel()
+		if err := registry.Set(ctx, r.PathValue("name"), on); err != nil {
+			if errors.Is(err, shelly.ErrUnknownDevice) {
+				http.Error(w, err.Error(), http.StatusNotFound)
+				return
+			}
+			http.Error(w, "Error occurred switching Shelly device", http.StatusBadGateway)
+			return
+		}
+		writeJSON(w, map[string]bool{"output": on})
+	}
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	json, err := json.Marshal(v)
+	if err != nil {
+		log.Error().Err(err).Msg("Error marshalling response to JSON")
+		http.Error(w, "Error occurred in JSON conversion", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(json)
+}
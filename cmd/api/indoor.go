@@ -0,0 +1,109 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/mikahozz/gohome/integrations/indoor"
+	"github.com/rs/zerolog/log"
+)
+
+// indoorResponse has the latest values at the top level in the format the
+// dashboard reads ({"temperature": 22.5, "humidity": 27.4, ...}) and the
+// recent readings next to them.
+type indoorResponse struct {
+	Sensor string `json:"sensor"`
+	indoor.Reading
+	Readings []indoor.Reading `json:"readings"`
+}
+
+// registerIndoorHandlers exposes the indoor sensors:
+//
+//	GET      /api/indoor                 - sensors with stored readings
+//	GET      /api/indoor/{sensor}        - latest values and readings of the last hours
+//	POST     /api/indoor/{sensor}        - store a reading posted as JSON
+//	GET|POST /api/indoor/{sensor}/shelly - Shelly H&T webhook (values in the query)
+func registerIndoorHandlers(mux *http.ServeMux, repo *indoor.Repository) {
+	mux.HandleFunc("GET /api/indoor", func(w http.ResponseWriter, r *http.Request) {
+		sensors, err := repo.Sensors(r.Context())
+		if err != nil {
+			log.Error().Err(err).Msg("Error reading indoor sensors")
+			http.Error(w, "Error occurred reading indoor sensors", http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, sensors)
+	})
+	mux.HandleFunc("GET /api/indoor/{sensor}", getIndoor(repo))
+	mux.HandleFunc("POST /api/indoor/{sensor}", func(w http.ResponseWriter, r *http.Request) {
+		var reading indoor.Reading
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<16)).Decode(&reading); err != nil {
+			http.Error(w, "Invalid JSON reading", http.StatusBadRequest)
+			return
+		}
+		if reading.Time.IsZero() {
+			reading.Time = time.Now()
+		}
+		storeIndoor(w, r, repo, reading, reading.Validate())
+	})
+	shellyWebhook := func(w http.ResponseWriter, r *http.Request) {
+		reading, err := indoor.FromShellyQuery(r.URL.Query(), time.Now())
+		storeIndoor(w, r, repo, reading, err)
+	}
+	mux.HandleFunc("GET /api/indoor/{sensor}/shelly", shellyWebhook)
+	mux.HandleFunc("POST /api/indoor/{sensor}/shelly", shellyWebhook)
+}
+
+func storeIndoor(w http.ResponseWriter, r *http.Request, repo *indoor.Repository, reading indoor.Reading, err error) {
+	sensor := r.PathValue("sensor")
+	if !indoor.ValidSensor(sensor) {
+		http.Error(w, fmt.Sprintf("invalid sensor name %q, use a-z, 0-9, _ and -", sensor), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := repo.Store(r.Context(), sensor, []indoor.Reading{reading}); err != nil {
+		log.Error().Err(err).Str("sensor", sensor).Msg("Error storing indoor reading")
+		http.Error(w, "Error occurred storing indoor reading", http.StatusInternalServerError)
+		return
+	}
+	log.Debug().Str("event", "indoor_reading").Str("sensor", sensor).Time("time", reading.Time).Msg("Stored indoor reading")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// getIndoor returns the latest values of a sensor and its readings of the
+// last hours (default 24).
+func getIndoor(repo *indoor.Repository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		sensor := r.PathValue("sensor")
+		hours, err := positiveIntParam(r, "hours", 24)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		latest, ok, err := repo.Latest(r.Context(), sensor)
+		if err != nil {
+			log.Error().Err(err).Str("sensor", sensor).Msg("Error reading indoor sensor")
+			http.Error(w, "Error occurred reading indoor sensor", http.StatusInternalServerError)
+			return
+		}
+		if !ok {
+			http.Error(w, fmt.Sprintf("no readings for sensor %q", sensor), http.StatusNotFound)
+			return
+		}
+		now := time.Now()
+		readings, err := repo.Readings(r.Context(), sensor, now.Add(-time.Duration(hours)*time.Hour), now)
+		if err != nil {
+			log.Error().Err(err).Str("sensor", sensor).Msg("Error reading indoor readings")
+			http.Error(w, "Error occurred reading indoor sensor", http.StatusInternalServerError)
+			return
+		}
+		if readings == nil {
+			readings = []indoor.Reading{}
+		}
+		writeJSON(w, indoorResponse{Sensor: sensor, Reading: latest, Readings: readings})
+	}
+}
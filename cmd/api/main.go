@@ -1,15 +1,22 @@
 package main
 
 import (
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/mikahozz/gohome/config"
+	"github.com/mikahozz/gohome/db"
 	"github.com/mikahozz/gohome/integrations/cal"
 	"github.com/mikahozz/gohome/integrations/fmi"
+	"github.com/mikahozz/gohome/integrations/indoor"
+	"github.com/mikahozz/gohome/integrations/scenes"
+	"github.com/mikahozz/gohome/integrations/shelly"
 	"github.com/mikahozz/gohome/integrations/spot"
 	"github.com/mikahozz/gohome/integrations/sun"
 	"github.com/mikahozz/gohome/mock"
@@ -23,19 +30,18 @@ const port = ":6001"
 type handlers struct {
 	weatherNow     http.HandlerFunc
 	weatherFore    http.HandlerFunc
-	indoorTemp     http.HandlerFunc
 	spotPrices     http.HandlerFunc
 	calendarEvents http.HandlerFunc
 	sunData        http.HandlerFunc
 }
 
 // Create real data handlers
-func createRealHandlers() handlers {
+func createRealHandlers(prices *spot.PriceRepository) handlers {
+	stations := fmi.NewStationFinder()
 	return handlers{
-		weatherNow:     getWeatherData("101004", fmi.Observations),
-		weatherFore:    getWeatherData("Tapanila,Helsinki", fmi.Forecast),
-		indoorTemp:     jsonResponse(mock.IndoorDevUpstairs),
-		spotPrices:     getSpotPrices(),
+		weatherNow:     getWeatherData(fmi.Location{FMISID: fmi.ConfiguredStation()}, fmi.Observations, stations),
+		weatherFore:    getWeatherData(fmi.Location{Place: fmi.ConfiguredPlace()}, fmi.Forecast, stations),
+		spotPrices:     getSpotPrices(prices),
 		calendarEvents: getCalendarEvents(),
 		sunData:        getSunData(),
 	}
@@ -46,10 +52,9 @@ func createMockHandlers() handlers {
 	return handlers{
 		weatherNow:     jsonResponse(mock.OutdoorWeathernNow),
 		weatherFore:    jsonResponse(mock.OutdoorWeatherFore),
-		indoorTemp:     jsonResponse(mock.IndoorDevUpstairs),
 		spotPrices:     jsonResponse(mock.ElectricityPrices),
 		calendarEvents: jsonResponse(mock.Events),
-		sunData:        getSunData(), // We use hard code Helsinki data for now
+		sunData:        getSunData(), // Calculated, no external data needed
 	}
 }
 
@@ -66,25 +71,89 @@ func jsonResponse(f func() (string, error)) func(http.ResponseWriter, *http.Requ
 	}
 }
 
-func getWeatherData(place string, requestType fmi.RequestType) http.HandlerFunc {
+// getWeatherData serves observations or the forecast for the location in
+// the query: fmisid=<station id>, place=<name> or lat=..&lon=.. (resolved to
+// the nearest weather station). Without them def is used.
+func getWeatherData(def fmi.Location, requestType fmi.RequestType, stations stationFinder) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		weather, err := fmi.GetWeatherData(fmi.StationId(place), requestType)
+		location, err := weatherLocation(r, def, stations)
+		if err != nil {
+			var lookup stationLookupError
+			if errors.As(err, &lookup) {
+				log.Err(err).Msg("")
+				http.Error(w, "Error occurred in finding the nearest weather station", http.StatusBadGateway)
+				return
+			}
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		weather, err := fmi.GetWeatherDataAt(location, requestType)
 		if err != nil {
 			log.Err(err).Msg("")
-			http.Error(w, fmt.Sprintf("Error occurred in fetching weather data for %s", place), http.StatusInternalServerError)
+			http.Error(w, fmt.Sprintf("Error occurred in fetching weather data for %s", location), http.StatusInternalServerError)
 			return
 		}
 		json, err := json.Marshal(weather.WeatherData)
 		if err != nil {
 			log.Err(err).Msg("")
-			http.Error(w, fmt.Sprintf("Error occurred in fetching weather data for %s", place), http.StatusInternalServerError)
+			http.Error(w, fmt.Sprintf("Error occurred in fetching weather data for %s", location), http.StatusInternalServerError)
 			return
 		}
 		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Weather-Location", location.String())
 		w.Write(json)
 	}
 }
 
+// stationFinder resolves coordinates to the nearest weather station, see
+// fmi.StationFinder.
+type stationFinder interface {
+	Nearest(lat, lon float64) (fmi.Station, float64, error)
+}
+
+// stationLookupError is a failure to load the station list, as opposed to
+// invalid parameters.
+type stationLookupError struct{ error }
+
+func weatherLocation(r *http.Request, def fmi.Location, stations stationFinder) (fmi.Location, error) {
+	q := r.URL.Query()
+	fmisid, place, latStr, lonStr := q.Get("fmisid"), q.Get("place"), q.Get("lat"), q.Get("lon")
+	given := 0
+	for _, set := range []bool{fmisid != "", place != "", latStr != "" || lonStr != ""} {
+		if set {
+			given++
+		}
+	}
+	if given > 1 {
+		return fmi.Location{}, errors.New("Use only one of fmisid, place or lat and lon.")
+	}
+	switch {
+	case fmisid != "":
+		if _, err := strconv.ParseUint(fmisid, 10, 32); err != nil {
+			return fmi.Location{}, errors.New("Invalid fmisid. Use a numeric FMI station id (e.g., 101004).")
+		}
+		return fmi.Location{FMISID: fmi.StationId(fmisid)}, nil
+	case place != "":
+		return fmi.Location{Place: place}, nil
+	case latStr != "" || lonStr != "":
+		lat, err := strconv.ParseFloat(latStr, 64)
+		if err != nil || lat < -90 || lat > 90 {
+			return fmi.Location{}, errors.New("Invalid lat. Use decimal degrees between -90 and 90.")
+		}
+		lon, err := strconv.ParseFloat(lonStr, 64)
+		if err != nil || lon < -180 || lon > 180 {
+			return fmi.Location{}, errors.New("Invalid lon. Use decimal degrees between -180 and 180.")
+		}
+		station, km, err := stations.Nearest(lat, lon)
+		if err != nil {
+			return fmi.Location{}, stationLookupError{err}
+		}
+		log.Debug().Str("fmisid", string(station.Id)).Float64("distance_km", km).Msgf("Nearest weather station to %v,%v", lat, lon)
+		return fmi.Location{FMISID: station.Id}, nil
+	}
+	return def, nil
+}
+
 func getCalendarEvents() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		from := cal.DateOffset{}
@@ -106,7 +175,32 @@ func getCalendarEvents() http.HandlerFunc {
 	}
 }
 
-func getSpotPrices() http.HandlerFunc {
+// openDatabase connects to the database, or returns nil when it is not
+// reachable so the API still serves the live integrations.
+func openDatabase() *sql.DB {
+	conn, err := db.Open()
+	if err != nil {
+		log.Warn().Err(err).Msg("Database not available, spot prices are fetched live and stored readings are not served")
+		return nil
+	}
+	return conn
+}
+
+// newPriceRepository returns a database backed price repository, or nil
+// without a database. Missing prices are fetched from ENTSO-E.
+func newPriceRepository(conn *sql.DB) *spot.PriceRepository {
+	if conn == nil {
+		return nil
+	}
+	source, err := spot.NewSpotServiceFromEnv()
+	if err != nil {
+		log.Warn().Err(err).Msg("Spot price backfill disabled, serving stored prices only")
+		return spot.NewPriceRepository(conn, nil)
+	}
+	return spot.NewPriceRepository(conn, source)
+}
+
+func getSpotPrices(repo *spot.PriceRepository) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		startStr := r.URL.Query().Get("start")
 		endStr := r.URL.Query().Get("end")
@@ -149,7 +243,16 @@ func getSpotPrices() http.HandlerFunc {
 		}
 
 		log.Info().Msgf("Getting spot prices for %s to %s in %s format", start, end, timeFormat)
-		prices, err := spot.GetPrices(start, end, location)
+		var prices *spot.SpotPriceList
+		if repo != nil {
+			prices, err = repo.GetPrices(r.Context(), start, end, location)
+			if err != nil {
+				log.Error().Err(err).Msg("Error reading spot prices from database, fetching live")
+			}
+		}
+		if prices == nil {
+			prices, err = spot.GetPrices(start, end, location)
+		}
 		if err != nil {
 			log.Error().Err(err).Msg("Error getting spot prices")
 			http.Error(w, "Error occurred fetching spot prices", http.StatusInternalServerError)
@@ -169,6 +272,10 @@ func getSpotPrices() http.HandlerFunc {
 }
 
 func getSunData() http.HandlerFunc {
+	defLat, defLon, err := sun.ConfiguredCoordinates()
+	if err != nil {
+		log.Fatal().Err(err).Msg("Invalid sun coordinates")
+	}
 	return func(w http.ResponseWriter, r *http.Request) {
 		// Parse date parameters - only using YYYY-MM-DD format
 		startStr := r.URL.Query().Get("start")
@@ -193,17 +300,32 @@ func getSunData() http.HandlerFunc {
 			}
 		}
 
-		// Load sun data
-		sunData, err := sun.NewSunData()
+		// Coordinates default to SUN_LATITUDE/SUN_LONGITUDE
+		lat, lon := defLat, defLon
+		if latStr, lonStr := r.URL.Query().Get("lat"), r.URL.Query().Get("lon"); latStr != "" || lonStr != "" {
+			lat, err = strconv.ParseFloat(latStr, 64)
+			if err != nil || lat < -90 || lat > 90 {
+				http.Error(w, "Invalid lat. Use decimal degrees between -90 and 90.", http.StatusBadRequest)
+				return
+			}
+			lon, err = strconv.ParseFloat(lonStr, 64)
+			if err != nil || lon < -180 || lon > 180 {
+				http.Error(w, "Invalid lon. Use decimal degrees between -180 and 180.", http.StatusBadRequest)
+				return
+			}
+		}
 
+		tz := r.URL.Query().Get("tz")
+		if tz == "" {
+			tz = "Europe/Helsinki"
+		}
+		loc, err := time.LoadLocation(tz)
 		if err != nil {
-			log.Error().Err(err).Msg("Error loading sun data")
-			http.Error(w, "Error occurred in loading sun data", http.StatusInternalServerError)
+			http.Error(w, "Invalid tz. Use an IANA timezone name (e.g., Europe/Helsinki).", http.StatusBadRequest)
 			return
 		}
 
-		// Get data for the single date (ignoring year, only returns one day)
-		dailySunData := sunData.GetSunDataForDateRange(start, end)
+		dailySunData := sun.NewCalculator(lat, lon, loc).GetSunDataForDateRange(start, end)
 		json, err := json.Marshal(dailySunData)
 		if err != nil {
 			log.Error().Err(err).Msg("Error marshalling sun data to JSON")
@@ -218,24 +340,42 @@ func getSunData() http.HandlerFunc {
 func printEndpoints() {
 	fmt.Println("\nAvailable endpoints:")
 	fmt.Println("-------------------")
-	fmt.Printf("GET /weathernow                  - Current weather observations\n")
-	fmt.Printf("    curl http://localhost:6001/api/weathernow\n")
+	fmt.Printf("GET /weathernow                  - Current weather observations (params: fmisid, place or lat and lon)\n")
+	fmt.Printf("    curl \"http://localhost:6001/api/weathernow?lat=60.27&lon=25.03\"\n")
 
-	fmt.Printf("GET /weatherfore                 - Weather forecast\n")
-	fmt.Printf("    curl http://localhost:6001/api/weatherfore\n")
+	fmt.Printf("GET /weatherfore                 - Weather forecast (params: fmisid, place or lat and lon)\n")
+	fmt.Printf("    curl \"http://localhost:6001/api/weatherfore?place=Tapanila,Helsinki\"\n")
 
-	fmt.Printf("GET /indoor/dev_upstairs         - Indoor temperature\n")
-	fmt.Printf("    curl http://localhost:6001/api/indoor/dev_upstairs\n")
+	fmt.Printf("GET /api/indoor/{sensor}        - Latest indoor values and readings (params: hours)\n")
+	fmt.Printf("    curl \"http://localhost:6001/api/indoor/dev_upstairs?hours=24\"\n")
+
+	fmt.Printf("POST /api/indoor/{sensor}       - Store a reading; Shelly H&T webhooks use /api/indoor/{sensor}/shelly\n")
+	fmt.Printf("    curl -d '{\"temperature\":22.5,\"humidity\":27.4}' http://localhost:6001/api/indoor/dev_upstairs\n")
 
 	fmt.Printf("GET /electricity/prices          - Spot prices for time range (params: start, end, timeFormat)\n")
 	fmt.Printf("    curl \"http://localhost:6001/api/electricity/prices?start=2024-03-20T00:00:00Z&end=2024-03-21T00:00:00Z&timeFormat=Europe/Helsinki\"\n")
 
+	fmt.Printf("GET /electricity/costs           - Cost of Shelly metered devices (params: start, end, group, device)\n")
+	fmt.Printf("    curl \"http://localhost:6001/api/electricity/costs?device=sauna&group=day\"\n")
+
+	fmt.Printf("GET /api/measurements           - Time-bucketed sensor series (params: sensor, start, end, bucket, agg, field)\n")
+	fmt.Printf("    curl \"http://localhost:6001/api/measurements?sensor=indoor_dev_upstairs&field=value->humidity&bucket=1h&agg=max\"\n")
+
 	fmt.Printf("GET /api/events                  - Calendar events for next 7 days\n")
 	fmt.Printf("    curl http://localhost:6001/api/events\n")
 
 	fmt.Printf("GET /api/sun                    - Sunset and runrise info for date range (params: start, end)\n")
 	fmt.Printf("    curl \"http://localhost:6001/api/sun?start=2025-03-20&end=2025-03-21\"\n")
 
+	fmt.Printf("GET /api/devices                - Shelly devices; POST /api/devices/{name}/on|off switches one\n")
+	fmt.Printf("    curl -X POST http://localhost:6001/api/devices/garden-lights/on\n")
+
+	fmt.Printf("GET /api/devices/{name}/power   - Recent power draw and daily kWh (params: hours, days)\n")
+	fmt.Printf("    curl \"http://localhost:6001/api/devices/night-lights/power?hours=24&days=7\"\n")
+
+	fmt.Printf("GET /api/scenes                 - Scenes; POST /api/scenes/{name}/activate switches their devices\n")
+	fmt.Printf("    curl -X POST http://localhost:6001/api/scenes/evening/activate\n")
+
 	fmt.Printf("\nServer running on port %s\n\n", port)
 }
 
@@ -256,18 +396,54 @@ func main() {
 	}
 
 	// Choose handlers based on mock flag
-	h := createRealHandlers()
+	var h handlers
+	var conn *sql.DB
+	var prices *spot.PriceRepository
 	if *useMock {
 		h = createMockHandlers()
+	} else {
+		conn = openDatabase()
+		prices = newPriceRepository(conn)
+		h = createRealHandlers(prices)
+	}
+	tariff, err := spot.TariffFromEnv()
+	if err != nil {
+		log.Fatal().Err(err).Msg("Invalid electricity tariff")
 	}
 
 	mux := http.NewServeMux()
 	mux.HandleFunc("/api/weathernow", h.weatherNow)
-	mux.HandleFunc("/api/indoor/dev_upstairs", h.indoorTemp)
 	mux.HandleFunc("/api/weatherfore", h.weatherFore)
 	mux.HandleFunc("/api/electricity/prices", h.spotPrices)
 	mux.HandleFunc("/api/events", h.calendarEvents)
 	mux.HandleFunc("/api/sun", h.sunData)
+	var power *shelly.PowerRepository
+	if conn != nil {
+		power = shelly.NewPowerRepository(conn)
+	}
+	switch {
+	case *useMock:
+		mux.HandleFunc("GET /api/indoor/dev_upstairs", jsonResponse(mock.IndoorDevUpstairs))
+	case conn != nil:
+		registerIndoorHandlers(mux, indoor.NewRepository(conn))
+	default:
+		log.Warn().Msg("Database not available, indoor sensor endpoints disabled")
+	}
+	if conn != nil {
+		mux.HandleFunc("GET /api/measurements", getMeasurements(conn))
+	}
+	registry, err := shelly.Default()
+	if err != nil {
+		log.Warn().Err(err).Msg("Shelly devices not configured, device endpoints disabled")
+	} else {
+		registerDeviceHandlers(mux, registry, power)
+	}
+	if sceneRegistry, err := scenes.Default(); err != nil {
+		log.Warn().Err(err).Msg("Scenes not configured, scene endpoints disabled")
+	} else {
+		registerSceneHandlers(mux, sceneRegistry)
+	}
+	mux.HandleFunc("GET /api/electricity/costs", getElectricityCosts(registry, power, prices, tariff))
 
 	// Start server in a goroutine
 	server := &http.Server{
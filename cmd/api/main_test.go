@@ -0,0 +1,80 @@
+package main
+
+import (
+	"errors"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mikahozz/gohome/integrations/fmi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stubStations returns station for any coordinates, or err.
+type stubStations struct {
+	station  fmi.StationId
+	err      error
+	lat, lon float64
+}
+
+func (s *stubStations) Nearest(lat, lon float64) (fmi.Station, float64, error) {
+	s.lat, s.lon = lat, lon
+	if s.err != nil {
+		return fmi.Station{}, 0, s.err
+	}
+	return fmi.Station{Id: s.station}, 1.5, nil
+}
+
+func TestWeatherLocation(t *testing.T) {
+	def := fmi.Location{FMISID: "101004"}
+	tests := []struct {
+		name  string
+		query string
+		want  fmi.Location
+		err   string
+	}{
+		{name: "default", query: "", want: def},
+		{name: "fmisid", query: "fmisid=100971", want: fmi.Location{FMISID: "100971"}},
+		{name: "place", query: "place=Tapanila,Helsinki", want: fmi.Location{Place: "Tapanila,Helsinki"}},
+		{name: "coordinates", query: "lat=60.27&lon=25.03", want: fmi.Location{FMISID: "100968"}},
+		{name: "fmisid and place", query: "fmisid=100971&place=Oulu", err: "Use only one of fmisid, place or lat and lon."},
+		{name: "place and coordinates", query: "place=Oulu&lat=60.27&lon=25.03", err: "Use only one of fmisid, place or lat and lon."},
+		{name: "fmisid and lat", query: "fmisid=100971&lat=60.27", err: "Use only one of fmisid, place or lat and lon."},
+		{name: "non-numeric fmisid", query: "fmisid=abc", err: "Invalid fmisid. Use a numeric FMI station id (e.g., 101004)."},
+		{name: "negative fmisid", query: "fmisid=-1", err: "Invalid fmisid. Use a numeric FMI station id (e.g., 101004)."},
+		{name: "lat without lon", query: "lat=60.27", err: "Invalid lon. Use decimal degrees between -180 and 180."},
+		{name: "lon without lat", query: "lon=25.03", err: "Invalid lat. Use decimal degrees between -90 and 90."},
+		{name: "lat out of range", query: "lat=91&lon=25.03", err: "Invalid lat. Use decimal degrees between -90 and 90."},
+		{name: "lon out of range", query: "lat=60.27&lon=-181", err: "Invalid lon. Use decimal degrees between -180 and 180."},
+		{name: "non-numeric lat", query: "lat=north&lon=25.03", err: "Invalid lat. Use decimal degrees between -90 and 90."},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stations := &stubStations{station: "100968"}
+			r := httptest.NewRequest("GET", "/api/weathernow?"+tt.query, nil)
+			got, err := weatherLocation(r, def, stations)
+			if tt.err != "" {
+				require.EqualError(t, err, tt.err)
+				assert.False(t, errors.As(err, new(stationLookupError)), "invalid parameters are not lookup failures")
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestWeatherLocation_NearestStation(t *testing.T) {
+	stations := &stubStations{station: "100968"}
+	r := httptest.NewRequest("GET", "/api/weathernow?lat=60.27&lon=25.03", nil)
+	_, err := weatherLocation(r, fmi.Location{}, stations)
+	require.NoError(t, err)
+	assert.Equal(t, 60.27, stations.lat)
+	assert.Equal(t, 25.03, stations.lon)
+
+	stations.err = errors.New("station list unavailable")
+	_, err = weatherLocation(r, fmi.Location{}, stations)
+	var lookup stationLookupError
+	require.ErrorAs(t, err, &lookup)
+	assert.EqualError(t, err, "station list unavailable")
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mikahozz/gohome/db"
+	"github.com/rs/zerolog/log"
+)
+
+// measurementsTimeout bounds a series query.
+const measurementsTimeout = 15 * time.Second
+
+type measurementsResponse struct {
+	Start  time.Time   `json:"start"`
+	End    time.Time   `json:"end"`
+	Bucket string      `json:"bucket"`
+	Agg    string      `json:"agg"`
+	Field  string      `json:"field"`
+	Series []db.Series `json:"series"`
+}
+
+// getMeasurements serves time-bucketed series of stored measurements:
+//
+//	GET /api/measurements?sensor=a&sensor=b (or sensor=a,b)
+//	    &start=RFC3339&end=RFC3339 (default the last 24 hours)
+//	    &bucket=1h (Go duration or days, e.g. 15m, 1d)
+//	    &agg=avg|min|max|last (default avg)
+//	    &field=main_value|value->key (default main_value)
+func getMeasurements(conn *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		params := r.URL.Query()
+		q := db.SeriesQuery{
+			Field: params.Get("field"),
+			Agg:   db.Aggregate(params.Get("agg")),
+		}
+		for _, s := range params["sensor"] {
+			for _, sensor := range strings.Split(s, ",") {
+				if sensor = strings.TrimSpace(sensor); sensor != "" {
+					q.Sensors = append(q.Sensors, sensor)
+				}
+			}
+		}
+		if q.Field == "" {
+			q.Field = db.MainValue
+		}
+		if q.Agg == "" {
+			q.Agg = db.AggAvg
+		}
+
+		var err error
+		q.End = time.Now()
+		if s := params.Get("end"); s != "" {
+			if q.End, err = time.Parse(time.RFC3339, s); err != nil {
+				http.Error(w, "Invalid end time format. Use RFC3339.", http.StatusBadRequest)
+				return
+			}
+		}
+		q.Start = q.End.Add(-24 * time.Hour)
+		if s := params.Get("start"); s != "" {
+			if q.Start, err = time.Parse(time.RFC3339, s); err != nil {
+				http.Error(w, "Invalid start time format. Use RFC3339.", http.StatusBadRequest)
+				return
+			}
+		}
+		bucket := params.Get("bucket")
+		if bucket == "" {
+			bucket = "1h"
+		}
+		if q.Bucket, err = parseBucket(bucket); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), measurementsTimeout)
+		defer cancel()
+		series, err := db.QuerySeries(ctx, conn, q)
+		if errors.Is(err, db.ErrInvalidSeriesQuery) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			log.Error().Err(err).Strs("sensors", q.Sensors).Msg("Error querying measurements")
+			http.Error(w, "Error occurred querying measurements", http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, measurementsResponse{
+			Start:  q.Start,
+			End:    q.End,
+			Bucket: bucket,
+			Agg:    string(q.Agg),
+			Field:  q.Field,
+			Series: series,
+		})
+	}
+}
+
+// parseBucket accepts Go durations and whole days ("1d", "7d").
+func parseBucket(s string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		if n, err := strconv.Atoi(days); err == nil && n > 0 {
+			return time.Duration(n) * 24 * time.Hour, nil
+		}
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid bucket %q, use e.g. 15m, 1h or 1d", s)
+	}
+	return d, nil
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/mikahozz/gohome/integrations/scenes"
+)
+
+type sceneInfo struct {
+	Name     string              `json:"name"`
+	Parallel bool                `json:"parallel"`
+	Rollback bool                `json:"rollback"`
+	Steps    []scenes.StepConfig `json:"steps"`
+}
+
+// registerSceneHandlers exposes the configured scenes:
+//
+//	GET  /api/scenes                 - configured scenes and their steps
+//	POST /api/scenes/{name}/activate - switch the scene's devices
+func registerSceneHandlers(mux *http.ServeMux, registry *scenes.Registry) {
+	mux.HandleFunc("GET /api/scenes", func(w http.ResponseWriter, r *http.Request) {
+		list := []sceneInfo{}
+		for _, s := range registry.Scenes() {
+			info := sceneInfo{Name: s.Name, Parallel: s.Parallel, Rollback: s.Rollback}
+			for _, step := range s.Steps {
+				state := "off"
+				if step.On {
+					state = "on"
+				}
+				info.Steps = append(info.Steps, scenes.StepConfig{Device: step.Device, State: state, Timeout: step.Timeout.String()})
+			}
+			list = append(list, info)
+		}
+		writeJSON(w, list)
+	})
+	mux.HandleFunc("POST /api/scenes/{name}/activate", func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 2*time.Minute)
+		defer cancel()
+		result, err := registry.Activate(ctx, r.PathValue("name"))
+		if errors.Is(err, scenes.ErrUnknownScene) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			// The result tells which steps failed and what was rolled back
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadGateway)
+		}
+		writeJSON(w, result)
+	})
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/mikahozz/gohome/integrations/notify"
+	"github.com/rs/zerolog/log"
+)
+
+// alertTimeout bounds the delivery of a single notification.
+const alertTimeout = 30 * time.Second
+
+// UseNotifier sets where failed actions and other notable events are
+// reported. The notifier should deduplicate (notify.Limiter): a failing
+// action reports every attempt.
+func (s *Scheduler) UseNotifier(n notify.Notifier) {
+	s.mu.Lock()
+	s.notifier = n
+	s.mu.Unlock()
+}
+
+// alert sends a notification in the background so a slow channel does not
+// hold up actions.
+func (s *Scheduler) alert(m notify.Message) {
+	s.mu.RLock()
+	n := s.notifier
+	s.mu.RUnlock()
+	if n == nil {
+		return
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), alertTimeout)
+		defer cancel()
+		if err := n.Notify(ctx, m); err != nil {
+			log.Error().Err(err).Str("event", "notify_error").Str("key", m.Key).Msg("failed to send notification")
+		}
+	}()
+}
+
+func (s *Scheduler) alertActionFailed(sch *DailySchedule, attempt int, next time.Time, err error) {
+	retry := "retrying on the next evaluation"
+	if next.After(s.clock.Now()) {
+		retry = "retrying at " + next.In(zone).Format("15:04:05")
+	}
+	s.alert(notify.Message{
+		Title: "Action failed: " + sch.Name,
+		Body:  fmt.Sprintf("%v\nAttempt %d, %s.", err, attempt, retry),
+		Key:   "action_error:" + sch.Name,
+	})
+}
+
+func (s *Scheduler) alertGaveUp(sch *DailySchedule, trigger time.Time, attempts int, lastError string) {
+	s.alert(notify.Message{
+		Title:    "Action gave up: " + sch.Name,
+		Body:     fmt.Sprintf("%d attempts at the %s trigger failed, waiting for the next one. Last error: %s", attempts, trigger.In(zone).Format("2006-01-02 15:04"), lastError),
+		Priority: notify.High,
+		Key:      "action_gave_up:" + sch.Name,
+	})
+}
+
+func (s *Scheduler) alertRecovered(sch *DailySchedule, attempts int) {
+	s.alert(notify.Message{
+		Title:    "Action recovered: " + sch.Name,
+		Body:     fmt.Sprintf("Succeeded on attempt %d.", attempts),
+		Priority: notify.Low,
+		Key:      "action_recovered:" + sch.Name,
+	})
+}
+
+func (s *Scheduler) alertPanic(sch *DailySchedule, r interface{}) {
+	s.alert(notify.Message{
+		Title:    "Action panicked: " + sch.Name,
+		Body:     fmt.Sprint(r),
+		Priority: notify.High,
+		Key:      "action_panic:" + sch.Name,
+	})
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mikahozz/gohome/integrations/notify"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type recordingNotifier struct {
+	mu       sync.Mutex
+	messages []notify.Message
+}
+
+func (r *recordingNotifier) Notify(ctx context.Context, m notify.Message) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.messages = append(r.messages, m)
+	return nil
+}
+
+func (r *recordingNotifier) titles() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var titles []string
+	for _, m := range r.messages {
+		titles = append(titles, m.Title)
+	}
+	return titles
+}
+
+func TestScheduler_AlertsFailedActions(t *testing.T) {
+	day := time.Date(2025, 11, 3, 0, 0, 0, 0, zone)
+	clock := NewFakeClock(day.Add(7 * time.Hour))
+	s := NewSchedulerWithClock(clock)
+	rec := &recordingNotifier{}
+	s.UseNotifier(rec)
+	sch, _ := failingSchedule(day, RetryPolicy{MaxAttempts: 2, Backoff: time.Minute}, 0)
+	s.AddSchedule(sch)
+
+	for i := 0; i < 3; i++ {
+		s.evaluate(clock.Now())
+		time.Sleep(20 * time.Millisecond)
+		clock.Advance(time.Minute)
+	}
+	assert.Equal(t, []string{"Action failed: Heater", "Action gave up: Heater"}, rec.titles())
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	require.Len(t, rec.messages, 2)
+	assert.Equal(t, "device offline\nAttempt 1, retrying at 07:01:00.", rec.messages[0].Body)
+	assert.Equal(t, "action_error:Heater", rec.messages[0].Key)
+	assert.Equal(t, notify.High, rec.messages[1].Priority)
+	assert.Contains(t, rec.messages[1].Body, "2 attempts at the 2025-11-03 07:00 trigger failed")
+}
+
+func TestScheduler_AlertsRecovery(t *testing.T) {
+	day := time.Date(2025, 11, 3, 0, 0, 0, 0, zone)
+	clock := NewFakeClock(day.Add(7 * time.Hour))
+	s := NewSchedulerWithClock(clock)
+	rec := &recordingNotifier{}
+	s.UseNotifier(rec)
+	sch, _ := failingSchedule(day, RetryPolicy{}, 2)
+	s.AddSchedule(sch)
+
+	for i := 0; i < 2; i++ {
+		s.evaluate(clock.Now())
+		time.Sleep(20 * time.Millisecond)
+		clock.Advance(time.Minute)
+	}
+	assert.Equal(t, []string{"Action failed: Heater", "Action recovered: Heater"}, rec.titles())
+}
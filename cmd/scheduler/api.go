@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const apiPort = ":6002"
+
+// ScheduleStatus is the API representation of a registered schedule.
+type ScheduleStatus struct {
+	Name           string     `json:"name"`
+	Category       string     `json:"category,omitempty"`
+	NextTrigger    time.Time  `json:"next_trigger"`
+	LastTriggered  *time.Time `json:"last_triggered,omitempty"`
+	LastError      string     `json:"last_error,omitempty"`
+	LastSkipReason string     `json:"last_skip_reason,omitempty"`
+	Paused         bool       `json:"paused"`
+	CategoryPaused bool       `json:"category_paused,omitempty"`
+	Override       *Override  `json:"override,omitempty"`
+	Plan           *PricePlan `json:"plan,omitempty"`
+	// Retry holds the attempts at the latest trigger and their outcome.
+	Retry *RetryStatus `json:"retry,omitempty"`
+}
+
+// Status returns the current state of every registered schedule.
+func (s *Scheduler) Status() []ScheduleStatus {
+	now := s.clock.Now()
+	s.mu.RLock()
+	schedules := make([]*DailySchedule, len(s.schedules))
+	copy(schedules, s.schedules)
+	s.mu.RUnlock()
+
+	result := make([]ScheduleStatus, 0, len(schedules))
+	for _, sch := range schedules {
+		next := s.nextTrigger(sch, now)
+		s.mu.RLock()
+		st := ScheduleStatus{
+			Name:           sch.Name,
+			Category:       sch.Category,
+			NextTrigger:    next,
+			LastError:      sch.LastError,
+			LastSkipReason: sch.LastSkipReason,
+			Paused:         s.controls.pausedSchedules[sch.Name],
+			CategoryPaused: sch.Category != "" && s.controls.pausedCategories[sch.Category],
+			Plan:           sch.Plan,
+			Retry:          retryStatus(sch),
+		}
+		if !sch.LastTriggered.IsZero() {
+			last := sch.LastTriggered
+			st.LastTriggered = &last
+		}
+		if o, ok := s.controls.overrides[sch.Name]; ok {
+			st.Override = &o
+		}
+		s.mu.RUnlock()
+		result = append(result, st)
+	}
+	return result
+}
+
+// NewAPIHandler exposes schedule status and controls over HTTP.
+func NewAPIHandler(s *Scheduler) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/schedules", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, s.Status())
+	})
+	mux.HandleFunc("GET /api/schedules/{name}", func(w http.ResponseWriter, r *http.Request) {
+		name := r.PathValue("name")
+		for _, st := range s.Status() {
+			if st.Name == name {
+				writeJSON(w, http.StatusOK, st)
+				return
+			}
+		}
+		writeError(w, ErrScheduleNotFound)
+	})
+	mux.HandleFunc("POST /api/schedules/{name}/run", func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+		defer cancel()
+		if err := s.RunNow(ctx, r.PathValue("name")); err != nil {
+			writeError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+	})
+	mux.HandleFunc("POST /api/schedules/{name}/pause", scheduleControl(s.SetSchedulePaused, true))
+	mux.HandleFunc("POST /api/schedules/{name}/resume", scheduleControl(s.SetSchedulePaused, false))
+	mux.HandleFunc("POST /api/categories/{name}/pause", scheduleControl(s.SetCategoryPaused, true))
+	mux.HandleFunc("POST /api/categories/{name}/resume", scheduleControl(s.SetCategoryPaused, false))
+	mux.HandleFunc("POST /api/schedules/{name}/override", func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Time string `json:"time"` // HH:MM
+			Skip bool   `json:"skip"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, "Invalid JSON body. Use {\"time\":\"HH:MM\"} or {\"skip\":true}.", http.StatusBadRequest)
+			return
+		}
+		var hour, minute int
+		if !body.Skip {
+			if _, err := fmt.Sscanf(body.Time, "%d:%d", &hour, &minute); err != nil || hour > 23 || minute > 59 || hour < 0 || minute < 0 {
+				http.Error(w, "Invalid time. Use HH:MM.", http.StatusBadRequest)
+				return
+			}
+		}
+		o, err := s.SetOverride(r.PathValue("name"), hour, minute, body.Skip)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, o)
+	})
+	mux.HandleFunc("DELETE /api/schedules/{name}/override", func(w http.ResponseWriter, r *http.Request) {
+		if err := s.ClearOverride(r.PathValue("name")); err != nil {
+			writeError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+	return mux
+}
+
+func scheduleControl(set func(string, bool) error, paused bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := set(r.PathValue("name"), paused); err != nil {
+			writeError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]bool{"paused": paused})
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Error().Err(err).Msg("Error writing JSON response")
+	}
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	if errors.Is(err, ErrScheduleNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, ErrOverrideNotSupported) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	log.Error().Err(err).Msg("")
+	http.Error(w, err.Error(), http.StatusInternalServerError)
+}
+
+// StartAPI serves the control API until the scheduler is stopped.
+func (s *Scheduler) StartAPI(addr string) {
+	server := &http.Server{Addr: addr, Handler: NewAPIHandler(s)}
+	go func() {
+		if err := server.ListenAndServe(); err != http.ErrServerClosed {
+			log.Fatal().Err(err).Msg("Scheduler API failed to start")
+		}
+	}()
+	go func() {
+		<-s.ctx.Done()
+		server.Close()
+	}()
+	log.Info().Str("event", "api_start").Str("addr", addr).Msg("scheduler API listening")
+}
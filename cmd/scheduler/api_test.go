@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newAPITestScheduler(now time.Time, calls *int32) *Scheduler {
+	s := NewSchedulerWithClock(NewFakeClock(now))
+	act := func(ctx context.Context) error { atomic.AddInt32(calls, 1); return nil }
+	at := func(h, m int) func() time.Time {
+		return func() time.Time { return time.Date(now.Year(), now.Month(), now.Day(), h, m, 0, 0, zone) }
+	}
+	s.AddSchedule(&DailySchedule{Name: "Lights ON", Category: "lights", Trigger: Trigger{Time: at(16, 0)}, Action: act})
+	s.AddSchedule(&DailySchedule{Name: "Lights OFF", Category: "lights", Trigger: Trigger{Time: at(23, 0)}, Action: act})
+	return s
+}
+
+func doRequest(t *testing.T, h http.Handler, method, path, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestAPI_ListSchedules(t *testing.T) {
+	now := time.Date(2025, 11, 8, 17, 0, 0, 0, zone)
+	var calls int32
+	s := newAPITestScheduler(now, &calls)
+	s.evaluate(now)
+	time.Sleep(50 * time.Millisecond)
+
+	rec := doRequest(t, NewAPIHandler(s), http.MethodGet, "/api/schedules", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var got []ScheduleStatus
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &got))
+	require.Len(t, got, 2)
+
+	assert.Equal(t, "Lights ON", got[0].Name)
+	require.NotNil(t, got[0].LastTriggered)
+	assert.True(t, got[0].NextTrigger.Equal(time.Date(2025, 11, 9, 16, 0, 0, 0, zone)))
+
+	assert.Nil(t, got[1].LastTriggered)
+	assert.Equal(t, "trigger_time_not_reached", got[1].LastSkipReason)
+	assert.True(t, got[1].NextTrigger.Equal(time.Date(2025, 11, 8, 23, 0, 0, 0, zone)))
+}
+
+func TestAPI_PauseAndResume(t *testing.T) {
+	now := time.Date(2025, 11, 8, 23, 30, 0, 0, zone)
+	var calls int32
+	s := newAPITestScheduler(now, &calls)
+	h := NewAPIHandler(s)
+
+	rec := doRequest(t, h, http.MethodPost, "/api/categories/lights/pause", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	s.evaluate(now)
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, int32(0), atomic.LoadInt32(&calls))
+	assert.Equal(t, "category_paused", s.schedules[1].LastSkipReason)
+
+	doRequest(t, h, http.MethodPost, "/api/categories/lights/resume", "")
+	rec = doRequest(t, h, http.MethodPost, "/api/schedules/"+url.PathEscape("Lights OFF")+"/pause", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	s.evaluate(now)
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, "paused", s.schedules[1].LastSkipReason)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls), "only the unpaused ON schedule runs")
+
+	doRequest(t, h, http.MethodPost, "/api/schedules/"+url.PathEscape("Lights OFF")+"/resume", "")
+	s.evaluate(now)
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+
+	rec = doRequest(t, h, http.MethodPost, "/api/schedules/missing/pause", "")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestAPI_OverrideToday(t *testing.T) {
+	now := time.Date(2025, 11, 8, 21, 0, 0, 0, zone)
+	var calls int32
+	s := newAPITestScheduler(now, &calls)
+	s.schedules[0].LastTriggered = time.Date(2025, 11, 8, 16, 0, 0, 0, zone)
+	h := NewAPIHandler(s)
+	path := "/api/schedules/" + url.PathEscape("Lights OFF") + "/override"
+
+	rec := doRequest(t, h, http.MethodPost, path, `{"time":"25:00"}`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = doRequest(t, h, http.MethodPost, path, `{"time":"20:30"}`)
+	require.Equal(t, http.StatusOK, rec.Code)
+	s.evaluate(now)
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls), "override moved OFF earlier so it should run at 21:00")
+
+	// Skipping today's trigger
+	late := time.Date(2025, 11, 8, 23, 30, 0, 0, zone)
+	s2 := newAPITestScheduler(late, &calls)
+	s2.schedules[0].LastTriggered = time.Date(2025, 11, 8, 16, 0, 0, 0, zone)
+	rec = doRequest(t, NewAPIHandler(s2), http.MethodPost, path, `{"skip":true}`)
+	require.Equal(t, http.StatusOK, rec.Code)
+	s2.evaluate(late)
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, "skipped_by_override", s2.schedules[1].LastSkipReason)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+	st := s2.Status()
+	assert.True(t, st[1].NextTrigger.Equal(time.Date(2025, 11, 9, 23, 0, 0, 0, zone)))
+}
+
+func TestAPI_RunNow(t *testing.T) {
+	now := time.Date(2025, 11, 8, 10, 0, 0, 0, zone)
+	var calls int32
+	s := newAPITestScheduler(now, &calls)
+	rec := doRequest(t, NewAPIHandler(s), http.MethodPost, "/api/schedules/"+url.PathEscape("Lights ON")+"/run", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+	assert.True(t, s.schedules[0].LastTriggered.IsZero(), "manual run must not consume today's trigger")
+}
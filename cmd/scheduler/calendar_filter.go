@@ -0,0 +1,132 @@
+package main
+
+import (
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/mikahozz/gohome/integrations/cal"
+	"github.com/rs/zerolog/log"
+)
+
+// CalendarEventMode tells whether a calendar filter needs a matching event.
+type CalendarEventMode string
+
+const (
+	// EventPresent passes only on days covered by a matching event.
+	EventPresent CalendarEventMode = "present"
+	// EventAbsent passes on days without a matching event, e.g. skip on holidays.
+	EventAbsent CalendarEventMode = "absent"
+)
+
+const (
+	// calendarRefreshInterval is how long fetched events are used before a refresh.
+	calendarRefreshInterval = 30 * time.Minute
+	// calendarRetryInterval is the wait after a failed fetch.
+	calendarRetryInterval = 5 * time.Minute
+)
+
+// calendarFetchRange is the period fetched from the calendar, relative to now.
+var calendarFetchRange = [2]cal.DateOffset{{Days: -1}, {Days: 7}}
+
+// calendarCache keeps the last fetched calendar events. Refreshes run in the
+// background so a slow or unreachable calendar never blocks evaluation; until
+// the first fetch succeeds there are no events.
+type calendarCache struct {
+	fetch func() ([]cal.Event, error)
+
+	mu        sync.Mutex
+	events    []cal.Event
+	loaded    bool
+	fetchedAt time.Time
+	failedAt  time.Time
+	fetching  bool
+}
+
+func newCalendarCache(fetch func() ([]cal.Event, error)) *calendarCache {
+	return &calendarCache{fetch: fetch}
+}
+
+// UseCalendar sets the calendar used by calendar filters.
+func (s *Scheduler) UseCalendar(fetch func() ([]cal.Event, error)) {
+	s.mu.Lock()
+	s.calendar = newCalendarCache(fetch)
+	s.mu.Unlock()
+}
+
+// familyCalendar fetches the family calendar around today.
+func familyCalendar() ([]cal.Event, error) {
+	return cal.GetFamilyCalendarEvents(calendarFetchRange[0], calendarFetchRange[1])
+}
+
+// Events returns the cached events and whether any fetch has succeeded yet.
+// A refresh is started in the background when the events are stale.
+func (c *calendarCache) Events(now time.Time) ([]cal.Event, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stale := !c.loaded || now.Sub(c.fetchedAt) >= calendarRefreshInterval
+	retryWait := !c.failedAt.IsZero() && now.Sub(c.failedAt) < calendarRetryInterval
+	if stale && !retryWait && !c.fetching {
+		c.fetching = true
+		go c.refresh(now)
+	}
+	return c.events, c.loaded
+}
+
+// refresh fetches the events. On failure the previous events are kept.
+func (c *calendarCache) refresh(now time.Time) {
+	events, err := c.fetch()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.fetching = false
+	if err != nil {
+		c.failedAt = now
+		log.Error().Err(err).Str("event", "calendar_fetch_error").Bool("stale_events", c.loaded).Msg("Failed to fetch calendar events")
+		return
+	}
+	c.events, c.loaded, c.fetchedAt, c.failedAt = events, true, now, time.Time{}
+	log.Info().Str("event", "calendar_fetched").Int("events", len(events)).Msg("Calendar events refreshed")
+}
+
+// matchingEvent returns the first event whose summary matches pattern and
+// that overlaps the day of now in loc.
+func matchingEvent(events []cal.Event, pattern *regexp.Regexp, now time.Time, loc *time.Location) (cal.Event, bool) {
+	local := now.In(loc)
+	dayStart := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+	dayEnd := dayStart.AddDate(0, 0, 1)
+	for _, e := range events {
+		if e.Start.Before(dayEnd) && e.End.After(dayStart) && pattern.MatchString(e.Summary) {
+			return e, true
+		}
+	}
+	return cal.Event{}, false
+}
+
+// calendarFilterPass evaluates a calendar filter for the day of now.
+func (s *Scheduler) calendarFilterPass(filter Filter, now time.Time) bool {
+	s.mu.RLock()
+	cache := s.calendar
+	s.mu.RUnlock()
+	var events []cal.Event
+	if cache == nil {
+		log.Warn().Str("event", "calendar_not_configured").Msg("Calendar filter used without a calendar, assuming no events")
+	} else {
+		var loaded bool
+		events, loaded = cache.Events(now)
+		if !loaded {
+			log.Warn().Str("event", "calendar_unavailable").Msg("Calendar events not loaded yet, assuming no events")
+		}
+	}
+	loc := filter.Location
+	if loc == nil {
+		loc = now.Location()
+	}
+	event, found := matchingEvent(events, filter.Pattern, now, loc)
+	if found {
+		log.Debug().Str("event", "calendar_filter_match").Str("summary", event.Summary).Str("pattern", filter.Pattern.String()).Msg("Calendar event matched")
+	}
+	if filter.Event == EventAbsent {
+		return !found
+	}
+	return found
+}
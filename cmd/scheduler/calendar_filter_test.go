@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mikahozz/gohome/integrations/cal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func holidayEvents() []cal.Event {
+	return []cal.Event{
+		// All-day events end at midnight after the last day
+		{Uid: "1", Summary: "Syysloma", Start: time.Date(2025, 10, 13, 0, 0, 0, 0, zone), End: time.Date(2025, 10, 18, 0, 0, 0, 0, zone)},
+		{Uid: "2", Summary: "Sauna #sauna", Start: time.Date(2025, 10, 20, 18, 0, 0, 0, zone), End: time.Date(2025, 10, 20, 20, 0, 0, 0, zone)},
+	}
+}
+
+func TestMatchingEvent(t *testing.T) {
+	holiday := regexp.MustCompile("(?i)loma|holiday")
+	_, found := matchingEvent(holidayEvents(), holiday, time.Date(2025, 10, 17, 6, 45, 0, 0, zone), zone)
+	assert.True(t, found)
+	_, found = matchingEvent(holidayEvents(), holiday, time.Date(2025, 10, 18, 6, 45, 0, 0, zone), zone)
+	assert.False(t, found, "the event ends at midnight")
+	e, found := matchingEvent(holidayEvents(), regexp.MustCompile("(?i)sauna"), time.Date(2025, 10, 20, 6, 45, 0, 0, zone), zone)
+	assert.True(t, found, "an event later in the day covers the day")
+	assert.Equal(t, "2", e.Uid)
+}
+
+func TestScheduleConfig_CalendarFilters(t *testing.T) {
+	b := testBuilder(time.Date(2025, 10, 17, 12, 0, 0, 0, zone))
+	path := writeFile(t, "schedules.yaml", `
+schedules:
+  - name: Morning lights
+    trigger: "06:45"
+    action: shelly.on
+    filters:
+      - type: calendar
+        match: "Loma|Holiday"
+        event: absent
+  - name: Sauna
+    trigger: "17:00"
+    action: shelly.on
+    filters:
+      - type: calendar
+        tag: sauna
+        event: present
+`)
+	schedules, err := b.LoadScheduleFile(path)
+	require.NoError(t, err)
+	f := schedules[0].Filters[0]
+	assert.Equal(t, FilterCalendar, f.Type)
+	assert.Equal(t, EventAbsent, f.Event)
+	assert.True(t, f.Pattern.MatchString("Syysloma"))
+	tag := schedules[1].Filters[0].Pattern
+	assert.True(t, tag.MatchString("Sauna #Sauna"))
+	assert.False(t, tag.MatchString("Sauna"))
+	assert.False(t, tag.MatchString("#saunaton"))
+
+	path = writeFile(t, "bad.yaml", `
+schedules:
+  - name: No mode
+    trigger: "06:45"
+    action: shelly.on
+    filters: [{type: calendar, match: Loma}]
+  - name: No pattern
+    trigger: "06:45"
+    action: shelly.on
+    filters: [{type: calendar, event: absent}]
+  - name: Bad regexp
+    trigger: "06:45"
+    action: shelly.on
+    filters: [{type: calendar, match: "Loma(", event: absent}]
+`)
+	_, err = b.LoadScheduleFile(path)
+	require.Error(t, err)
+	for _, want := range []string{`"No mode"`, `"No pattern"`, `"Bad regexp"`} {
+		assert.Contains(t, err.Error(), want)
+	}
+}
+
+func TestScheduler_CalendarFilter(t *testing.T) {
+	now := time.Date(2025, 10, 17, 6, 45, 0, 0, zone)
+	s := NewSchedulerWithClock(NewFakeClock(now))
+	var fetches int32
+	s.UseCalendar(func() ([]cal.Event, error) {
+		atomic.AddInt32(&fetches, 1)
+		return holidayEvents(), nil
+	})
+	s.calendar.refresh(now.Add(-time.Minute))
+
+	var runs int32
+	sch := &DailySchedule{
+		Name:    "Morning lights",
+		Trigger: Trigger{Time: func() time.Time { return time.Date(2025, 10, 17, 6, 45, 0, 0, zone) }},
+		Filters: []Filter{{Type: FilterCalendar, Pattern: regexp.MustCompile("(?i)loma|holiday"), Event: EventAbsent, Location: zone}},
+		Action:  func(ctx context.Context) error { atomic.AddInt32(&runs, 1); return nil },
+	}
+	s.AddSchedule(sch)
+	s.evaluate(now)
+	time.Sleep(20 * time.Millisecond)
+	assert.Equal(t, int32(0), atomic.LoadInt32(&runs), "skipped during the holiday")
+	assert.Equal(t, "filters_not_passed", sch.LastSkipReason)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&fetches), "fresh events are not refetched")
+}
+
+func TestCalendarCache_Outage(t *testing.T) {
+	now := time.Date(2025, 10, 17, 6, 45, 0, 0, zone)
+	fail := errors.New("calendar down")
+	var err error
+	c := newCalendarCache(func() ([]cal.Event, error) {
+		if err != nil {
+			return nil, err
+		}
+		return holidayEvents(), nil
+	})
+	c.refresh(now)
+	err = fail
+	c.refresh(now.Add(time.Hour))
+	events, loaded := c.Events(now.Add(time.Hour))
+	assert.True(t, loaded)
+	assert.Len(t, events, 2, "previous events are kept when a refresh fails")
+
+	// A hanging calendar does not block evaluation
+	release := make(chan struct{})
+	defer close(release)
+	hanging := newCalendarCache(func() ([]cal.Event, error) {
+		<-release
+		return holidayEvents(), nil
+	})
+	done := make(chan struct{})
+	go func() {
+		events, loaded = hanging.Events(now)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Events blocked on the calendar fetch")
+	}
+	assert.False(t, loaded)
+	assert.Empty(t, events)
+}
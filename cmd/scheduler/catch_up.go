@@ -0,0 +1,110 @@
+package main
+
+import (
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// CatchUpPolicy decides what happens to a trigger missed while the scheduler
+// was not running (downtime, suspend).
+type CatchUpPolicy string
+
+const (
+	// FireLate runs a missed trigger when the scheduler gets to it (default).
+	FireLate CatchUpPolicy = "fire_late"
+	// SkipIfMissedBy skips a trigger missed by more than the schedule's MissedBy.
+	SkipIfMissedBy CatchUpPolicy = "skip_if_missed_by"
+	// FireOnlyLatestInCategory skips a missed trigger when a later trigger of
+	// the same category has passed too, even if that one does not run.
+	FireOnlyLatestInCategory CatchUpPolicy = "fire_only_latest_in_category"
+)
+
+// missedTolerance is how late a trigger may be evaluated without counting as
+// missed.
+const missedTolerance = time.Minute
+
+// missedTrigger records what was decided for a missed trigger.
+type missedTrigger struct {
+	Name     string
+	Trigger  time.Time
+	MissedBy time.Duration
+	Policy   CatchUpPolicy
+	Skip     bool
+	Reason   string
+}
+
+// decideMissed finds the triggers that passed since the previous evaluation
+// without being evaluated in time, applies each schedule's policy and logs
+// the decisions. Skipped triggers are blocked until the next one.
+func (s *Scheduler) decideMissed(schedules []*DailySchedule, now time.Time) []missedTrigger {
+	s.mu.RLock()
+	last := s.lastEval
+	s.mu.RUnlock()
+
+	var decisions []missedTrigger
+	for _, sch := range schedules {
+		if hasTriggeredThisPeriod(sch, now) {
+			continue
+		}
+		t := s.triggerTime(sch)
+		if t.IsZero() || t.After(now) || now.Sub(t) <= missedTolerance || (!last.IsZero() && !t.After(last)) {
+			continue
+		}
+		d := missedTrigger{Name: sch.Name, Trigger: t, MissedBy: now.Sub(t), Policy: sch.CatchUp, Reason: "fire_late"}
+		if d.Policy == "" {
+			d.Policy = FireLate
+		}
+		switch d.Policy {
+		case SkipIfMissedBy:
+			if d.MissedBy > sch.MissedBy {
+				d.Skip, d.Reason = true, "missed_by_too_much"
+			}
+		case FireOnlyLatestInCategory:
+			if later := s.laterInCategory(schedules, sch, t, now); later != "" {
+				d.Skip, d.Reason = true, "superseded_by_"+later
+			}
+		}
+		decisions = append(decisions, d)
+	}
+
+	s.mu.Lock()
+	for _, d := range decisions {
+		if d.Skip {
+			s.missed[d.Name] = d.Trigger
+		}
+	}
+	s.mu.Unlock()
+	for _, d := range decisions {
+		log.Info().Str("event", "missed_trigger").Str("name", d.Name).Time("trigger_time", d.Trigger).
+			Dur("missed_by", d.MissedBy).Str("policy", string(d.Policy)).Bool("skip", d.Skip).Str("decision", d.Reason).
+			Bool("startup", last.IsZero()).Msg("trigger missed while not running")
+	}
+	return decisions
+}
+
+// laterInCategory returns the name of a schedule in sch's category whose
+// trigger passed after t, or "".
+func (s *Scheduler) laterInCategory(schedules []*DailySchedule, sch *DailySchedule, t, now time.Time) string {
+	if sch.Category == "" {
+		return ""
+	}
+	for _, other := range schedules {
+		if other == sch || other.Category != sch.Category {
+			continue
+		}
+		if ot := s.triggerTime(other); ot.After(t) && !ot.After(now) {
+			return other.Name
+		}
+	}
+	return ""
+}
+
+// missedSkipped reports whether the schedule's current trigger was skipped by
+// its catch-up policy.
+func (s *Scheduler) missedSkipped(sch *DailySchedule) bool {
+	s.mu.RLock()
+	t, ok := s.missed[sch.Name]
+	s.mu.RUnlock()
+	return ok && t.Equal(s.triggerTime(sch))
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScheduler_CatchUp(t *testing.T) {
+	day := time.Date(2025, 11, 3, 0, 0, 0, 0, zone)
+	clockAt := func(hour, minute int) func() time.Time {
+		return func() time.Time { return day.Add(time.Duration(hour)*time.Hour + time.Duration(minute)*time.Minute) }
+	}
+	var mu sync.Mutex
+	var ran []string
+	act := func(name string) func(context.Context) error {
+		return func(context.Context) error { mu.Lock(); ran = append(ran, name); mu.Unlock(); return nil }
+	}
+	rainy := Filter{Type: FilterWeekday} // passes on no day
+
+	s := NewSchedulerWithClock(NewFakeClock(day))
+	for _, sch := range []*DailySchedule{
+		{Name: "Heater", Trigger: Trigger{Time: clockAt(7, 30)}, Action: act("Heater")},
+		{Name: "Blinds", Trigger: Trigger{Time: clockAt(8, 0)}, CatchUp: SkipIfMissedBy, MissedBy: time.Hour, Action: act("Blinds")},
+		{Name: "Coffee", Trigger: Trigger{Time: clockAt(9, 30)}, CatchUp: SkipIfMissedBy, MissedBy: time.Hour, Action: act("Coffee")},
+		{Name: "Lights on", Category: "lights", Trigger: Trigger{Time: clockAt(7, 0)}, CatchUp: FireOnlyLatestInCategory, Action: act("Lights on")},
+		{Name: "Lights off", Category: "lights", Trigger: Trigger{Time: clockAt(9, 0)}, Filters: []Filter{rainy}, Action: act("Lights off")},
+		{Name: "Later", Trigger: Trigger{Time: clockAt(12, 0)}, CatchUp: SkipIfMissedBy, MissedBy: time.Minute, Action: act("Later")},
+	} {
+		s.AddSchedule(sch)
+	}
+
+	// Started at 10:00 after being down since before 07:00
+	now := clockAt(10, 0)()
+	s.mu.RLock()
+	schedules := append([]*DailySchedule(nil), s.schedules...)
+	s.mu.RUnlock()
+	decisions := s.decideMissed(schedules, now)
+	got := map[string]string{}
+	for _, d := range decisions {
+		got[d.Name] = d.Reason
+	}
+	assert.Equal(t, map[string]string{
+		"Heater":     "fire_late",
+		"Blinds":     "missed_by_too_much",
+		"Coffee":     "fire_late",
+		"Lights on":  "superseded_by_Lights off",
+		"Lights off": "fire_late",
+	}, got)
+
+	s.evaluate(now)
+	time.Sleep(50 * time.Millisecond)
+	mu.Lock()
+	assert.ElementsMatch(t, []string{"Heater", "Coffee"}, ran, "Lights on would have run as the off switch is filtered out")
+	mu.Unlock()
+	assert.Equal(t, "missed_trigger_skipped", schedules[1].LastSkipReason)
+	assert.Equal(t, "missed_trigger_skipped", schedules[3].LastSkipReason)
+
+	// Running normally again: later triggers are not missed, skipped ones stay skipped
+	s.evaluate(clockAt(10, 1)())
+	s.evaluate(clockAt(12, 0)())
+	time.Sleep(50 * time.Millisecond)
+	mu.Lock()
+	assert.ElementsMatch(t, []string{"Heater", "Coffee", "Later"}, ran)
+	mu.Unlock()
+	assert.Equal(t, "missed_trigger_skipped", schedules[1].LastSkipReason)
+}
+
+func TestScheduler_CatchUpOnlyAfterDowntime(t *testing.T) {
+	now := time.Date(2025, 11, 3, 6, 0, 0, 0, zone)
+	var runs int
+	var mu sync.Mutex
+	s := NewSchedulerWithClock(NewFakeClock(now))
+	s.AddSchedule(&DailySchedule{
+		Name:     "Car heater",
+		Trigger:  Trigger{Time: func() time.Time { return now }},
+		CatchUp:  SkipIfMissedBy,
+		MissedBy: 10 * time.Minute,
+		Filters:  []Filter{{Type: FilterWeekday}}, // passes on no day
+		Action:   func(context.Context) error { mu.Lock(); runs++; mu.Unlock(); return nil },
+	})
+
+	s.evaluate(now) // on time, but filtered out
+	s.schedules[0].Filters = nil
+	s.evaluate(now.Add(30 * time.Minute))
+	time.Sleep(50 * time.Millisecond)
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, 1, runs, "a trigger evaluated on time is not missed when it runs later")
+}
+
+func TestScheduleConfig_CatchUp(t *testing.T) {
+	b := testBuilder(time.Date(2025, 11, 3, 12, 0, 0, 0, zone))
+	schedules, err := b.Build(ScheduleFile{Schedules: []ScheduleConfig{
+		{Name: "A", Trigger: "07:00", Action: "shelly.on", CatchUp: "skip_if_missed_by", MissedBy: "30m"},
+		{Name: "B", Category: "lights", Trigger: "08:00", Action: "shelly.on", CatchUp: "fire_only_latest_in_category"},
+		{Name: "C", Trigger: "09:00", Action: "shelly.on"},
+	}})
+	require.NoError(t, err)
+	assert.Equal(t, SkipIfMissedBy, schedules[0].CatchUp)
+	assert.Equal(t, 30*time.Minute, schedules[0].MissedBy)
+	assert.Equal(t, FireOnlyLatestInCategory, schedules[1].CatchUp)
+	assert.Equal(t, CatchUpPolicy(""), schedules[2].CatchUp)
+
+	_, err = b.Build(ScheduleFile{Schedules: []ScheduleConfig{
+		{Name: "No limit", Trigger: "07:00", Action: "shelly.on", CatchUp: "skip_if_missed_by"},
+		{Name: "No category", Trigger: "07:00", Action: "shelly.on", CatchUp: "fire_only_latest_in_category"},
+		{Name: "Limit alone", Trigger: "07:00", Action: "shelly.on", MissedBy: "1h"},
+		{Name: "Unknown", Trigger: "07:00", Action: "shelly.on", CatchUp: "maybe"},
+	}})
+	require.Error(t, err)
+	for _, want := range []string{`"No limit"`, `"No category"`, `"Limit alone"`, `"Unknown"`} {
+		assert.Contains(t, err.Error(), want)
+	}
+}
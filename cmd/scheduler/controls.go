@@ -0,0 +1,243 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// ErrScheduleNotFound is returned by control operations for unknown schedule names.
+var ErrScheduleNotFound = errors.New("schedule not found")
+
+// ErrOverrideNotSupported is returned for time overrides of recurring schedules,
+// which have no single trigger to move.
+var ErrOverrideNotSupported = errors.New("time override not supported for recurring schedules")
+
+// Override replaces today's trigger of a single schedule, either with a
+// different time or by skipping it. It expires on its own after that day.
+type Override struct {
+	Date string    `json:"date"` // day the override applies to, YYYY-MM-DD in the trigger's timezone
+	Time time.Time `json:"time"`
+	Skip bool      `json:"skip,omitempty"`
+}
+
+// controls holds runtime state changed through the HTTP API. It is keyed by
+// schedule name and category so it survives a schedule file reload.
+type controls struct {
+	pausedSchedules  map[string]bool
+	pausedCategories map[string]bool
+	overrides        map[string]Override
+}
+
+func newControls() controls {
+	return controls{
+		pausedSchedules:  make(map[string]bool),
+		pausedCategories: make(map[string]bool),
+		overrides:        make(map[string]Override),
+	}
+}
+
+// findSchedule returns the schedule with the given name. Caller must hold s.mu.
+func (s *Scheduler) findSchedule(name string) *DailySchedule {
+	for _, sch := range s.schedules {
+		if sch.Name == name {
+			return sch
+		}
+	}
+	return nil
+}
+
+// SetSchedulePaused pauses or resumes a single schedule.
+func (s *Scheduler) SetSchedulePaused(name string, paused bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.findSchedule(name) == nil {
+		return ErrScheduleNotFound
+	}
+	if paused {
+		s.controls.pausedSchedules[name] = true
+	} else {
+		delete(s.controls.pausedSchedules, name)
+	}
+	s.notify()
+	log.Info().Str("event", "schedule_paused").Str("name", name).Bool("paused", paused).Msg("schedule pause state changed")
+	return nil
+}
+
+// SetCategoryPaused pauses or resumes every schedule in a category.
+func (s *Scheduler) SetCategoryPaused(category string, paused bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	found := false
+	for _, sch := range s.schedules {
+		if sch.Category == category {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return fmt.Errorf("category %q: %w", category, ErrScheduleNotFound)
+	}
+	if paused {
+		s.controls.pausedCategories[category] = true
+	} else {
+		delete(s.controls.pausedCategories, category)
+	}
+	s.notify()
+	log.Info().Str("event", "category_paused").Str("category", category).Bool("paused", paused).Msg("category pause state changed")
+	return nil
+}
+
+// SetOverride adds a one-off override for today's trigger of a schedule. When
+// skip is false the trigger moves to hour:minute on the same day.
+func (s *Scheduler) SetOverride(name string, hour, minute int, skip bool) (Override, error) {
+	s.mu.Lock()
+	sch := s.findSchedule(name)
+	s.mu.Unlock()
+	if sch == nil {
+		return Override{}, ErrScheduleNotFound
+	}
+	if sch.Trigger.Recurrence != nil && !skip {
+		return Override{}, ErrOverrideNotSupported
+	}
+	t := sch.Trigger.Time()
+	o := Override{Date: t.Format("2006-01-02"), Skip: skip}
+	if !skip {
+		o.Time = time.Date(t.Year(), t.Month(), t.Day(), hour, minute, 0, 0, t.Location())
+	}
+	s.mu.Lock()
+	s.controls.overrides[name] = o
+	s.mu.Unlock()
+	s.notify()
+	log.Info().Str("event", "schedule_override").Str("name", name).Str("date", o.Date).Time("time", o.Time).Bool("skip", o.Skip).Msg("override added")
+	return o, nil
+}
+
+// ClearOverride removes a pending override.
+func (s *Scheduler) ClearOverride(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.findSchedule(name) == nil {
+		return ErrScheduleNotFound
+	}
+	delete(s.controls.overrides, name)
+	s.notify()
+	return nil
+}
+
+// RunNow executes a schedule's action immediately, outside its trigger time.
+// LastTriggered is not touched so the regular trigger still runs.
+func (s *Scheduler) RunNow(ctx context.Context, name string) error {
+	s.mu.RLock()
+	sch := s.findSchedule(name)
+	s.mu.RUnlock()
+	if sch == nil {
+		return ErrScheduleNotFound
+	}
+	log.Info().Str("event", "schedule_run_now").Str("name", name).Msg("running schedule action on request")
+	err := sch.Action(ctx)
+	if err != nil {
+		s.setLastError(sch, err.Error())
+	} else {
+		s.setLastError(sch, "")
+	}
+	return err
+}
+
+// activeOverride returns the override applying to the schedule's current trigger day.
+func (s *Scheduler) activeOverride(sch *DailySchedule, t time.Time) (Override, bool) {
+	s.mu.RLock()
+	o, ok := s.controls.overrides[sch.Name]
+	s.mu.RUnlock()
+	if !ok || o.Date != t.Format("2006-01-02") {
+		return Override{}, false
+	}
+	return o, true
+}
+
+// triggerTime returns the schedule's trigger for the current day, honouring
+// a time override.
+func (s *Scheduler) triggerTime(sch *DailySchedule) time.Time {
+	t := sch.Trigger.Time()
+	if o, ok := s.activeOverride(sch, t); ok && !o.Skip {
+		return o.Time
+	}
+	return t
+}
+
+// blockedReason reports why a due schedule must not run: paused, or skipped
+// by an override or its catch-up policy. Empty when nothing blocks it.
+func (s *Scheduler) blockedReason(sch *DailySchedule) string {
+	s.mu.RLock()
+	pausedSchedule := s.controls.pausedSchedules[sch.Name]
+	pausedCategory := sch.Category != "" && s.controls.pausedCategories[sch.Category]
+	s.mu.RUnlock()
+	switch {
+	case pausedSchedule:
+		return "paused"
+	case pausedCategory:
+		return "category_paused"
+	}
+	if o, ok := s.activeOverride(sch, sch.Trigger.Time()); ok && o.Skip {
+		return "skipped_by_override"
+	}
+	if s.missedSkipped(sch) {
+		return "missed_trigger_skipped"
+	}
+	return ""
+}
+
+// nextTrigger returns when the schedule is due next. A trigger time in the past
+// means the schedule is due but has not run yet (e.g. its filters fail); a
+// failed action is due at its next retry. Once today's trigger is done or
+// given up, tomorrow's is estimated at the same wall clock time.
+func (s *Scheduler) nextTrigger(sch *DailySchedule, now time.Time) time.Time {
+	if w := sch.Trigger.Window; w != nil {
+		s.mu.RLock()
+		plan := sch.Plan
+		s.mu.RUnlock()
+		start, end := w.windowAt(now)
+		if plan != nil && plan.WindowStart.Equal(start) {
+			if next := plan.nextBoundary(now); !next.IsZero() {
+				return next
+			}
+			start, _ = w.windowAt(end)
+		}
+		return start
+	}
+	t := sch.Trigger.Time()
+	o, hasOverride := s.activeOverride(sch, t)
+	s.mu.RLock()
+	triggered := hasTriggeredThisPeriod(sch, now)
+	s.mu.RUnlock()
+	// Given up attempts wait for the next trigger like a successful one
+	retry := s.retryFor(sch, s.triggerTime(sch))
+	triggered = triggered || retry.outcome == RetryGaveUp
+	backoff := retry.outcome == RetryPending && retry.nextAttempt.After(now)
+	if rec := sch.Trigger.Recurrence; rec != nil {
+		switch {
+		case hasOverride && o.Skip:
+			// A skip covers the rest of the day
+			y, m, d := t.In(sch.Trigger.Location).Date()
+			return rec.Next(time.Date(y, m, d+1, 0, 0, 0, 0, sch.Trigger.Location).Add(-time.Nanosecond))
+		case triggered:
+			return rec.Next(now)
+		case backoff:
+			return retry.nextAttempt
+		}
+		return t
+	}
+	if triggered || (hasOverride && o.Skip) {
+		return t.AddDate(0, 0, 1)
+	}
+	if backoff {
+		return retry.nextAttempt
+	}
+	if hasOverride {
+		return o.Time
+	}
+	return t
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cronSearchDays bounds the search for an occurrence, enough for "Feb 29".
+const cronSearchDays = 366 * 8
+
+var cronMacros = map[string]string{
+	"@hourly":  "0 * * * *",
+	"@daily":   "0 0 * * *",
+	"@weekly":  "0 0 * * sun",
+	"@monthly": "0 0 1 * *",
+}
+
+var (
+	cronMonthNames = map[string]int{"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12}
+	cronDayNames = map[string]int{"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6}
+)
+
+// cronRecurrence is a standard five field cron expression (minute hour
+// day-of-month month day-of-week) evaluated in a timezone. As in cron, a day
+// matches either day field when both are restricted.
+type cronRecurrence struct {
+	expr     string
+	minutes  [60]bool
+	hours    [24]bool
+	monthDay [32]bool
+	months   [13]bool
+	weekDay  [7]bool
+	// anyMonthDay and anyWeekDay are set for fields starting with "*"
+	anyMonthDay, anyWeekDay bool
+	loc                     *time.Location
+}
+
+// parseCron parses expr, e.g. "*/15 16-21 * * mon-fri" or "@daily".
+func parseCron(expr string, loc *time.Location) (*cronRecurrence, error) {
+	c := &cronRecurrence{expr: expr, loc: loc}
+	spec := strings.ToLower(strings.TrimSpace(expr))
+	if macro, ok := cronMacros[spec]; ok {
+		spec = macro
+	}
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("invalid cron %q (want minute hour day month weekday)", expr)
+	}
+	for i, f := range []struct {
+		name     string
+		set      []bool
+		min, max int
+		names    map[string]int
+	}{
+		{"minute", c.minutes[:], 0, 59, nil},
+		{"hour", c.hours[:], 0, 23, nil},
+		{"day", c.monthDay[:], 1, 31, nil},
+		{"month", c.months[:], 1, 12, cronMonthNames},
+		{"weekday", nil, 0, 7, cronDayNames}, // 7 is also Sunday
+	} {
+		set := f.set
+		if set == nil {
+			set = make([]bool, 8)
+		}
+		if err := parseCronField(fields[i], set, f.min, f.max, f.names); err != nil {
+			return nil, fmt.Errorf("invalid cron %q: %s: %w", expr, f.name, err)
+		}
+		if f.set == nil {
+			copy(c.weekDay[:], set)
+			c.weekDay[0] = c.weekDay[0] || set[7]
+		}
+	}
+	c.anyMonthDay = strings.HasPrefix(fields[2], "*")
+	c.anyWeekDay = strings.HasPrefix(fields[4], "*")
+	return c, nil
+}
+
+// parseCronField sets the values of a comma separated list of values, ranges
+// ("1-5") and steps ("*/15", "8-18/2") in set.
+func parseCronField(field string, set []bool, min, max int, names map[string]int) error {
+	value := func(s string) (int, error) {
+		if v, ok := names[s]; ok {
+			return v, nil
+		}
+		v, err := strconv.Atoi(s)
+		if err != nil || v < min || v > max {
+			return 0, fmt.Errorf("invalid value %q", s)
+		}
+		return v, nil
+	}
+	for _, part := range strings.Split(field, ",") {
+		rng, stepStr, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			var err error
+			if step, err = strconv.Atoi(stepStr); err != nil || step < 1 {
+				return fmt.Errorf("invalid step %q", stepStr)
+			}
+		}
+		lo, hi := min, max
+		if rng != "*" {
+			from, to, isRange := strings.Cut(rng, "-")
+			var err error
+			if lo, err = value(from); err != nil {
+				return err
+			}
+			hi = lo
+			if isRange {
+				if hi, err = value(to); err != nil {
+					return err
+				}
+			} else if hasStep {
+				hi = max // "5/10" means from 5 on
+			}
+			if hi < lo {
+				return fmt.Errorf("invalid range %q", rng)
+			}
+		}
+		for v := lo; v <= hi; v += step {
+			set[v] = true
+		}
+	}
+	return nil
+}
+
+func (c *cronRecurrence) dayMatches(year int, month time.Month, day int) bool {
+	d := time.Date(year, month, day, 0, 0, 0, 0, c.loc)
+	if !c.months[d.Month()] {
+		return false
+	}
+	inMonth, inWeek := c.monthDay[d.Day()], c.weekDay[d.Weekday()]
+	if c.anyMonthDay || c.anyWeekDay {
+		return inMonth && inWeek
+	}
+	return inMonth || inWeek
+}
+
+func (c *cronRecurrence) Prev(t time.Time) time.Time {
+	local := t.In(c.loc)
+	for i := 0; i < cronSearchDays; i++ {
+		y, m, d := local.AddDate(0, 0, -i).Date()
+		if !c.dayMatches(y, m, d) {
+			continue
+		}
+		for h := 23; h >= 0; h-- {
+			for minute := 59; c.hours[h] && minute >= 0; minute-- {
+				if c.minutes[minute] {
+					if occ := time.Date(y, m, d, h, minute, 0, 0, c.loc); !occ.After(t) {
+						return occ
+					}
+				}
+			}
+		}
+	}
+	return time.Time{}
+}
+
+func (c *cronRecurrence) Next(t time.Time) time.Time {
+	local := t.In(c.loc)
+	for i := 0; i < cronSearchDays; i++ {
+		y, m, d := local.AddDate(0, 0, i).Date()
+		if !c.dayMatches(y, m, d) {
+			continue
+		}
+		for h := 0; h < 24; h++ {
+			for minute := 0; c.hours[h] && minute < 60; minute++ {
+				if c.minutes[minute] {
+					if occ := time.Date(y, m, d, h, minute, 0, 0, c.loc); occ.After(t) {
+						return occ
+					}
+				}
+			}
+		}
+	}
+	return time.Time{}
+}
+
+func (c *cronRecurrence) String() string {
+	return "cron " + c.expr
+}
@@ -2,10 +2,14 @@ package main
 
 import (
 	"context"
+	"fmt"
+	"regexp"
 	"runtime/debug"
 	"sync"
 	"time"
 
+	"github.com/mikahozz/gohome/integrations/fmi"
+	"github.com/mikahozz/gohome/integrations/notify"
 	"github.com/rs/zerolog/log"
 )
 
@@ -29,7 +33,12 @@ func (rc *RealClock) After(d time.Duration) <-chan time.Time {
 type FilterType string
 
 const (
-	FilterDate FilterType = "date"
+	FilterDate      FilterType = "date"
+	FilterCalendar  FilterType = "calendar"
+	FilterWeekday   FilterType = "weekday"
+	FilterDateRange FilterType = "date_range"
+	FilterHoliday   FilterType = "holiday"
+	FilterWeather   FilterType = "weather"
 )
 
 type AndOrType string
@@ -41,6 +50,14 @@ const (
 
 type Trigger struct {
 	Time func() time.Time
+	// Window makes the schedule follow the cheapest slots of a daily price
+	// window instead of triggering once at Time.
+	Window *PriceWindow
+	// Recurrence makes the schedule trigger at each of its occurrences
+	// instead of once a day; Time returns the current one. Days are taken in
+	// Location.
+	Recurrence Recurrence
+	Location   *time.Location
 }
 
 type Comparator string
@@ -55,6 +72,28 @@ type Filter struct {
 	Type       FilterType
 	Date       time.Time
 	Comparator Comparator
+	// Calendar filters: events whose summary matches Pattern on the day
+	// are looked up and Event tells whether one must exist.
+	Pattern *regexp.Regexp
+	Event   CalendarEventMode
+	// Weekday filters pass on the days set, indexed by time.Weekday.
+	Weekdays [7]bool
+	// Date range filters pass from From to To (inclusive) every year.
+	From, To MonthDay
+	// Holiday filters pass on Finnish holidays, only official ones with
+	// OfficialOnly.
+	OfficialOnly bool
+	// Weather filters compare Measure (e.g. "temperature") from Source
+	// against Threshold with Comparator; forecasts look Within ahead.
+	Source    WeatherSource
+	Measure   string
+	Threshold float64
+	Within    time.Duration
+	// Negate inverts the result, e.g. a negated holiday filter passes on
+	// working days.
+	Negate bool
+	// Location is the timezone the day is determined in (default: now's).
+	Location *time.Location
 }
 
 type DailySchedule struct {
@@ -64,7 +103,21 @@ type DailySchedule struct {
 	FilterLogic   AndOrType
 	Filters       []Filter
 	Action        func(context.Context) error
+	OffAction     func(context.Context) error // price window schedules only
 	LastTriggered time.Time
+	// Outcome of the latest evaluation, exposed through the HTTP API.
+	LastError      string
+	LastSkipReason string
+	Plan           *PricePlan // price window schedules only
+	// CatchUp decides about triggers missed while the scheduler was down;
+	// MissedBy is the limit for SkipIfMissedBy.
+	CatchUp  CatchUpPolicy
+	MissedBy time.Duration
+	// Retry decides how a failed action is retried.
+	Retry RetryPolicy
+
+	switched switchState
+	retry    retryState
 }
 
 type Scheduler struct {
@@ -74,6 +127,18 @@ type Scheduler struct {
 	cancel    context.CancelFunc
 	wg        sync.WaitGroup
 	clock     Clock
+	wake      chan struct{} // signalled when schedules or controls change
+	store     StateStore
+	restored  map[string]time.Time
+	controls  controls
+	prices    PriceProvider
+	calendar  *calendarCache
+	weather   *weatherCache
+	notifier  notify.Notifier
+	// lastEval is the previous evaluation, missed holds the triggers skipped
+	// by catch-up policies by schedule name.
+	lastEval time.Time
+	missed   map[string]time.Time
 }
 
 // NewScheduler creates a new scheduler instance with real clock
@@ -89,6 +154,9 @@ func NewSchedulerWithClock(clock Clock) *Scheduler {
 		ctx:       ctx,
 		cancel:    cancel,
 		clock:     clock,
+		wake:      make(chan struct{}, 1),
+		controls:  newControls(),
+		missed:    make(map[string]time.Time),
 	}
 }
 
@@ -96,14 +164,107 @@ func NewSchedulerWithClock(clock Clock) *Scheduler {
 func (s *Scheduler) AddSchedule(schedule *DailySchedule) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	s.restoreState(schedule)
 	s.schedules = append(s.schedules, schedule)
 	s.logScheduleAdded(schedule)
+	s.notify()
+}
+
+// notify wakes the run loop to recompute when the next schedule is due.
+func (s *Scheduler) notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// ReplaceSchedules swaps the registered schedules for a new set. LastTriggered,
+// the last error and the retry state are carried over from schedules with the
+// same name, so a reload neither re-runs actions that already fired today nor
+// cancels a pending retry.
+func (s *Scheduler) ReplaceSchedules(schedules []*DailySchedule) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	previous := make(map[string]*DailySchedule, len(s.schedules))
+	for _, sch := range s.schedules {
+		previous[sch.Name] = sch
+	}
+	for _, sch := range schedules {
+		prev, ok := previous[sch.Name]
+		if ok && sch.LastTriggered.IsZero() {
+			sch.LastTriggered = prev.LastTriggered
+		}
+		if ok {
+			sch.LastError = prev.LastError
+			sch.retry = prev.retry
+		}
+		// Keep following an unchanged price window's plan and device state
+		if ok && sch.Trigger.Window != nil && prev.Trigger.Window != nil && *sch.Trigger.Window == *prev.Trigger.Window {
+			sch.Plan = prev.Plan
+			sch.switched = prev.switched
+		}
+		s.restoreState(sch)
+		s.logScheduleAdded(sch)
+	}
+	s.schedules = schedules
+	s.notify()
+}
+
+// UseStateStore loads persisted trigger times from store, applies them to
+// registered schedules and records every future successful trigger in it.
+func (s *Scheduler) UseStateStore(ctx context.Context, store StateStore) error {
+	state, err := store.Load(ctx)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.store = store
+	s.restored = state
+	for _, sch := range s.schedules {
+		s.restoreState(sch)
+	}
+	s.notify()
+	return nil
+}
+
+// restoreState applies a persisted trigger time to schedule. Caller must hold s.mu.
+func (s *Scheduler) restoreState(schedule *DailySchedule) {
+	last, ok := s.restored[schedule.Name]
+	if !ok || !last.After(schedule.LastTriggered) {
+		return
+	}
+	schedule.LastTriggered = last
+	log.Info().Str("event", "schedule_state_restored").Str("name", schedule.Name).Time("last_triggered", last).Msg("restored last trigger time")
+}
+
+// recordTrigger persists a successful trigger when a state store is configured.
+func (s *Scheduler) recordTrigger(schedule *DailySchedule, at time.Time) {
+	s.mu.RLock()
+	store := s.store
+	s.mu.RUnlock()
+	if store == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := store.RecordTrigger(ctx, schedule, at); err != nil {
+		log.Error().Err(err).Str("event", "state_store_error").Str("schedule", schedule.Name).Msg("failed to persist trigger")
+	}
 }
 
 // Start begins running the scheduler
 func (s *Scheduler) Start() {
 	s.wg.Add(1)
 	s.logStart()
+	if s.calendar != nil {
+		// Load events before the first calendar filter needs them
+		s.calendar.Events(s.clock.Now())
+	}
+	if s.weather != nil {
+		s.weather.Data(fmi.Observations, s.clock.Now())
+		s.weather.Data(fmi.Forecast, s.clock.Now())
+	}
 	go s.run()
 }
 
@@ -113,35 +274,105 @@ func (s *Scheduler) Stop() {
 	s.wg.Wait()
 }
 
-// run is the main scheduler loop
+const (
+	// maxSleep bounds the wait between evaluations so failed actions and
+	// filters are retried and price plans refreshed.
+	maxSleep = time.Minute
+	// clockCheckInterval is how often a wait checks for wall clock jumps.
+	clockCheckInterval = 10 * time.Second
+	// clockJumpTolerance is the largest wall clock change not treated as a jump.
+	clockJumpTolerance = 2 * time.Second
+)
+
+// run is the main scheduler loop. It sleeps until the next schedule is due
+// and evaluates the schedules then, or earlier when they change.
 func (s *Scheduler) run() {
 	defer s.wg.Done()
 
 	for {
-		select {
-		case <-s.ctx.Done():
+		now := s.clock.Now()
+		if !s.sleep(now, s.nextWait(now)) {
 			s.logStop()
 			return
-		case <-s.clock.After(1 * time.Minute):
-			s.evaluate(s.clock.Now())
 		}
+		s.evaluate(s.clock.Now())
 	}
 }
 
+// nextWait returns the time until the next schedule is due, at most maxSleep.
+// Schedules already due but not run (failing actions, filters) are retried
+// after maxSleep.
+func (s *Scheduler) nextWait(now time.Time) time.Duration {
+	s.mu.RLock()
+	schedules := append([]*DailySchedule(nil), s.schedules...)
+	s.mu.RUnlock()
+	wait := maxSleep
+	for _, sch := range schedules {
+		if next := s.nextTrigger(sch, now); next.After(now) && next.Sub(now) < wait {
+			wait = next.Sub(now)
+		}
+	}
+	return wait
+}
+
+// sleep waits d from start. It wakes early when schedules change or the wall
+// clock jumps (NTP step, resume from suspend), which timers do not follow.
+// Returns false when the scheduler stops.
+func (s *Scheduler) sleep(start time.Time, d time.Duration) bool {
+	for remaining := d; remaining > 0; remaining = d - s.clock.Now().Sub(start) {
+		select {
+		case <-s.ctx.Done():
+			return false
+		case <-s.wake:
+			return true
+		case <-s.clock.After(min(remaining, clockCheckInterval)):
+		}
+		if jump := clockJump(start, s.clock.Now()); jump > clockJumpTolerance || jump < -clockJumpTolerance {
+			log.Warn().Str("event", "clock_jump").Dur("jump", jump).Msg("wall clock changed, re-evaluating schedules")
+			return true
+		}
+	}
+	return true
+}
+
+// clockJump returns how much more the wall clock moved between from and to
+// than the monotonic clock. Zero for times without monotonic readings.
+func clockJump(from, to time.Time) time.Duration {
+	return to.Round(0).Sub(from.Round(0)) - to.Sub(from)
+}
+
 // evaluate checks all schedules and executes matching ones
 func (s *Scheduler) evaluate(now time.Time) {
 	s.mu.RLock()
-	schedules := make([]*DailySchedule, len(s.schedules))
-	copy(schedules, s.schedules)
+	schedules := make([]*DailySchedule, 0, len(s.schedules))
+	var priced []*DailySchedule
+	for _, sch := range s.schedules {
+		if sch.Trigger.Window != nil {
+			priced = append(priced, sch)
+		} else {
+			schedules = append(schedules, sch)
+		}
+	}
 	s.mu.RUnlock()
 
+	// Price window schedules follow their plan and take no part in categories.
+	for _, sch := range priced {
+		s.evaluatePriceSchedule(sch, now)
+	}
+	s.decideMissed(schedules, now)
+	defer func() {
+		s.mu.Lock()
+		s.lastEval = now
+		s.mu.Unlock()
+	}()
+
 	// Track, per category, the latest trigger time that has already fired today.
 	triggeredMax := make(map[string]time.Time)
 	for _, sch := range schedules {
 		if sch.Category == "" || sch.LastTriggered.IsZero() || !hasTriggeredThisPeriod(sch, now) {
 			continue
 		}
-		t := sch.Trigger.Time()
+		t := s.triggerTime(sch)
 		if prev, ok := triggeredMax[sch.Category]; !ok || t.After(prev) {
 			triggeredMax[sch.Category] = t
 		}
@@ -151,15 +382,24 @@ func (s *Scheduler) evaluate(now time.Time) {
 	candidates := make(map[string]*DailySchedule)
 	candidateTime := make(map[string]time.Time)
 	eligible := make(map[*DailySchedule]bool)
+	blocked := make(map[*DailySchedule]string)
 	for _, sch := range schedules {
 		// Basic eligibility (time reached & not triggered today)
 		if !s.shouldTrigger(sch, now) {
 			continue
 		}
+		if reason := s.blockedReason(sch); reason != "" {
+			blocked[sch] = reason
+			continue
+		}
+		if reason := s.retryBlocked(sch, s.triggerTime(sch), now); reason != "" {
+			blocked[sch] = reason
+			continue
+		}
 		if !s.filtersPass(sch, now) {
 			continue
 		}
-		t := sch.Trigger.Time()
+		t := s.triggerTime(sch)
 		cat := sch.Category
 		eligible[sch] = true
 		// If a schedule already fired today in this category with time >= t, suppress (we only allow later times).
@@ -187,43 +427,92 @@ func (s *Scheduler) evaluate(now time.Time) {
 		isWinner := candidates[catKey] == sch && eligible[sch]
 		if isWinner {
 			s.logScheduleTrigger(sch, now)
-			go func(sch *DailySchedule) {
-				start := s.clock.Now()
-				s.logActionStart(sch, start)
-				defer func() {
-					if r := recover(); r != nil {
-						s.logActionPanic(sch, r)
-					}
-				}()
-				if err := sch.Action(s.ctx); err != nil {
-					log.Error().Err(err).Str("event", "action_error").Str("schedule", sch.Name).Msg("action failed; will retry next cycle")
-				} else {
-					s.mu.Lock()
-					sch.LastTriggered = now
-					s.mu.Unlock()
-					s.logActionFinish(sch, start)
-				}
-			}(sch)
+			s.setSkipReason(sch, "")
+			trigger := s.triggerTime(sch)
+			go s.runAction(sch, now, trigger, s.startAttempt(sch, trigger))
 			continue
 		}
 		// Derive skip reason
 		reason := "trigger_time_not_reached"
+		var failed []string
 		if hasTriggeredThisPeriod(sch, now) {
 			reason = "already_triggered_today"
+		} else if blocked[sch] != "" {
+			reason = blocked[sch]
 		} else if eligible[sch] { // eligible but not winner
 			reason = "superseded_by_later_schedule"
 		} else if s.shouldTrigger(sch, now) && sch.Category != "" {
 			// suppressed due to later already triggered
-			t := sch.Trigger.Time()
+			t := s.triggerTime(sch)
 			if firedT, ok := triggeredMax[sch.Category]; ok && (t.Before(firedT) || t.Equal(firedT)) {
 				reason = "earlier_than_triggered_later_schedule"
 			}
 		} else if s.shouldTrigger(sch, now) && !s.filtersPass(sch, now) {
 			reason = "filters_not_passed"
+			failed = s.failedFilters(sch, now)
+		}
+		s.setSkipReason(sch, reason)
+		s.logScheduleSkip(sch, now, s.triggerTime(sch), reason, failed)
+	}
+}
+
+// runAction executes attempt number attempt at a schedule's trigger and
+// records the outcome. On failure LastTriggered stays unset so the schedule is
+// retried as its retry policy allows.
+func (s *Scheduler) runAction(sch *DailySchedule, now, trigger time.Time, attempt int) {
+	start := s.clock.Now()
+	s.logActionStart(sch, start)
+	ctx, cancel := s.attemptContext(sch, trigger)
+	defer cancel()
+	var err error
+	func() {
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("panic: %v", r)
+				s.logActionPanic(sch, r)
+			}
+		}()
+		err = sch.Action(ctx)
+	}()
+	// The schedule file may have been reloaded while the action ran
+	sch = s.current(sch)
+	if err != nil {
+		s.setLastError(sch, err.Error())
+		s.attemptFailed(sch, trigger, attempt, err)
+		return
+	}
+	s.attemptSucceeded(sch)
+	s.mu.Lock()
+	sch.LastTriggered = now
+	sch.LastError = ""
+	s.mu.Unlock()
+	s.recordTrigger(sch, now)
+	s.logActionFinish(sch, start)
+}
+
+// current returns the registered schedule with sch's name, sch itself when it
+// was removed.
+func (s *Scheduler) current(sch *DailySchedule) *DailySchedule {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, c := range s.schedules {
+		if c.Name == sch.Name {
+			return c
 		}
-		triggerT := sch.Trigger.Time()
-		s.logScheduleSkip(sch, now, triggerT, reason)
 	}
+	return sch
+}
+
+func (s *Scheduler) setSkipReason(sch *DailySchedule, reason string) {
+	s.mu.Lock()
+	sch.LastSkipReason = reason
+	s.mu.Unlock()
+}
+
+func (s *Scheduler) setLastError(sch *DailySchedule, msg string) {
+	s.mu.Lock()
+	sch.LastError = msg
+	s.mu.Unlock()
 }
 
 // shouldTrigger checks if the trigger condition is met
@@ -231,16 +520,26 @@ func (s *Scheduler) shouldTrigger(schedule *DailySchedule, now time.Time) bool {
 	if hasTriggeredThisPeriod(schedule, now) {
 		return false
 	}
-	t := schedule.Trigger.Time()
+	t := s.triggerTime(schedule)
+	if t.IsZero() {
+		// No trigger today, e.g. sunset during the polar day
+		return false
+	}
 	// Compare absolute instants instead of naive hour/minute fields which break across timezones.
 	// Trigger when now >= t.
 	return !now.Before(t)
 }
 
+// hasTriggeredThisPeriod reports whether the schedule already ran for today's
+// trigger or, with a recurrence, for its latest occurrence.
 func hasTriggeredThisPeriod(schedule *DailySchedule, now time.Time) bool {
 	if schedule.LastTriggered.IsZero() {
 		return false
 	}
+	if rec := schedule.Trigger.Recurrence; rec != nil {
+		occ := occurrence(rec, now, schedule.Trigger.Location)
+		return !occ.IsZero() && !occ.After(now) && !schedule.LastTriggered.Before(occ)
+	}
 	return schedule.LastTriggered.Year() == now.Year() &&
 		schedule.LastTriggered.Month() == now.Month() &&
 		schedule.LastTriggered.Day() == now.Day()
@@ -272,22 +571,66 @@ func (s *Scheduler) filtersPass(schedule *DailySchedule, now time.Time) bool {
 
 // filterPass checks if a single filter passes
 func (s *Scheduler) filterPass(filter Filter, now time.Time) bool {
+	pass, _ := s.filterResult(filter, now)
+	return pass
+}
+
+// failedFilters describes the filters of schedule that do not pass.
+func (s *Scheduler) failedFilters(schedule *DailySchedule, now time.Time) []string {
+	var failed []string
+	for _, filter := range schedule.Filters {
+		if pass, detail := s.filterResult(filter, now); !pass {
+			failed = append(failed, detail)
+		}
+	}
+	return failed
+}
+
+// filterResult evaluates a filter and describes what it was decided on.
+func (s *Scheduler) filterResult(filter Filter, now time.Time) (bool, string) {
+	pass, detail := s.filterMatches(filter, now)
+	if detail == "" {
+		detail = string(filter.Type)
+	}
+	if filter.Negate {
+		return !pass, "not " + detail
+	}
+	return pass, detail
+}
+
+// filterMatches evaluates a filter before negation. Filters that compare a
+// value return a description of it.
+func (s *Scheduler) filterMatches(filter Filter, now time.Time) (bool, string) {
+	day := now
+	if filter.Location != nil {
+		day = now.In(filter.Location)
+	}
 	switch filter.Type {
 	case FilterDate:
 		switch filter.Comparator {
 		case Equal:
 			return now.Year() == filter.Date.Year() &&
 				now.Month() == filter.Date.Month() &&
-				now.Day() == filter.Date.Day()
+				now.Day() == filter.Date.Day(), ""
 		case LessThan:
-			return now.Before(filter.Date)
+			return now.Before(filter.Date), ""
 		case GreaterThan:
-			return now.After(filter.Date)
+			return now.After(filter.Date), ""
 		default:
 			log.Info().Msg("No filter matched for: " + filter.Date.String())
 		}
+	case FilterCalendar:
+		return s.calendarFilterPass(filter, now), ""
+	case FilterWeekday:
+		return filter.Weekdays[day.Weekday()], ""
+	case FilterDateRange:
+		return inDateRange(day, filter.From, filter.To), ""
+	case FilterHoliday:
+		return isHoliday(day, filter.OfficialOnly), ""
+	case FilterWeather:
+		return s.weatherFilterPass(filter, now)
 	}
-	return true
+	return true, ""
 }
 
 // --- Logging helpers (centralized formatting) ---
@@ -297,6 +640,9 @@ func (s *Scheduler) logScheduleAdded(schedule *DailySchedule) {
 	if schedule.Category != "" {
 		evt = evt.Str("category", schedule.Category)
 	}
+	if schedule.Trigger.Recurrence != nil {
+		evt = evt.Str("recurrence", schedule.Trigger.Recurrence.String())
+	}
 	evt.Msg("schedule registered")
 }
 
@@ -323,11 +669,14 @@ func (s *Scheduler) logScheduleTrigger(schedule *DailySchedule, now time.Time) {
 	evt.Msg("executing schedule action")
 }
 
-func (s *Scheduler) logScheduleSkip(schedule *DailySchedule, now, triggerT time.Time, reason string) {
+func (s *Scheduler) logScheduleSkip(schedule *DailySchedule, now, triggerT time.Time, reason string, failedFilters []string) {
 	evt := log.Debug().Str("event", "schedule_skip").Str("name", schedule.Name).Str("reason", reason).Time("now", now).Time("trigger_time", triggerT).Time("last_triggered", schedule.LastTriggered)
 	if schedule.Category != "" {
 		evt = evt.Str("category", schedule.Category)
 	}
+	if len(failedFilters) > 0 {
+		evt = evt.Strs("failed_filters", failedFilters)
+	}
 	evt.Msg("schedule not executed")
 }
 
@@ -342,4 +691,5 @@ func (s *Scheduler) logActionFinish(schedule *DailySchedule, start time.Time) {
 
 func (s *Scheduler) logActionPanic(schedule *DailySchedule, r interface{}) {
 	log.Error().Str("event", "action_panic").Str("schedule", schedule.Name).Interface("panic", r).Bytes("stack", debug.Stack()).Msg("schedule action panic")
+	s.alertPanic(schedule, r)
 }
@@ -97,3 +97,71 @@ func TestScheduler_Integration_NextDayReset(t *testing.T) {
 	assert.Equal(t, int32(2), off)
 	assert.Equal(t, int32(2), on)
 }
+
+// advanceSeconds moves the clock one second at a time, letting the scheduler
+// loop go back to sleep in between.
+func advanceSeconds(fc *FakeClock, seconds int) {
+	for i := 0; i < seconds; i++ {
+		fc.WaitForTimers(1)
+		fc.Advance(time.Second)
+	}
+	fc.WaitForTimers(1)
+}
+
+func TestScheduler_Integration_FiresOnTime(t *testing.T) {
+	start := time.Date(2025, 11, 3, 7, 28, 0, 0, zone)
+	fc := NewFakeClock(start)
+	trigger := time.Date(2025, 11, 3, 7, 30, 20, 0, zone) // e.g. sunrise, not on a whole minute
+	fired := make(chan time.Time, 1)
+	s := NewSchedulerWithClock(fc)
+	s.AddSchedule(&DailySchedule{
+		Name:    "Sunrise",
+		Trigger: Trigger{Time: func() time.Time { return trigger }},
+		Action:  func(ctx context.Context) error { fired <- fc.Now(); return nil },
+	})
+	s.Start()
+	defer s.Stop()
+
+	advanceSeconds(fc, 139) // 07:30:19
+	assert.Empty(t, fired)
+	advanceSeconds(fc, 1)
+	select {
+	case at := <-fired:
+		assert.Equal(t, trigger, at, "fired at the trigger time, not on the next minute")
+	case <-time.After(time.Second):
+		t.Fatal("action did not fire at 07:30:20")
+	}
+}
+
+func TestScheduler_Integration_WakesForAddedSchedule(t *testing.T) {
+	start := time.Date(2025, 11, 3, 10, 0, 0, 0, zone)
+	fc := NewFakeClock(start)
+	s := NewSchedulerWithClock(fc)
+	s.Start()
+	defer s.Stop()
+	fc.WaitForTimers(1) // asleep for maxSleep with nothing scheduled
+
+	var calls int32
+	s.AddSchedule(&DailySchedule{
+		Name:    "Soon",
+		Trigger: Trigger{Time: func() time.Time { return start.Add(5 * time.Second) }},
+		Action:  func(ctx context.Context) error { atomic.AddInt32(&calls, 1); return nil },
+	})
+	fc.WaitForTimers(2) // the previous timer stays pending in the fake clock
+	fc.Advance(5 * time.Second)
+	assert.Eventually(t, func() bool { return atomic.LoadInt32(&calls) == 1 }, time.Second, time.Millisecond)
+}
+
+func TestScheduler_NextWait(t *testing.T) {
+	now := time.Date(2025, 11, 3, 7, 29, 30, 0, zone)
+	s := NewSchedulerWithClock(NewFakeClock(now))
+	assert.Equal(t, maxSleep, s.nextWait(now), "nothing scheduled")
+	s.AddSchedule(&DailySchedule{Name: "Due", Trigger: Trigger{Time: func() time.Time { return now.Add(-time.Hour) }}})
+	assert.Equal(t, maxSleep, s.nextWait(now), "a due schedule is retried after maxSleep")
+	s.AddSchedule(&DailySchedule{Name: "Next", Trigger: Trigger{Time: func() time.Time { return now.Add(20 * time.Second) }}})
+	assert.Equal(t, 20*time.Second, s.nextWait(now))
+
+	assert.Zero(t, clockJump(now, now.Add(time.Hour)), "fake times have no monotonic reading")
+	wall := time.Now()
+	assert.Zero(t, clockJump(wall, wall.Add(time.Hour)))
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mikahozz/gohome/integrations/holidays"
+)
+
+// MonthDay is a day of the year without the year, e.g. October 1st.
+type MonthDay struct {
+	Month time.Month
+	Day   int
+}
+
+func (md MonthDay) before(other MonthDay) bool {
+	return md.Month < other.Month || (md.Month == other.Month && md.Day < other.Day)
+}
+
+func (md MonthDay) String() string {
+	return fmt.Sprintf("%02d-%02d", md.Month, md.Day)
+}
+
+var weekdayNames = map[string][]time.Weekday{
+	"mon":      {time.Monday},
+	"tue":      {time.Tuesday},
+	"wed":      {time.Wednesday},
+	"thu":      {time.Thursday},
+	"fri":      {time.Friday},
+	"sat":      {time.Saturday},
+	"sun":      {time.Sunday},
+	"weekdays": {time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday},
+	"weekend":  {time.Saturday, time.Sunday},
+}
+
+// parseWeekdays parses day names ("mon".."sun", also full names) and the
+// groups "weekdays" and "weekend".
+func parseWeekdays(names []string) ([7]bool, error) {
+	var days [7]bool
+	if len(names) == 0 {
+		return days, fmt.Errorf("days is required")
+	}
+	for _, name := range names {
+		key := strings.ToLower(strings.TrimSpace(name))
+		if len(key) > 3 && key != "weekdays" && key != "weekend" {
+			key = key[:3] // "monday" -> "mon"
+		}
+		wds, ok := weekdayNames[key]
+		if !ok {
+			return days, fmt.Errorf("invalid day %q (want mon..sun, weekdays or weekend)", name)
+		}
+		for _, wd := range wds {
+			days[wd] = true
+		}
+	}
+	return days, nil
+}
+
+// parseMonthDay parses "MM-DD", or "MM" meaning the first (end=false) or last
+// (end=true) day of the month.
+func parseMonthDay(s string, end bool) (MonthDay, error) {
+	parts := strings.Split(strings.TrimSpace(s), "-")
+	month, err := strconv.Atoi(parts[0])
+	if err != nil || month < 1 || month > 12 || len(parts) > 2 {
+		return MonthDay{}, fmt.Errorf("invalid date %q (want MM-DD or MM)", s)
+	}
+	md := MonthDay{Month: time.Month(month), Day: 1}
+	if len(parts) == 1 {
+		if end {
+			// Day 0 of the next month is the last day; a leap year covers Feb 29
+			md.Day = time.Date(2024, md.Month+1, 0, 0, 0, 0, 0, time.UTC).Day()
+		}
+		return md, nil
+	}
+	day, err := strconv.Atoi(parts[1])
+	maxDay := time.Date(2024, md.Month+1, 0, 0, 0, 0, 0, time.UTC).Day()
+	if err != nil || day < 1 || day > maxDay {
+		return MonthDay{}, fmt.Errorf("invalid date %q (want MM-DD or MM)", s)
+	}
+	md.Day = day
+	return md, nil
+}
+
+// inDateRange reports whether the day of t is within from..to (inclusive).
+// A range whose end is before its start wraps over the new year (Oct-Mar).
+func inDateRange(t time.Time, from, to MonthDay) bool {
+	day := MonthDay{Month: t.Month(), Day: t.Day()}
+	if to.before(from) {
+		return !day.before(from) || !to.before(day)
+	}
+	return !day.before(from) && !to.before(day)
+}
+
+// isHoliday reports whether the day of t is a Finnish holiday. With
+// officialOnly the eves that are only days off in practice do not count.
+func isHoliday(t time.Time, officialOnly bool) bool {
+	h, ok := holidays.Lookup(t)
+	return ok && (h.Official || !officialOnly)
+}
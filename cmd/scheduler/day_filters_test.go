@@ -0,0 +1,123 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseWeekdays(t *testing.T) {
+	days, err := parseWeekdays([]string{"Weekend", "friday"})
+	require.NoError(t, err)
+	assert.Equal(t, [7]bool{true, false, false, false, false, true, true}, days)
+
+	days, err = parseWeekdays([]string{"weekdays"})
+	require.NoError(t, err)
+	assert.False(t, days[time.Sunday])
+	assert.True(t, days[time.Wednesday])
+
+	_, err = parseWeekdays([]string{"someday"})
+	assert.Error(t, err)
+	_, err = parseWeekdays(nil)
+	assert.Error(t, err)
+}
+
+func TestInDateRange(t *testing.T) {
+	day := func(m time.Month, d int) time.Time { return time.Date(2025, m, d, 12, 0, 0, 0, zone) }
+	from, err := parseMonthDay("10", false)
+	require.NoError(t, err)
+	to, err := parseMonthDay("03", true)
+	require.NoError(t, err)
+	assert.Equal(t, "03-31", to.String())
+
+	assert.True(t, inDateRange(day(time.October, 1), from, to))
+	assert.True(t, inDateRange(day(time.January, 15), from, to), "wraps over new year")
+	assert.True(t, inDateRange(day(time.March, 31), from, to))
+	assert.False(t, inDateRange(day(time.April, 1), from, to))
+	assert.False(t, inDateRange(day(time.September, 30), from, to))
+
+	from, _ = parseMonthDay("06-01", false)
+	to, _ = parseMonthDay("08-15", true)
+	assert.True(t, inDateRange(day(time.August, 15), from, to))
+	assert.False(t, inDateRange(day(time.August, 16), from, to))
+	assert.False(t, inDateRange(day(time.May, 31), from, to))
+
+	for _, bad := range []string{"", "13", "02-30", "1-2-3", "x-01"} {
+		_, err := parseMonthDay(bad, false)
+		assert.Error(t, err, bad)
+	}
+	feb, err := parseMonthDay("02", true)
+	require.NoError(t, err)
+	assert.Equal(t, 29, feb.Day, "leap day is included")
+}
+
+func TestScheduleConfig_DayFilters(t *testing.T) {
+	b := testBuilder(time.Date(2025, 10, 17, 12, 0, 0, 0, zone))
+	path := writeFile(t, "schedules.yaml", `
+schedules:
+  - name: Morning lights
+    trigger: "06:45"
+    action: shelly.on
+    filters:
+      - {type: weekday, days: [weekdays]}
+      - {type: date_range, from: "10", to: "03"}
+      - {type: holiday, negate: true}
+  - name: Weekend or holiday
+    trigger: "09:00"
+    action: shelly.on
+    filter_logic: or
+    filters:
+      - {type: weekday, days: [weekend]}
+      - {type: holiday, official_only: true}
+`)
+	schedules, err := b.LoadScheduleFile(path)
+	require.NoError(t, err)
+	require.Len(t, schedules[0].Filters, 3)
+	assert.Equal(t, FilterDateRange, schedules[0].Filters[1].Type)
+	assert.True(t, schedules[0].Filters[2].Negate)
+	assert.True(t, schedules[1].Filters[1].OfficialOnly)
+
+	s := NewSchedulerWithClock(NewFakeClock(time.Date(2025, 10, 17, 6, 45, 0, 0, zone)))
+	at := func(y int, m time.Month, d int) time.Time { return time.Date(y, m, d, 6, 45, 0, 0, zone) }
+	for _, tc := range []struct {
+		name     string
+		schedule *DailySchedule
+		now      time.Time
+		want     bool
+	}{
+		{"working day in winter", schedules[0], at(2025, time.October, 17), true},
+		{"saturday", schedules[0], at(2025, time.October, 18), false},
+		{"summer", schedules[0], at(2025, time.June, 17), false},
+		{"monday after independence day", schedules[0], at(2025, time.December, 8), true},
+		{"holiday on a weekday", schedules[0], at(2026, time.January, 6), false},
+		{"weekend", schedules[1], at(2025, time.October, 18), true},
+		{"official holiday", schedules[1], at(2026, time.January, 6), true},
+		{"christmas eve is not official", schedules[1], at(2025, time.December, 24), false},
+		{"working day", schedules[1], at(2025, time.October, 17), false},
+	} {
+		assert.Equal(t, tc.want, s.filtersPass(tc.schedule, tc.now), tc.name)
+	}
+
+	// The local day decides, not UTC
+	lateUTC := time.Date(2025, 12, 5, 22, 30, 0, 0, time.UTC) // 00:30 on Dec 6 in Helsinki
+	assert.True(t, s.filterPass(Filter{Type: FilterHoliday, Location: zone}, lateUTC))
+
+	path = writeFile(t, "bad.yaml", `
+schedules:
+  - name: No days
+    trigger: "06:45"
+    action: shelly.on
+    filters: [{type: weekday}]
+  - name: Bad range
+    trigger: "06:45"
+    action: shelly.on
+    filters: [{type: date_range, from: "10-32", to: "03"}]
+`)
+	_, err = b.LoadScheduleFile(path)
+	require.Error(t, err)
+	for _, want := range []string{`"No days"`, `"Bad range"`} {
+		assert.Contains(t, err.Error(), want)
+	}
+}
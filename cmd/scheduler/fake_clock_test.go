@@ -68,3 +68,19 @@ func (fc *FakeClock) Advance(d time.Duration) {
 	fc.timers = remaining
 	fc.mu.Unlock()
 }
+
+// WaitForTimers blocks until at least n timers are pending, i.e. the
+// scheduler loop is asleep, so a following Advance is not missed.
+func (fc *FakeClock) WaitForTimers(n int) {
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		fc.mu.Lock()
+		pending := len(fc.timers)
+		fc.mu.Unlock()
+		if pending >= n {
+			return
+		}
+		time.Sleep(100 * time.Microsecond)
+	}
+	panic("timed out waiting for the scheduler to sleep")
+}
@@ -2,25 +2,27 @@ package main
 
 import (
 	"context"
+	"flag"
+	"fmt"
 	"os"
 	"time"
 
+	"github.com/mikahozz/gohome/config"
+	"github.com/mikahozz/gohome/db"
+	"github.com/mikahozz/gohome/integrations/fmi"
+	"github.com/mikahozz/gohome/integrations/notify"
+	"github.com/mikahozz/gohome/integrations/scenes"
 	"github.com/mikahozz/gohome/integrations/shelly"
+	"github.com/mikahozz/gohome/integrations/spot"
 	"github.com/mikahozz/gohome/integrations/sun"
 	"github.com/rs/zerolog/log"
 )
 
 var zone, _ = time.LoadLocation("Europe/Helsinki")
 
-var sunDataInstance *sun.SunData
-
-func init() {
-	var err error
-	sunDataInstance, err = sun.NewSunData()
-	if err != nil {
-		log.Fatal().Err(err).Msg("Failed to load sun data")
-	}
-}
+// sunDataInstance is replaced in main with a calculator for the configured
+// coordinates.
+var sunDataInstance sun.Provider = sun.NewCalculator(sun.HelsinkiLatitude, sun.HelsinkiLongitude, zone)
 
 func getSunriseTimeToday() time.Time {
 	now := time.Now().In(zone) // Get current time in Helsinki timezone
@@ -34,6 +36,93 @@ func getSunsetTimeToday() time.Time {
 	return data.Sunset
 }
 
+// newStateStore returns the PostgreSQL store for "postgres" and a file store
+// for anything else. The process exits if the file location is not writable.
+func newStateStore(state string) StateStore {
+	if state != "postgres" {
+		store := NewFileStateStore(state)
+		if err := store.CheckWritable(); err != nil {
+			log.Fatal().Err(err).Str("state", state).Msg("Scheduler state location is not writable")
+		}
+		return store
+	}
+	conn, err := db.Open()
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to connect to database for scheduler state")
+	}
+	return NewPostgresStateStore(conn)
+}
+
+// shellyAction returns a factory for "shelly.on(<device>)" style actions;
+// plain "shelly.on" switches the "default" device. Unknown device names are
+// rejected when the schedule file is loaded.
+func shellyAction(on bool) ActionFactory {
+	return func(device string) (func(context.Context) error, error) {
+		if device == "" {
+			device = shelly.DefaultDeviceName
+		}
+		registry, err := shelly.Default()
+		if err != nil {
+			return nil, err
+		}
+		if !registry.Has(device) {
+			return nil, fmt.Errorf("%w %q", shelly.ErrUnknownDevice, device)
+		}
+		return func(ctx context.Context) error {
+			return registry.Set(ctx, device, on)
+		}, nil
+	}
+}
+
+// sceneAction is the factory of "scene.activate(<scene>)" actions. Unknown
+// scene names are rejected when the schedule file is loaded.
+func sceneAction(scene string) (func(context.Context) error, error) {
+	registry, err := scenes.Default()
+	if err != nil {
+		return nil, err
+	}
+	if !registry.Has(scene) {
+		return nil, fmt.Errorf("%w %q", scenes.ErrUnknownScene, scene)
+	}
+	return func(ctx context.Context) error {
+		_, err := registry.Activate(ctx, scene)
+		return err
+	}, nil
+}
+
+// livePrices fetches prices straight from ENTSO-E on every plan.
+type livePrices struct {
+	source *spot.SpotService
+}
+
+func (l livePrices) GetPrices(ctx context.Context, start, end time.Time, location *time.Location) (*spot.SpotPriceList, error) {
+	return l.source.GetSpotPrices(start.In(location), end.In(location))
+}
+
+// newPriceProvider returns the price source for "cheapest" schedules: ENTSO-E
+// directly for "live", or the price table (backfilled from ENTSO-E) for
+// "postgres". Returns nil when no source is available.
+func newPriceProvider(prices string) PriceProvider {
+	source, err := spot.NewSpotServiceFromEnv()
+	if err != nil {
+		log.Warn().Err(err).Msg("ENTSO-E prices not available")
+	}
+	if prices != "postgres" {
+		if source == nil {
+			return nil
+		}
+		return livePrices{source: source}
+	}
+	conn, err := db.Open()
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to connect to database for spot prices")
+	}
+	if source == nil {
+		return spot.NewPriceRepository(conn, nil)
+	}
+	return spot.NewPriceRepository(conn, source)
+}
+
 func main() {
 	defer func() {
 		if r := recover(); r != nil {
@@ -44,48 +133,53 @@ func main() {
 			os.Exit(1)
 		}
 	}()
+	// Flag defaults below are read from the environment
+	config.LoadEnv()
 
-	scheduler := NewScheduler()
-	scheduler.AddSchedule(&DailySchedule{
-		Name:     "Night lights ON at sunset",
-		Category: "night_lights",
-		Trigger: Trigger{
-			Time: getSunsetTimeToday,
-		},
-		Action: func(ctx context.Context) error { return shelly.TurnOn(ctx) },
-	})
-	scheduler.AddSchedule(&DailySchedule{
-		Name:     "Night lights OFF at 23:00",
-		Category: "night_lights",
-		Trigger: Trigger{
-			Time: func() time.Time {
-				now := time.Now().In(zone) // Get current time in Helsinki timezone
-				return time.Date(now.Year(), now.Month(), now.Day(), 23, 0, 0, 0, zone)
-			},
-		},
-		Action: func(ctx context.Context) error { return shelly.TurnOff(ctx) },
-	})
-
-	scheduler.AddSchedule(&DailySchedule{
-		Name:     "Morning lights ON at 6:45",
-		Category: "night_lights",
-		Trigger: Trigger{
-			Time: func() time.Time {
-				now := time.Now().In(zone) // Get current time in Helsinki timezone
-				return time.Date(now.Year(), now.Month(), now.Day(), 6, 45, 0, 0, zone)
-			},
-		},
-		Action: func(ctx context.Context) error { return shelly.TurnOn(ctx) },
-	})
-	scheduler.AddSchedule(&DailySchedule{
-		Name:     "Morning lights OFF at sunrise",
-		Category: "night_lights",
-		Trigger: Trigger{
-			Time: getSunriseTimeToday,
+	configPath := flag.String("config", "schedules.yaml", "Path to the schedule config file (YAML or JSON)")
+	statePath := flag.String("state", "data/scheduler_state.json", `Where trigger state is persisted: a JSON file path or "postgres"`)
+	weatherStation := flag.String("weather-station", string(fmi.ConfiguredStation()), "FMI station (fmisid) observed by weather filters (default from FMI_STATION)")
+	weatherPlace := flag.String("weather-place", fmi.ConfiguredPlace(), "FMI forecast place used by weather filters (default from FMI_PLACE)")
+	pricesSource := flag.String("prices", "live", `Spot price source for "cheapest" schedules: "live" (ENTSO-E) or "postgres"`)
+	flag.Parse()
+
+	latitude, longitude, err := sun.ConfiguredCoordinates()
+	if err != nil {
+		log.Fatal().Err(err).Msg("Invalid sun coordinates")
+	}
+	sunDataInstance = sun.NewCalculator(latitude, longitude, zone)
+
+	builder := &ScheduleBuilder{
+		Factories: map[string]ActionFactory{
+			"shelly.on":      shellyAction(true),
+			"shelly.off":     shellyAction(false),
+			"scene.activate": sceneAction,
 		},
-		Action: func(ctx context.Context) error { return shelly.TurnOff(ctx) },
-	})
+		Sun: sunDataInstance,
+	}
+	schedules, err := builder.LoadScheduleFile(*configPath)
+	if err != nil {
+		log.Fatal().Err(err).Str("path", *configPath).Msg("Failed to load schedules")
+	}
+
+	scheduler := NewScheduler()
+	scheduler.UsePrices(newPriceProvider(*pricesSource))
+	scheduler.UseCalendar(familyCalendar)
+	scheduler.UseWeather(fmiWeather(*weatherStation, *weatherPlace))
+	notifier, err := notify.FromEnv()
+	if err != nil {
+		log.Fatal().Err(err).Msg("Invalid notification settings")
+	}
+	scheduler.UseNotifier(notifier)
+	if err := scheduler.UseStateStore(context.Background(), newStateStore(*statePath)); err != nil {
+		log.Fatal().Err(err).Str("state", *statePath).Msg("Failed to load scheduler state")
+	}
+	for _, sch := range schedules {
+		scheduler.AddSchedule(sch)
+	}
+	scheduler.WatchScheduleFile(*configPath, builder.LoadScheduleFile, 30*time.Second)
 	scheduler.Start()
+	scheduler.StartAPI(apiPort)
 	defer scheduler.Stop()
 
 	// Keep the main function running
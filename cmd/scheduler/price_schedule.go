@@ -0,0 +1,389 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/mikahozz/gohome/integrations/notify"
+	"github.com/mikahozz/gohome/integrations/spot"
+	"github.com/rs/zerolog/log"
+)
+
+// planRetryInterval is how often an incomplete plan is recomputed while
+// waiting for the day-ahead prices of the window to be published.
+const planRetryInterval = 15 * time.Minute
+
+// PriceProvider returns spot prices between start and end (inclusive).
+// *spot.PriceRepository implements it.
+type PriceProvider interface {
+	GetPrices(ctx context.Context, start, end time.Time, location *time.Location) (*spot.SpotPriceList, error)
+}
+
+// PriceWindow makes a schedule follow electricity prices: the schedule's
+// Action runs when one of the cheapest slots of the daily window begins and
+// its OffAction when the slots end, e.g. "charge 3h between 18:00 and 07:00".
+type PriceWindow struct {
+	Start time.Duration // time of day the window opens, as offset from midnight
+	End   time.Duration // time of day it closes; End <= Start ends the next day
+	// Duration is the total on time; it is rounded up to whole slots.
+	Duration   time.Duration
+	Slot       time.Duration // 1h or 15m
+	Contiguous bool
+	Location   *time.Location
+}
+
+// PlannedSlot is a slot the schedule is switched on for.
+type PlannedSlot struct {
+	Start     time.Time `json:"start"`
+	End       time.Time `json:"end"`
+	PriceCkwh float64   `json:"price"`
+}
+
+// PricePlan is the selection of slots for one window.
+type PricePlan struct {
+	WindowStart time.Time     `json:"window_start"`
+	WindowEnd   time.Time     `json:"window_end"`
+	Slots       []PlannedSlot `json:"slots"`
+	// Complete is false while some prices of the window are not published yet.
+	Complete  bool      `json:"complete"`
+	PlannedAt time.Time `json:"planned_at"`
+}
+
+// switchState is the last state a price schedule switched its device to.
+type switchState int
+
+const (
+	switchUnknown switchState = iota
+	switchOn
+	switchOff
+)
+
+// UsePrices sets the price source for price window schedules.
+func (s *Scheduler) UsePrices(p PriceProvider) {
+	s.mu.Lock()
+	s.prices = p
+	s.mu.Unlock()
+}
+
+// bounds returns the window opening on day (midnight in w.Location).
+func (w *PriceWindow) bounds(day time.Time) (time.Time, time.Time) {
+	y, m, d := day.Date()
+	start := wallClock(y, m, d, w.Start, w.Location)
+	end := wallClock(y, m, d, w.End, w.Location)
+	if !end.After(start) {
+		end = wallClock(y, m, d+1, w.End, w.Location)
+	}
+	return start, end
+}
+
+// windowAt returns the window that is open at now, or the next one to open.
+func (w *PriceWindow) windowAt(now time.Time) (time.Time, time.Time) {
+	local := now.In(w.Location)
+	today := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, w.Location)
+	for _, day := range []time.Time{today.AddDate(0, 0, -1), today} {
+		if start, end := w.bounds(day); now.Before(end) {
+			return start, end
+		}
+	}
+	return w.bounds(today.AddDate(0, 0, 1))
+}
+
+// slotCount returns the number of slots needed to cover Duration.
+func (w *PriceWindow) slotCount() int {
+	return int(math.Ceil(float64(w.Duration) / float64(w.Slot)))
+}
+
+// slotPrices returns the priced slots between start and end. A slot costs the
+// average of the prices inside it, or the price of the period containing it
+// when prices have a coarser resolution than the slot.
+func slotPrices(prices []spot.SpotPrice, start, end time.Time, slot time.Duration) []PlannedSlot {
+	var slots []PlannedSlot
+	for t := start; t.Before(end); t = t.Add(slot) {
+		slotEnd := t.Add(slot)
+		sum, n := 0.0, 0
+		var before *spot.SpotPrice
+		for i := range prices {
+			p := &prices[i]
+			if !p.DateTime.Before(t) && p.DateTime.Before(slotEnd) {
+				sum += p.PriceCkwh
+				n++
+			}
+			if !p.DateTime.After(t) && t.Sub(p.DateTime) < time.Hour {
+				before = p
+			}
+		}
+		switch {
+		case n > 0:
+			slots = append(slots, PlannedSlot{Start: t, End: slotEnd, PriceCkwh: sum / float64(n)})
+		case before != nil:
+			slots = append(slots, PlannedSlot{Start: t, End: slotEnd, PriceCkwh: before.PriceCkwh})
+		}
+	}
+	return slots
+}
+
+// plan selects the cheapest slots of the window [start, end). Slots of the
+// previous plan for the same window that have already started are kept so a
+// replan never extends the total on time.
+func (w *PriceWindow) plan(prices []spot.SpotPrice, start, end, now time.Time, previous *PricePlan) *PricePlan {
+	candidates := slotPrices(prices, start, end, w.Slot)
+	plan := &PricePlan{
+		WindowStart: start,
+		WindowEnd:   end,
+		Complete:    len(candidates) == int(end.Sub(start)/w.Slot),
+		PlannedAt:   now,
+	}
+
+	var kept []PlannedSlot
+	if previous != nil && previous.WindowStart.Equal(start) {
+		for _, sl := range previous.Slots {
+			if !sl.Start.After(now) {
+				kept = append(kept, sl)
+			}
+		}
+		if w.Contiguous && len(kept) > 0 {
+			plan.Slots = previous.Slots
+			return plan
+		}
+	}
+
+	needed := w.slotCount() - len(kept)
+	var future []PlannedSlot
+	for _, sl := range candidates {
+		if sl.End.After(now) && !containsSlot(kept, sl.Start) {
+			future = append(future, sl)
+		}
+	}
+
+	var picked []PlannedSlot
+	if w.Contiguous {
+		picked = cheapestRun(future, needed, w.Slot)
+	} else {
+		picked = cheapestSlots(future, needed)
+	}
+	plan.Slots = append(kept, picked...)
+	sort.Slice(plan.Slots, func(i, j int) bool { return plan.Slots[i].Start.Before(plan.Slots[j].Start) })
+	return plan
+}
+
+func containsSlot(slots []PlannedSlot, start time.Time) bool {
+	for _, sl := range slots {
+		if sl.Start.Equal(start) {
+			return true
+		}
+	}
+	return false
+}
+
+// cheapestSlots returns the n cheapest slots; on equal price the earlier wins.
+func cheapestSlots(slots []PlannedSlot, n int) []PlannedSlot {
+	if n <= 0 {
+		return nil
+	}
+	sorted := make([]PlannedSlot, len(slots))
+	copy(sorted, slots)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].PriceCkwh < sorted[j].PriceCkwh })
+	if n > len(sorted) {
+		n = len(sorted)
+	}
+	return sorted[:n]
+}
+
+// cheapestRun returns the n consecutive slots with the lowest total price.
+// If there is no run of n slots the longest cheapest run available is used.
+func cheapestRun(slots []PlannedSlot, n int, slot time.Duration) []PlannedSlot {
+	for ; n > 0; n-- {
+		best, bestSum := -1, math.Inf(1)
+		for i := 0; i+n <= len(slots); i++ {
+			if !slots[i+n-1].Start.Equal(slots[i].Start.Add(time.Duration(n-1) * slot)) {
+				continue // gap in prices
+			}
+			sum := 0.0
+			for _, sl := range slots[i : i+n] {
+				sum += sl.PriceCkwh
+			}
+			if sum < bestSum {
+				best, bestSum = i, sum
+			}
+		}
+		if best >= 0 {
+			return slots[best : best+n]
+		}
+	}
+	return nil
+}
+
+// active reports whether now falls into a planned slot.
+func (p *PricePlan) active(now time.Time) bool {
+	for _, sl := range p.Slots {
+		if !now.Before(sl.Start) && now.Before(sl.End) {
+			return true
+		}
+	}
+	return false
+}
+
+// nextBoundary returns the next time the plan switches on or off after now.
+// Adjacent slots form a single on period.
+func (p *PricePlan) nextBoundary(now time.Time) time.Time {
+	for i, sl := range p.Slots {
+		if sl.Start.After(now) {
+			return sl.Start
+		}
+		if sl.End.After(now) {
+			end := sl.End
+			for _, next := range p.Slots[i+1:] {
+				if !next.Start.Equal(end) {
+					break
+				}
+				end = next.End
+			}
+			return end
+		}
+	}
+	return time.Time{}
+}
+
+// currentPlan returns the plan for the window open at now (or the next one),
+// recomputing it when the window changed or prices were still missing.
+func (s *Scheduler) currentPlan(sch *DailySchedule, now time.Time) (*PricePlan, error) {
+	w := sch.Trigger.Window
+	start, end := w.windowAt(now)
+
+	s.mu.RLock()
+	previous := sch.Plan
+	prices := s.prices
+	s.mu.RUnlock()
+	if previous != nil && previous.WindowStart.Equal(start) &&
+		(previous.Complete || now.Sub(previous.PlannedAt) < planRetryInterval) {
+		return previous, nil
+	}
+	if prices == nil {
+		return nil, fmt.Errorf("no price source configured")
+	}
+
+	ctx, cancel := context.WithTimeout(s.ctx, 30*time.Second)
+	defer cancel()
+	list, err := prices.GetPrices(ctx, start, end.Add(-time.Nanosecond), w.Location)
+	if err != nil {
+		if previous != nil && previous.WindowStart.Equal(start) {
+			// Keep following the partial plan and retry later
+			s.mu.Lock()
+			previous.PlannedAt = now
+			s.mu.Unlock()
+			return previous, err
+		}
+		return nil, err
+	}
+
+	plan := w.plan(list.Prices, start, end, now, previous)
+	s.mu.Lock()
+	sch.Plan = plan
+	s.mu.Unlock()
+	s.logPricePlan(sch, plan)
+	return plan, nil
+}
+
+// evaluatePriceSchedule switches a price window schedule on or off so its
+// device follows the current plan.
+func (s *Scheduler) evaluatePriceSchedule(sch *DailySchedule, now time.Time) {
+	if reason := s.blockedReason(sch); reason != "" {
+		s.setSkipReason(sch, reason)
+		return
+	}
+	plan, err := s.currentPlan(sch, now)
+	if plan == nil {
+		s.setSkipReason(sch, "no_prices")
+		log.Error().Err(err).Str("event", "price_plan_error").Str("schedule", sch.Name).Msg("cannot plan without prices")
+		s.alert(notify.Message{
+			Title: "No prices: " + sch.Name,
+			Body:  fmt.Sprintf("Cannot plan the cheapest slots: %v", err),
+			Key:   "price_plan_error:" + sch.Name,
+		})
+		return
+	}
+	if err != nil {
+		log.Warn().Err(err).Str("event", "price_plan_error").Str("schedule", sch.Name).Msg("replanning failed; keeping current plan")
+	}
+
+	want := switchOff
+	reason := "outside_cheapest_slots"
+	switch {
+	case !s.filtersPass(sch, now):
+		reason = "filters_not_passed"
+	case plan.active(now):
+		want, reason = switchOn, ""
+	}
+	s.mu.Lock()
+	sch.LastSkipReason = reason
+	if sch.switched == want {
+		s.mu.Unlock()
+		return
+	}
+	sch.switched = want
+	s.mu.Unlock()
+	go s.runSwitch(sch, want, now)
+}
+
+// runSwitch runs the schedule's Action (on) or OffAction (off). On failure the
+// state is reset so the next evaluation retries.
+func (s *Scheduler) runSwitch(sch *DailySchedule, want switchState, now time.Time) {
+	action := sch.Action
+	if want == switchOff {
+		action = sch.OffAction
+	}
+	start := s.clock.Now()
+	s.logActionStart(sch, start)
+	log.Info().Str("event", "price_switch").Str("schedule", sch.Name).Bool("on", want == switchOn).Time("now", now).Msg("switching for price plan")
+
+	var err error
+	func() {
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("panic: %v", r)
+				s.logActionPanic(sch, r)
+			}
+		}()
+		err = action(s.ctx)
+	}()
+	if err != nil {
+		s.mu.Lock()
+		sch.switched = switchUnknown
+		sch.LastError = err.Error()
+		s.mu.Unlock()
+		log.Error().Err(err).Str("event", "action_error").Str("schedule", sch.Name).Msg("action failed; will retry next cycle")
+		s.alert(notify.Message{
+			Title: "Action failed: " + sch.Name,
+			Body:  fmt.Sprintf("%v\nSwitching again on the next evaluation.", err),
+			Key:   "action_error:" + sch.Name,
+		})
+		return
+	}
+	s.mu.Lock()
+	sch.LastTriggered = now
+	sch.LastError = ""
+	s.mu.Unlock()
+	s.recordTrigger(sch, now)
+	s.logActionFinish(sch, start)
+}
+
+func (s *Scheduler) logPricePlan(sch *DailySchedule, plan *PricePlan) {
+	slots := make([]string, len(plan.Slots))
+	total := 0.0
+	for i, sl := range plan.Slots {
+		slots[i] = fmt.Sprintf("%s-%s %.3f", sl.Start.In(sch.Trigger.Window.Location).Format("15:04"),
+			sl.End.In(sch.Trigger.Window.Location).Format("15:04"), sl.PriceCkwh)
+		total += sl.PriceCkwh
+	}
+	avg := 0.0
+	if len(plan.Slots) > 0 {
+		avg = total / float64(len(plan.Slots))
+	}
+	log.Info().Str("event", "price_plan").Str("schedule", sch.Name).
+		Time("window_start", plan.WindowStart).Time("window_end", plan.WindowEnd).
+		Bool("complete", plan.Complete).Strs("slots", slots).Float64("avg_price", avg).
+		Msg("planned cheapest slots")
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mikahozz/gohome/integrations/spot"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakePrices struct {
+	prices []spot.SpotPrice
+	calls  int
+}
+
+func (f *fakePrices) GetPrices(ctx context.Context, start, end time.Time, location *time.Location) (*spot.SpotPriceList, error) {
+	f.calls++
+	var result []spot.SpotPrice
+	for _, p := range f.prices {
+		if !p.DateTime.Before(start) && !p.DateTime.After(end) {
+			result = append(result, p)
+		}
+	}
+	return &spot.SpotPriceList{Prices: result}, nil
+}
+
+// hourly returns hourly prices starting at start.
+func hourly(start time.Time, prices ...float64) []spot.SpotPrice {
+	result := make([]spot.SpotPrice, len(prices))
+	for i, p := range prices {
+		result[i] = spot.SpotPrice{DateTime: start.Add(time.Duration(i) * time.Hour), PriceCkwh: p}
+	}
+	return result
+}
+
+func slotStarts(plan *PricePlan) []string {
+	starts := make([]string, len(plan.Slots))
+	for i, sl := range plan.Slots {
+		starts[i] = sl.Start.In(zone).Format("15:04")
+	}
+	return starts
+}
+
+// overnightPrices covers 18:00-07:00 on 2025-11-08/09.
+var overnightStart = time.Date(2025, 11, 8, 18, 0, 0, 0, zone)
+
+func overnightPrices() []spot.SpotPrice {
+	//                                  18  19  20  21  22  23  00  01  02  03  04  05  06
+	return hourly(overnightStart, 9, 8, 7, 6, 5, 1, 4, 2, 3, 1.5, 6, 7, 8)
+}
+
+func TestPriceWindow_PlanCheapestSlots(t *testing.T) {
+	w := &PriceWindow{Start: 18 * time.Hour, End: 7 * time.Hour, Duration: 3 * time.Hour, Slot: time.Hour, Location: zone}
+	start, end := w.windowAt(time.Date(2025, 11, 8, 14, 0, 0, 0, zone))
+	require.True(t, start.Equal(overnightStart))
+	require.True(t, end.Equal(time.Date(2025, 11, 9, 7, 0, 0, 0, zone)))
+
+	plan := w.plan(overnightPrices(), start, end, start.Add(-4*time.Hour), nil)
+	assert.True(t, plan.Complete)
+	assert.Equal(t, []string{"23:00", "01:00", "03:00"}, slotStarts(plan))
+
+	w.Contiguous = true
+	plan = w.plan(overnightPrices(), start, end, start.Add(-4*time.Hour), nil)
+	assert.Equal(t, []string{"01:00", "02:00", "03:00"}, slotStarts(plan))
+	assert.True(t, plan.nextBoundary(start).Equal(time.Date(2025, 11, 9, 1, 0, 0, 0, zone)))
+	assert.True(t, plan.nextBoundary(time.Date(2025, 11, 9, 1, 30, 0, 0, zone)).Equal(time.Date(2025, 11, 9, 4, 0, 0, 0, zone)))
+}
+
+// TestPriceWindow_DSTDays checks that windows keep their wall clock times on
+// the days the clocks change (Europe/Helsinki: 2025-03-30 and 2025-10-26).
+func TestPriceWindow_DSTDays(t *testing.T) {
+	tests := []struct {
+		name string
+		day  time.Time
+		// overnight is the length of 18:00-07:00 over the night into day.
+		overnight time.Duration
+	}{
+		{"spring forward", time.Date(2025, 3, 30, 0, 0, 0, 0, zone), 12 * time.Hour},
+		{"fall back", time.Date(2025, 10, 26, 0, 0, 0, 0, zone), 14 * time.Hour},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			evening := &PriceWindow{Start: 18 * time.Hour, End: 22 * time.Hour, Duration: time.Hour, Slot: time.Hour, Location: zone}
+			start, end := evening.windowAt(tt.day.Add(12 * time.Hour))
+			assert.True(t, start.Equal(time.Date(2025, tt.day.Month(), tt.day.Day(), 18, 0, 0, 0, zone)), "start %s", start)
+			assert.True(t, end.Equal(time.Date(2025, tt.day.Month(), tt.day.Day(), 22, 0, 0, 0, zone)), "end %s", end)
+
+			overnight := &PriceWindow{Start: 18 * time.Hour, End: 7 * time.Hour, Duration: time.Hour, Slot: time.Hour, Location: zone}
+			start, end = overnight.windowAt(tt.day.Add(-12 * time.Hour))
+			assert.True(t, start.Equal(time.Date(2025, tt.day.Month(), tt.day.Day()-1, 18, 0, 0, 0, zone)), "start %s", start)
+			assert.True(t, end.Equal(time.Date(2025, tt.day.Month(), tt.day.Day(), 7, 0, 0, 0, zone)), "end %s", end)
+			assert.Equal(t, tt.overnight, end.Sub(start))
+		})
+	}
+}
+
+func TestPriceWindow_QuarterHourSlots(t *testing.T) {
+	w := &PriceWindow{Start: 18 * time.Hour, End: 7 * time.Hour, Duration: 45 * time.Minute, Slot: 15 * time.Minute, Location: zone}
+	start, end := w.windowAt(overnightStart)
+	plan := w.plan(overnightPrices(), start, end, start, nil)
+	assert.True(t, plan.Complete, "hourly prices cover quarter-hour slots")
+	assert.Equal(t, []string{"23:00", "23:15", "23:30"}, slotStarts(plan))
+	assert.Equal(t, 1.0, plan.Slots[0].PriceCkwh)
+}
+
+func TestPriceWindow_IncompletePricesAndReplan(t *testing.T) {
+	w := &PriceWindow{Start: 18 * time.Hour, End: 7 * time.Hour, Duration: 3 * time.Hour, Slot: time.Hour, Location: zone}
+	start, end := w.windowAt(overnightStart)
+
+	// Only today's prices are known
+	first := w.plan(overnightPrices()[:6], start, end, start, nil)
+	assert.False(t, first.Complete)
+	assert.Equal(t, []string{"21:00", "22:00", "23:00"}, slotStarts(first))
+
+	// Tomorrow's prices arrive at 21:30; the started 21:00 slot is kept
+	now := time.Date(2025, 11, 8, 21, 30, 0, 0, zone)
+	second := w.plan(overnightPrices(), start, end, now, first)
+	assert.True(t, second.Complete)
+	assert.Equal(t, []string{"21:00", "23:00", "03:00"}, slotStarts(second))
+}
+
+func newPriceTestScheduler(now time.Time, prices PriceProvider) (*Scheduler, *DailySchedule, *int32, *int32) {
+	s := NewSchedulerWithClock(NewFakeClock(now))
+	s.UsePrices(prices)
+	var on, off int32
+	w := &PriceWindow{Start: 18 * time.Hour, End: 7 * time.Hour, Duration: 2 * time.Hour, Slot: time.Hour, Location: zone}
+	sch := &DailySchedule{
+		Name:      "Car charging",
+		Trigger:   Trigger{Time: func() time.Time { st, _ := w.windowAt(now); return st }, Window: w},
+		Action:    func(ctx context.Context) error { atomic.AddInt32(&on, 1); return nil },
+		OffAction: func(ctx context.Context) error { atomic.AddInt32(&off, 1); return nil },
+	}
+	s.AddSchedule(sch)
+	return s, sch, &on, &off
+}
+
+func TestScheduler_PriceWindowSwitching(t *testing.T) {
+	prices := &fakePrices{prices: overnightPrices()}
+	s, sch, on, off := newPriceTestScheduler(overnightStart, prices)
+
+	step := func(at time.Time) {
+		s.evaluate(at)
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	step(overnightStart)
+	assert.Equal(t, int32(0), atomic.LoadInt32(on))
+	assert.Equal(t, int32(1), atomic.LoadInt32(off), "unknown state is switched off first")
+	assert.Equal(t, "outside_cheapest_slots", sch.LastSkipReason)
+
+	step(overnightStart.Add(30 * time.Minute))
+	assert.Equal(t, int32(1), atomic.LoadInt32(off), "no repeated off while state is unchanged")
+
+	step(time.Date(2025, 11, 8, 23, 0, 0, 0, zone))
+	assert.Equal(t, int32(1), atomic.LoadInt32(on))
+	assert.Empty(t, sch.LastSkipReason)
+
+	step(time.Date(2025, 11, 9, 0, 0, 0, 0, zone))
+	assert.Equal(t, int32(2), atomic.LoadInt32(off))
+	step(time.Date(2025, 11, 9, 3, 0, 0, 0, zone))
+	assert.Equal(t, int32(2), atomic.LoadInt32(on))
+	step(time.Date(2025, 11, 9, 4, 0, 0, 0, zone))
+	assert.Equal(t, int32(3), atomic.LoadInt32(off))
+	assert.Equal(t, 1, prices.calls, "a complete plan is not recomputed")
+
+	st := s.Status()
+	require.NotNil(t, st[0].Plan)
+	assert.Len(t, st[0].Plan.Slots, 2)
+	next := s.nextTrigger(sch, time.Date(2025, 11, 9, 4, 30, 0, 0, zone))
+	assert.True(t, next.Equal(time.Date(2025, 11, 9, 18, 0, 0, 0, zone)), "next window after the last slot, got %v", next)
+}
+
+func TestScheduler_PriceWindowWaitsForPrices(t *testing.T) {
+	prices := &fakePrices{prices: overnightPrices()[:4]}
+	s, sch, _, _ := newPriceTestScheduler(overnightStart, prices)
+
+	s.evaluate(overnightStart.Add(-4 * time.Hour))
+	require.NotNil(t, sch.Plan)
+	assert.False(t, sch.Plan.Complete)
+
+	// Within the retry interval the incomplete plan is reused
+	s.evaluate(overnightStart.Add(-4*time.Hour + 5*time.Minute))
+	assert.Equal(t, 1, prices.calls)
+
+	prices.prices = overnightPrices()
+	s.evaluate(overnightStart.Add(-4*time.Hour + planRetryInterval))
+	assert.Equal(t, 2, prices.calls)
+	assert.True(t, sch.Plan.Complete)
+	assert.Equal(t, []string{"23:00", "03:00"}, slotStarts(sch.Plan))
+}
+
+func TestScheduleConfig_Cheapest(t *testing.T) {
+	now := time.Date(2025, 11, 8, 12, 0, 0, 0, zone)
+	b := testBuilder(now)
+	path := writeFile(t, "schedules.yaml", `
+schedules:
+  - name: Car charging
+    cheapest:
+      window: "18:00-07:00"
+      duration: 3h
+      slot: 15m
+      contiguous: true
+    action: shelly.on
+    off_action: shelly.off
+`)
+	schedules, err := b.LoadScheduleFile(path)
+	require.NoError(t, err)
+	w := schedules[0].Trigger.Window
+	require.NotNil(t, w)
+	assert.Equal(t, 18*time.Hour, w.Start)
+	assert.Equal(t, 7*time.Hour, w.End)
+	assert.Equal(t, 15*time.Minute, w.Slot)
+	assert.True(t, w.Contiguous)
+	assert.NotNil(t, schedules[0].OffAction)
+	assert.True(t, schedules[0].Trigger.Time().Equal(time.Date(2025, 11, 8, 18, 0, 0, 0, zone)))
+
+	path = writeFile(t, "bad.yaml", `
+schedules:
+  - name: No off action
+    cheapest: {window: "18:00-07:00", duration: 3h}
+    action: shelly.on
+  - name: Too long
+    cheapest: {window: "22:00-23:00", duration: 3h}
+    action: shelly.on
+    off_action: shelly.off
+  - name: Unaligned
+    cheapest: {window: "18:30-07:00", duration: 1h}
+    action: shelly.on
+    off_action: shelly.off
+  - name: Both triggers
+    trigger: "12:00"
+    cheapest: {window: "18:00-07:00", duration: 1h}
+    action: shelly.on
+    off_action: shelly.off
+`)
+	_, err = b.LoadScheduleFile(path)
+	require.Error(t, err)
+	for _, want := range []string{`"No off action"`, `"Too long"`, `"Unaligned"`, `"Both triggers"`} {
+		assert.Contains(t, err.Error(), want)
+	}
+}
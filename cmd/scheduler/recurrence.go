@@ -0,0 +1,144 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// Recurrence produces the trigger times of a schedule that runs several
+// times a day or only on some days: cron expressions, fixed intervals and
+// weekly triggers.
+type Recurrence interface {
+	// Prev returns the latest occurrence at or before t, zero if none.
+	Prev(t time.Time) time.Time
+	// Next returns the first occurrence after t, zero if none.
+	Next(t time.Time) time.Time
+	String() string
+}
+
+// occurrence returns the recurrence's latest occurrence on the day of now, or
+// the next one when none is due today. Like daily triggers, occurrences of
+// earlier days are not caught up.
+func occurrence(r Recurrence, now time.Time, loc *time.Location) time.Time {
+	if prev := r.Prev(now); !prev.IsZero() && sameDay(prev, now, loc) {
+		return prev
+	}
+	return r.Next(now)
+}
+
+func sameDay(a, b time.Time, loc *time.Location) bool {
+	ay, am, ad := a.In(loc).Date()
+	by, bm, bd := b.In(loc).Date()
+	return ay == by && am == bm && ad == bd
+}
+
+// wallClock returns the time offset after midnight of the given day on the
+// wall clock, so DST changes do not shift it.
+func wallClock(year int, month time.Month, day int, offset time.Duration, loc *time.Location) time.Time {
+	return time.Date(year, month, day, 0, 0, int(offset/time.Second), 0, loc)
+}
+
+// intervalRecurrence triggers every Every from Start to End (inclusive,
+// offsets from midnight) on the days set. A window whose end is before its
+// start runs over midnight and belongs to the day it starts on.
+type intervalRecurrence struct {
+	every      time.Duration
+	start, end time.Duration
+	days       [7]bool
+	loc        *time.Location
+}
+
+// dayOccurrences returns the occurrences of the window starting on the day
+// offset days from t's day.
+func (r *intervalRecurrence) dayOccurrences(t time.Time, offset int) []time.Time {
+	y, m, d := t.In(r.loc).AddDate(0, 0, offset).Date()
+	if !r.days[time.Date(y, m, d, 0, 0, 0, 0, r.loc).Weekday()] {
+		return nil
+	}
+	end := r.end
+	if end < r.start {
+		end += 24 * time.Hour
+	}
+	var occs []time.Time
+	for off := r.start; off <= end; off += r.every {
+		occs = append(occs, wallClock(y, m, d, off, r.loc))
+	}
+	return occs
+}
+
+func (r *intervalRecurrence) Prev(t time.Time) time.Time {
+	for i := 0; i <= 7; i++ {
+		occs := r.dayOccurrences(t, -i)
+		for j := len(occs) - 1; j >= 0; j-- {
+			if !occs[j].After(t) {
+				return occs[j]
+			}
+		}
+	}
+	return time.Time{}
+}
+
+func (r *intervalRecurrence) Next(t time.Time) time.Time {
+	for i := -1; i <= 7; i++ { // yesterday's window may run past midnight
+		for _, occ := range r.dayOccurrences(t, i) {
+			if occ.After(t) {
+				return occ
+			}
+		}
+	}
+	return time.Time{}
+}
+
+func (r *intervalRecurrence) String() string {
+	return fmt.Sprintf("every %s %s-%s", r.every, formatOffset(r.start), formatOffset(r.end))
+}
+
+// weeklyRecurrence triggers once on each of the days set, at the time
+// returned by at for that day (a clock time or a sun event).
+type weeklyRecurrence struct {
+	days [7]bool
+	at   func(day time.Time) time.Time
+	loc  *time.Location
+}
+
+func (r *weeklyRecurrence) dayOccurrence(t time.Time, offset int) (time.Time, bool) {
+	y, m, d := t.In(r.loc).AddDate(0, 0, offset).Date()
+	day := time.Date(y, m, d, 0, 0, 0, 0, r.loc)
+	if !r.days[day.Weekday()] {
+		return time.Time{}, false
+	}
+	occ := r.at(day)
+	return occ, !occ.IsZero()
+}
+
+func (r *weeklyRecurrence) Prev(t time.Time) time.Time {
+	for i := 0; i <= 7; i++ {
+		if occ, ok := r.dayOccurrence(t, -i); ok && !occ.After(t) {
+			return occ
+		}
+	}
+	return time.Time{}
+}
+
+func (r *weeklyRecurrence) Next(t time.Time) time.Time {
+	for i := 0; i <= 7; i++ {
+		if occ, ok := r.dayOccurrence(t, i); ok && occ.After(t) {
+			return occ
+		}
+	}
+	return time.Time{}
+}
+
+func (r *weeklyRecurrence) String() string {
+	days := ""
+	for i := 1; i <= 7; i++ { // Monday first
+		if wd := time.Weekday(i % 7); r.days[wd] {
+			days += wd.String()[:3] + " "
+		}
+	}
+	return "weekly " + days[:len(days)-1]
+}
+
+func formatOffset(d time.Duration) string {
+	return fmt.Sprintf("%02d:%02d", int(d.Hours())%24, int(d.Minutes())%60)
+}
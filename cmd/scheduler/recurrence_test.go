@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func at(day, clock string) time.Time {
+	t, err := time.ParseInLocation("2006-01-02 15:04", day+" "+clock, zone)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func TestCron(t *testing.T) {
+	c, err := parseCron("*/15 16-21 * * mon-fri", zone)
+	require.NoError(t, err)
+	// 2025-10-17 is a Friday
+	assert.Equal(t, at("2025-10-17", "16:00"), c.Next(at("2025-10-17", "12:00")))
+	assert.Equal(t, at("2025-10-17", "16:15"), c.Next(at("2025-10-17", "16:00")))
+	assert.Equal(t, at("2025-10-17", "16:15"), c.Prev(at("2025-10-17", "16:29")))
+	assert.Equal(t, at("2025-10-20", "16:00"), c.Next(at("2025-10-17", "21:45")), "skips the weekend")
+	assert.Equal(t, at("2025-10-17", "21:45"), c.Prev(at("2025-10-19", "12:00")))
+
+	// Both day fields restricted: either matches
+	c, err = parseCron("0 7 1 * sun", zone)
+	require.NoError(t, err)
+	assert.Equal(t, at("2025-10-19", "07:00"), c.Next(at("2025-10-17", "12:00")))
+	assert.Equal(t, at("2025-11-01", "07:00"), c.Next(at("2025-10-26", "12:00")))
+
+	c, err = parseCron("30 6 29 feb *", zone)
+	require.NoError(t, err)
+	assert.Equal(t, at("2028-02-29", "06:30"), c.Next(at("2025-10-17", "12:00")))
+
+	c, err = parseCron("@daily", zone)
+	require.NoError(t, err)
+	assert.Equal(t, at("2025-10-18", "00:00"), c.Next(at("2025-10-17", "12:00")))
+
+	for _, bad := range []string{"* * * *", "60 * * * *", "* 5-1 * * *", "*/0 * * * *", "* * * * funday"} {
+		_, err := parseCron(bad, zone)
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestIntervalRecurrence(t *testing.T) {
+	all := [7]bool{true, true, true, true, true, true, true}
+	r := &intervalRecurrence{every: 15 * time.Minute, start: 16 * time.Hour, end: 22 * time.Hour, days: all, loc: zone}
+	assert.Equal(t, at("2025-10-17", "16:00"), r.Next(at("2025-10-17", "09:00")))
+	assert.Equal(t, at("2025-10-17", "22:00"), r.Prev(at("2025-10-17", "23:30")), "the end is included")
+	assert.Equal(t, at("2025-10-18", "16:00"), r.Next(at("2025-10-17", "22:00")))
+
+	// Over midnight
+	r = &intervalRecurrence{every: time.Hour, start: 22 * time.Hour, end: 2 * time.Hour, days: all, loc: zone}
+	assert.Equal(t, at("2025-10-18", "01:00"), r.Next(at("2025-10-18", "00:30")))
+	assert.Equal(t, at("2025-10-18", "02:00"), r.Prev(at("2025-10-18", "09:00")))
+
+	// Wall clock times are kept over the DST change (2025-10-26 03:00 -> 02:00)
+	r = &intervalRecurrence{every: 6 * time.Hour, start: 0, end: 24*time.Hour - time.Minute, days: all, loc: zone}
+	assert.Equal(t, at("2025-10-26", "06:00"), r.Next(at("2025-10-26", "00:30")))
+}
+
+func TestScheduleConfig_Recurrence(t *testing.T) {
+	b := testBuilder(at("2025-10-17", "12:00"))
+	path := writeFile(t, "schedules.yaml", `
+schedules:
+  - name: Heater pulse
+    every: 15m
+    between: "16:00-22:00"
+    days: [weekdays]
+    action: shelly.on
+  - name: Bins out
+    trigger: "19:00"
+    days: [sun]
+    action: shelly.on
+  - name: Pump
+    cron: "0 */2 * * *"
+    action: shelly.on
+`)
+	schedules, err := b.LoadScheduleFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "every 15m0s 16:00-22:00", schedules[0].Trigger.Recurrence.String())
+	assert.Equal(t, at("2025-10-17", "16:00"), schedules[0].Trigger.Time(), "next occurrence when none is due today")
+	assert.Equal(t, "weekly Sun", schedules[1].Trigger.Recurrence.String())
+	assert.Equal(t, at("2025-10-19", "19:00"), schedules[1].Trigger.Time())
+	assert.Equal(t, at("2025-10-17", "12:00"), schedules[2].Trigger.Time())
+
+	path = writeFile(t, "bad.yaml", `
+schedules:
+  - name: Both
+    trigger: "06:00"
+    cron: "0 6 * * *"
+    action: shelly.on
+  - name: Short interval
+    every: 30s
+    action: shelly.on
+  - name: Between alone
+    trigger: "06:00"
+    between: "16:00-22:00"
+    action: shelly.on
+  - name: Cron days
+    cron: "0 6 * * *"
+    days: [mon]
+    action: shelly.on
+  - name: Bad cron
+    cron: "0 25 * * *"
+    action: shelly.on
+`)
+	_, err = b.LoadScheduleFile(path)
+	require.Error(t, err)
+	for _, want := range []string{`"Both"`, `"Short interval"`, `"Between alone"`, `"Cron days"`, `"Bad cron"`} {
+		assert.Contains(t, err.Error(), want)
+	}
+}
+
+func TestScheduler_IntervalSchedule(t *testing.T) {
+	clock := NewFakeClock(at("2025-10-17", "15:59"))
+	b := testBuilder(clock.Now())
+	b.Now = clock.Now
+	schedules, err := b.Build(ScheduleFile{Schedules: []ScheduleConfig{
+		{Name: "Pulse", Category: "heater", Every: "15m", Between: "16:00-17:00", Action: "shelly.on"},
+		{Name: "Boost", Category: "heater", Trigger: "16:20", Action: "shelly.on"},
+	}})
+	require.NoError(t, err)
+	var mu sync.Mutex
+	var runs []string
+	for _, sch := range schedules {
+		name := sch.Name
+		sch.Action = func(context.Context) error {
+			mu.Lock()
+			runs = append(runs, name+" "+clock.Now().Format("15:04"))
+			mu.Unlock()
+			return nil
+		}
+	}
+	s := NewSchedulerWithClock(clock)
+	for _, sch := range schedules {
+		s.AddSchedule(sch)
+	}
+	step := func(to string) {
+		clock.Advance(at("2025-10-17", to).Sub(clock.Now()))
+		s.evaluate(clock.Now())
+		time.Sleep(20 * time.Millisecond)
+	}
+	for _, to := range []string{"16:00", "16:01", "16:14", "16:15", "16:20", "16:25", "16:30"} {
+		step(to)
+	}
+	mu.Lock()
+	assert.Equal(t, []string{"Pulse 16:00", "Pulse 16:15", "Boost 16:20", "Pulse 16:30"}, runs)
+	mu.Unlock()
+	assert.Equal(t, "already_triggered_today", schedules[1].LastSkipReason)
+	assert.Equal(t, at("2025-10-17", "16:45"), s.nextTrigger(schedules[0], clock.Now()))
+
+	_, err = s.SetOverride("Pulse", 18, 0, false)
+	assert.ErrorIs(t, err, ErrOverrideNotSupported)
+	_, err = s.SetOverride("Pulse", 0, 0, true)
+	require.NoError(t, err)
+	assert.Equal(t, at("2025-10-18", "16:00"), s.nextTrigger(schedules[0], clock.Now()))
+}
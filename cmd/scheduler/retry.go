@@ -0,0 +1,196 @@
+package main
+
+import (
+	"context"
+	"math/rand/v2"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// RetryPolicy decides how a failed action is retried. The zero value retries
+// on every evaluation until the trigger's period ends.
+type RetryPolicy struct {
+	// MaxAttempts is the number of attempts before giving up, 0 for no limit.
+	MaxAttempts int
+	// Backoff is the wait after the first failure, doubled after each
+	// further one up to MaxBackoff. 0 retries on the next evaluation.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// Jitter varies each wait randomly by up to this fraction (0.2 = ±20%).
+	Jitter float64
+	// Deadline is how long after the trigger time attempts are made, 0 for no
+	// limit. A running attempt is cancelled at the deadline.
+	Deadline time.Duration
+}
+
+// defaultMaxBackoff caps the backoff when a policy sets none.
+const defaultMaxBackoff = time.Hour
+
+// jitterRandom returns a number in [0, 1) for jitter.
+var jitterRandom = rand.Float64
+
+// delay returns the wait after the given failed attempt (1 for the first).
+func (p RetryPolicy) delay(attempt int, random float64) time.Duration {
+	if p.Backoff <= 0 {
+		return 0
+	}
+	limit := p.MaxBackoff
+	if limit <= 0 {
+		limit = defaultMaxBackoff
+	}
+	d := p.Backoff
+	for i := 1; i < attempt && d < limit; i++ {
+		d *= 2
+	}
+	d = min(d, limit)
+	return time.Duration(float64(d) * (1 + p.Jitter*(2*random-1)))
+}
+
+// RetryOutcome is the state of the attempts at a trigger.
+type RetryOutcome string
+
+const (
+	RetryRunning   RetryOutcome = "running"
+	RetryPending   RetryOutcome = "retrying"
+	RetrySucceeded RetryOutcome = "succeeded"
+	RetryGaveUp    RetryOutcome = "gave_up"
+)
+
+// retryState tracks the attempts at a schedule's latest trigger. Guarded by
+// Scheduler.mu.
+type retryState struct {
+	trigger     time.Time
+	attempts    int
+	outcome     RetryOutcome
+	nextAttempt time.Time
+	lastError   string
+}
+
+// RetryStatus is the API representation of a schedule's latest attempts.
+type RetryStatus struct {
+	Trigger     time.Time    `json:"trigger"`
+	Attempts    int          `json:"attempts"`
+	Outcome     RetryOutcome `json:"outcome"`
+	NextAttempt *time.Time   `json:"next_attempt,omitempty"`
+	LastError   string       `json:"last_error,omitempty"`
+}
+
+// retryStatus returns the schedule's attempt state, nil before the first
+// attempt. Caller must hold s.mu.
+func retryStatus(sch *DailySchedule) *RetryStatus {
+	r := sch.retry
+	if r.attempts == 0 {
+		return nil
+	}
+	st := &RetryStatus{Trigger: r.trigger, Attempts: r.attempts, Outcome: r.outcome, LastError: r.lastError}
+	if r.outcome == RetryPending && !r.nextAttempt.IsZero() {
+		next := r.nextAttempt
+		st.NextAttempt = &next
+	}
+	return st
+}
+
+// retryFor returns the attempt state of the trigger, zero when there were no
+// attempts at it.
+func (s *Scheduler) retryFor(sch *DailySchedule, trigger time.Time) retryState {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if !sch.retry.trigger.Equal(trigger) {
+		return retryState{}
+	}
+	return sch.retry
+}
+
+// retryBlocked reports why a due trigger must not be attempted now: an
+// attempt still running, a backoff not yet over, or attempts given up.
+// Empty when it may run. Passing the deadline gives up.
+func (s *Scheduler) retryBlocked(sch *DailySchedule, trigger, now time.Time) string {
+	r := s.retryFor(sch, trigger)
+	if r.outcome == RetryPending && sch.Retry.Deadline > 0 && now.After(trigger.Add(sch.Retry.Deadline)) {
+		s.giveUp(sch, trigger)
+		return "retry_gave_up"
+	}
+	switch {
+	case r.outcome == RetryRunning:
+		return "action_running"
+	case r.outcome == RetryGaveUp:
+		return "retry_gave_up"
+	case r.outcome == RetryPending && now.Before(r.nextAttempt):
+		return "retry_backoff"
+	}
+	return ""
+}
+
+// startAttempt records an attempt at the trigger and returns its number.
+func (s *Scheduler) startAttempt(sch *DailySchedule, trigger time.Time) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !sch.retry.trigger.Equal(trigger) {
+		sch.retry = retryState{trigger: trigger}
+	}
+	sch.retry.attempts++
+	sch.retry.outcome = RetryRunning
+	sch.retry.nextAttempt = time.Time{}
+	return sch.retry.attempts
+}
+
+// attemptContext limits an attempt to the policy's deadline.
+func (s *Scheduler) attemptContext(sch *DailySchedule, trigger time.Time) (context.Context, context.CancelFunc) {
+	if sch.Retry.Deadline <= 0 {
+		return context.WithCancel(s.ctx)
+	}
+	return context.WithTimeout(s.ctx, trigger.Add(sch.Retry.Deadline).Sub(s.clock.Now()))
+}
+
+// attemptSucceeded records the successful end of the attempts.
+func (s *Scheduler) attemptSucceeded(sch *DailySchedule) {
+	s.mu.Lock()
+	sch.retry.outcome = RetrySucceeded
+	sch.retry.lastError = ""
+	attempts := sch.retry.attempts
+	s.mu.Unlock()
+	if attempts > 1 {
+		s.alertRecovered(sch, attempts)
+	}
+}
+
+// attemptFailed schedules the next attempt, or gives up when the policy's
+// attempts or deadline are used up.
+func (s *Scheduler) attemptFailed(sch *DailySchedule, trigger time.Time, attempt int, err error) {
+	p := sch.Retry
+	now := s.clock.Now()
+	next := now // without backoff on the next evaluation
+	if d := p.delay(attempt, jitterRandom()); d > 0 {
+		next = now.Add(d)
+	}
+	s.mu.Lock()
+	sch.retry.lastError = err.Error()
+	s.mu.Unlock()
+	if (p.MaxAttempts > 0 && attempt >= p.MaxAttempts) || (p.Deadline > 0 && next.After(trigger.Add(p.Deadline))) {
+		s.giveUp(sch, trigger)
+		return
+	}
+	s.mu.Lock()
+	sch.retry.outcome = RetryPending
+	if next.After(now) {
+		sch.retry.nextAttempt = next
+	}
+	s.mu.Unlock()
+	s.notify()
+	log.Error().Err(err).Str("event", "action_error").Str("schedule", sch.Name).Int("attempt", attempt).
+		Time("next_attempt", next).Msg("action failed; will retry")
+	s.alertActionFailed(sch, attempt, next, err)
+}
+
+// giveUp stops the attempts at the trigger until the next one.
+func (s *Scheduler) giveUp(sch *DailySchedule, trigger time.Time) {
+	s.mu.Lock()
+	sch.retry.outcome = RetryGaveUp
+	sch.retry.nextAttempt = time.Time{}
+	attempts, lastError := sch.retry.attempts, sch.retry.lastError
+	s.mu.Unlock()
+	log.Error().Str("event", "action_gave_up").Str("schedule", sch.Name).Time("trigger_time", trigger).
+		Int("attempts", attempts).Str("last_error", lastError).Msg("action failed; giving up until the next trigger")
+	s.alertGaveUp(sch, trigger, attempts, lastError)
+}
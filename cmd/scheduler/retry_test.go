@@ -0,0 +1,231 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetryPolicy_Delay(t *testing.T) {
+	p := RetryPolicy{Backoff: 30 * time.Second, MaxBackoff: 3 * time.Minute}
+	var got []time.Duration
+	for attempt := 1; attempt <= 5; attempt++ {
+		got = append(got, p.delay(attempt, 0.5))
+	}
+	assert.Equal(t, []time.Duration{30 * time.Second, time.Minute, 2 * time.Minute, 3 * time.Minute, 3 * time.Minute}, got)
+
+	p.Jitter = 0.2
+	assert.Equal(t, 48*time.Second, p.delay(2, 0))
+	assert.Equal(t, 72*time.Second, p.delay(2, 1))
+	assert.Equal(t, time.Hour, RetryPolicy{Backoff: time.Minute}.delay(100, 0.5), "capped at the default maximum")
+	assert.Zero(t, RetryPolicy{}.delay(3, 0.5))
+}
+
+// failingSchedule returns a schedule triggering at 07:00 whose action fails
+// until succeedAt attempts (never when 0).
+func failingSchedule(day time.Time, policy RetryPolicy, succeedAt int) (*DailySchedule, func() int) {
+	var mu sync.Mutex
+	attempts := 0
+	trigger := day.Add(7 * time.Hour)
+	sch := &DailySchedule{
+		Name:    "Heater",
+		Trigger: Trigger{Time: func() time.Time { return trigger }},
+		Retry:   policy,
+		Action: func(context.Context) error {
+			mu.Lock()
+			defer mu.Unlock()
+			attempts++
+			if succeedAt > 0 && attempts >= succeedAt {
+				return nil
+			}
+			return errors.New("device offline")
+		},
+	}
+	return sch, func() int { mu.Lock(); defer mu.Unlock(); return attempts }
+}
+
+func TestScheduler_RetryBackoffAndGiveUp(t *testing.T) {
+	day := time.Date(2025, 11, 3, 0, 0, 0, 0, zone)
+	clock := NewFakeClock(day.Add(7 * time.Hour))
+	s := NewSchedulerWithClock(clock)
+	sch, attempts := failingSchedule(day, RetryPolicy{MaxAttempts: 3, Backoff: time.Minute}, 0)
+	s.AddSchedule(sch)
+	step := func(d time.Duration) {
+		clock.Advance(d)
+		s.evaluate(clock.Now())
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	step(0)
+	assert.Equal(t, 1, attempts())
+	st := s.Status()[0]
+	require.NotNil(t, st.Retry)
+	assert.Equal(t, RetryPending, st.Retry.Outcome)
+	assert.Equal(t, "device offline", st.Retry.LastError)
+	assert.Equal(t, day.Add(7*time.Hour+time.Minute), *st.Retry.NextAttempt)
+	assert.Equal(t, day.Add(7*time.Hour+time.Minute), st.NextTrigger)
+
+	step(30 * time.Second)
+	assert.Equal(t, 1, attempts())
+	assert.Equal(t, "retry_backoff", sch.LastSkipReason)
+
+	step(30 * time.Second) // 07:01, then 2 minutes until the third attempt
+	step(time.Minute)
+	assert.Equal(t, 2, attempts())
+	step(time.Minute)
+	assert.Equal(t, 3, attempts())
+
+	step(time.Hour)
+	assert.Equal(t, 3, attempts(), "no attempts after giving up")
+	assert.Equal(t, "retry_gave_up", sch.LastSkipReason)
+	st = s.Status()[0]
+	assert.Equal(t, RetryGaveUp, st.Retry.Outcome)
+	assert.Equal(t, 3, st.Retry.Attempts)
+	assert.Nil(t, st.Retry.NextAttempt)
+	assert.Equal(t, day.Add(31*time.Hour), st.NextTrigger, "waits for tomorrow's trigger")
+}
+
+func TestScheduler_RetryDeadline(t *testing.T) {
+	day := time.Date(2025, 11, 3, 0, 0, 0, 0, zone)
+	clock := NewFakeClock(day.Add(7 * time.Hour))
+	s := NewSchedulerWithClock(clock)
+	sch, attempts := failingSchedule(day, RetryPolicy{Deadline: 5 * time.Minute}, 0)
+	s.AddSchedule(sch)
+
+	for i := 0; i < 8; i++ {
+		s.evaluate(clock.Now())
+		time.Sleep(20 * time.Millisecond)
+		clock.Advance(time.Minute)
+	}
+	assert.Equal(t, 6, attempts(), "one attempt per evaluation from 07:00 to 07:05")
+	assert.Equal(t, "retry_gave_up", sch.LastSkipReason)
+	assert.Equal(t, RetryGaveUp, s.Status()[0].Retry.Outcome)
+}
+
+func TestScheduler_RetrySucceeds(t *testing.T) {
+	day := time.Date(2025, 11, 3, 0, 0, 0, 0, zone)
+	clock := NewFakeClock(day.Add(7 * time.Hour))
+	s := NewSchedulerWithClock(clock)
+	sch, attempts := failingSchedule(day, RetryPolicy{}, 2)
+	s.AddSchedule(sch)
+
+	for i := 0; i < 3; i++ {
+		s.evaluate(clock.Now())
+		time.Sleep(20 * time.Millisecond)
+		clock.Advance(time.Minute)
+	}
+	assert.Equal(t, 2, attempts())
+	st := s.Status()[0]
+	assert.Equal(t, &RetryStatus{Trigger: day.Add(7 * time.Hour), Attempts: 2, Outcome: RetrySucceeded}, st.Retry)
+	assert.Empty(t, st.LastError)
+	assert.Equal(t, "already_triggered_today", sch.LastSkipReason)
+}
+
+func TestScheduler_RetryKeptAcrossReload(t *testing.T) {
+	day := time.Date(2025, 11, 3, 0, 0, 0, 0, zone)
+	clock := NewFakeClock(day.Add(7 * time.Hour))
+	s := NewSchedulerWithClock(clock)
+	policy := RetryPolicy{MaxAttempts: 3, Backoff: time.Minute}
+	sch, attempts := failingSchedule(day, policy, 0)
+	s.AddSchedule(sch)
+
+	s.evaluate(clock.Now())
+	time.Sleep(20 * time.Millisecond)
+	require.Equal(t, RetryPending, s.Status()[0].Retry.Outcome)
+
+	reloaded, reloadedAttempts := failingSchedule(day, policy, 1)
+	s.ReplaceSchedules([]*DailySchedule{reloaded})
+	st := s.Status()[0]
+	require.NotNil(t, st.Retry)
+	assert.Equal(t, 1, st.Retry.Attempts)
+	assert.Equal(t, "device offline", st.LastError)
+
+	clock.Advance(30 * time.Second)
+	s.evaluate(clock.Now())
+	time.Sleep(20 * time.Millisecond)
+	assert.Zero(t, reloadedAttempts(), "the backoff survives the reload")
+	assert.Equal(t, "retry_backoff", reloaded.LastSkipReason)
+
+	clock.Advance(30 * time.Second)
+	s.evaluate(clock.Now())
+	time.Sleep(20 * time.Millisecond)
+	assert.Equal(t, 1, attempts())
+	assert.Equal(t, 1, reloadedAttempts())
+	st = s.Status()[0]
+	assert.Equal(t, RetrySucceeded, st.Retry.Outcome)
+	assert.Equal(t, 2, st.Retry.Attempts)
+}
+
+func TestScheduler_ReloadWhileActionRuns(t *testing.T) {
+	day := time.Date(2025, 11, 3, 0, 0, 0, 0, zone)
+	clock := NewFakeClock(day.Add(7 * time.Hour))
+	s := NewSchedulerWithClock(clock)
+	trigger := day.Add(7 * time.Hour)
+	release := make(chan struct{})
+	var mu sync.Mutex
+	runs := 0
+	newSchedule := func() *DailySchedule {
+		return &DailySchedule{
+			Name:    "Heater",
+			Trigger: Trigger{Time: func() time.Time { return trigger }},
+			Action: func(context.Context) error {
+				mu.Lock()
+				runs++
+				mu.Unlock()
+				<-release
+				return nil
+			},
+		}
+	}
+	s.AddSchedule(newSchedule())
+
+	s.evaluate(clock.Now())
+	time.Sleep(20 * time.Millisecond)
+	reloaded := newSchedule()
+	s.ReplaceSchedules([]*DailySchedule{reloaded})
+	s.evaluate(clock.Now())
+	assert.Equal(t, "action_running", reloaded.LastSkipReason)
+
+	close(release)
+	time.Sleep(20 * time.Millisecond)
+	clock.Advance(time.Minute)
+	s.evaluate(clock.Now())
+	time.Sleep(20 * time.Millisecond)
+	mu.Lock()
+	assert.Equal(t, 1, runs, "the reloaded schedule does not fire again")
+	mu.Unlock()
+	assert.Equal(t, RetrySucceeded, s.Status()[0].Retry.Outcome)
+	assert.Equal(t, "already_triggered_today", reloaded.LastSkipReason)
+}
+
+func TestScheduleConfig_Retry(t *testing.T) {
+	b := testBuilder(time.Date(2025, 11, 3, 12, 0, 0, 0, zone))
+	noJitter := 0.0
+	schedules, err := b.Build(ScheduleFile{Schedules: []ScheduleConfig{
+		{Name: "A", Trigger: "07:00", Action: "shelly.on", Retry: &RetryConfig{MaxAttempts: 5, Backoff: "30s", MaxBackoff: "10m", Deadline: "2h"}},
+		{Name: "B", Trigger: "07:00", Action: "shelly.on", Retry: &RetryConfig{Backoff: "1m", Jitter: &noJitter}},
+		{Name: "C", Trigger: "07:00", Action: "shelly.on"},
+	}})
+	require.NoError(t, err)
+	assert.Equal(t, RetryPolicy{MaxAttempts: 5, Backoff: 30 * time.Second, MaxBackoff: 10 * time.Minute, Jitter: 0.2, Deadline: 2 * time.Hour}, schedules[0].Retry)
+	assert.Equal(t, RetryPolicy{Backoff: time.Minute}, schedules[1].Retry)
+	assert.Equal(t, RetryPolicy{}, schedules[2].Retry)
+
+	tooMuch := 1.5
+	_, err = b.Build(ScheduleFile{Schedules: []ScheduleConfig{
+		{Name: "Negative", Trigger: "07:00", Action: "shelly.on", Retry: &RetryConfig{MaxAttempts: -1}},
+		{Name: "Bad backoff", Trigger: "07:00", Action: "shelly.on", Retry: &RetryConfig{Backoff: "soon"}},
+		{Name: "Short max", Trigger: "07:00", Action: "shelly.on", Retry: &RetryConfig{Backoff: "1m", MaxBackoff: "30s"}},
+		{Name: "Jitter", Trigger: "07:00", Action: "shelly.on", Retry: &RetryConfig{Backoff: "1m", Jitter: &tooMuch}},
+		{Name: "Cheapest", Cheapest: &CheapestConfig{Window: "18:00-07:00", Duration: "3h"}, Action: "shelly.on", OffAction: "shelly.off", Retry: &RetryConfig{MaxAttempts: 3}},
+	}})
+	require.Error(t, err)
+	for _, want := range []string{`"Negative"`, `"Bad backoff"`, `"Short max"`, `"Jitter"`, `"Cheapest"`} {
+		assert.Contains(t, err.Error(), want)
+	}
+}
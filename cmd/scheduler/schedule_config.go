@@ -0,0 +1,677 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mikahozz/gohome/integrations/sun"
+	"github.com/rs/zerolog/log"
+	"gopkg.in/yaml.v3"
+)
+
+// ScheduleFile is the on-disk representation of the scheduler configuration.
+// Files ending in .json are decoded as JSON, everything else as YAML.
+type ScheduleFile struct {
+	Timezone  string           `yaml:"timezone" json:"timezone"`
+	Schedules []ScheduleConfig `yaml:"schedules" json:"schedules"`
+}
+
+// ScheduleConfig describes a single DailySchedule in the config file.
+type ScheduleConfig struct {
+	Name        string         `yaml:"name" json:"name"`
+	Category    string         `yaml:"category" json:"category"`
+	Trigger     string         `yaml:"trigger" json:"trigger"`
+	FilterLogic string         `yaml:"filter_logic" json:"filter_logic"`
+	Filters     []FilterConfig `yaml:"filters" json:"filters"`
+	Action      string         `yaml:"action" json:"action"`
+	// Cheapest replaces Trigger for schedules following electricity prices;
+	// Action switches on and OffAction off.
+	Cheapest  *CheapestConfig `yaml:"cheapest" json:"cheapest"`
+	OffAction string          `yaml:"off_action" json:"off_action"`
+	// Recurring schedules: a cron expression, or every (e.g. "15m") with an
+	// optional between window ("16:00-22:00"). days limits every, or makes
+	// a trigger weekly.
+	Cron    string   `yaml:"cron" json:"cron"`
+	Every   string   `yaml:"every" json:"every"`
+	Between string   `yaml:"between" json:"between"`
+	Days    []string `yaml:"days" json:"days"`
+	// CatchUp is the policy for triggers missed during downtime: fire_late
+	// (default), skip_if_missed_by with MissedBy, or
+	// fire_only_latest_in_category.
+	CatchUp  string `yaml:"catch_up" json:"catch_up"`
+	MissedBy string `yaml:"missed_by" json:"missed_by"`
+	// Retry limits the retries of a failed action (default: every minute
+	// until the trigger's period ends).
+	Retry *RetryConfig `yaml:"retry" json:"retry"`
+}
+
+// RetryConfig describes a RetryPolicy, e.g. max_attempts 5, backoff "30s"
+// (doubled after each failure up to max_backoff, default 1h), jitter 0.2 and
+// deadline "2h" after the trigger time.
+type RetryConfig struct {
+	MaxAttempts int      `yaml:"max_attempts" json:"max_attempts"`
+	Backoff     string   `yaml:"backoff" json:"backoff"`
+	MaxBackoff  string   `yaml:"max_backoff" json:"max_backoff"`
+	Jitter      *float64 `yaml:"jitter" json:"jitter"` // default 0.2 with backoff
+	Deadline    string   `yaml:"deadline" json:"deadline"`
+}
+
+// CheapestConfig selects the cheapest slots within a daily window, e.g.
+// window "18:00-07:00", duration "3h", slot "1h".
+type CheapestConfig struct {
+	Window     string `yaml:"window" json:"window"`
+	Duration   string `yaml:"duration" json:"duration"`
+	Slot       string `yaml:"slot" json:"slot"` // defaults to 1h
+	Contiguous bool   `yaml:"contiguous" json:"contiguous"`
+}
+
+// FilterConfig describes a single Filter in the config file.
+type FilterConfig struct {
+	Type       string `yaml:"type" json:"type"`
+	Comparator string `yaml:"comparator" json:"comparator"`
+	Date       string `yaml:"date" json:"date"`
+	// Calendar filters: match is a case-insensitive regular expression for
+	// the event summary, tag matches "#tag" in it. event is present or absent.
+	Match string `yaml:"match" json:"match"`
+	Tag   string `yaml:"tag" json:"tag"`
+	Event string `yaml:"event" json:"event"`
+	// Weekday filters: day names, "weekdays" or "weekend".
+	Days []string `yaml:"days" json:"days"`
+	// Date range filters: "MM-DD" or "MM", recurring every year.
+	From string `yaml:"from" json:"from"`
+	To   string `yaml:"to" json:"to"`
+	// Holiday filters: skip Midsummer and Christmas Eve.
+	OfficialOnly bool `yaml:"official_only" json:"official_only"`
+	// Weather filters: value (e.g. temperature) from source observation
+	// (default) or forecast compared with threshold using comparator;
+	// within is the forecast window (default 3h).
+	Source    string   `yaml:"source" json:"source"`
+	Value     string   `yaml:"value" json:"value"`
+	Threshold *float64 `yaml:"threshold" json:"threshold"`
+	Within    string   `yaml:"within" json:"within"`
+	// Negate inverts any filter.
+	Negate bool `yaml:"negate" json:"negate"`
+}
+
+// ActionRegistry maps action references used in config files (e.g. "shelly.on")
+// to the functions executed when a schedule triggers.
+type ActionRegistry map[string]func(context.Context) error
+
+// ActionFactory creates an action from the argument of a parameterised
+// reference, e.g. "garden-lights" in "shelly.on(garden-lights)". A plain
+// reference without an entry in Actions calls the factory with an empty
+// argument.
+type ActionFactory func(arg string) (func(context.Context) error, error)
+
+// ScheduleBuilder turns ScheduleConfig entries into DailySchedules.
+type ScheduleBuilder struct {
+	Actions   ActionRegistry
+	Factories map[string]ActionFactory
+	Sun       sun.Provider
+	Now       func() time.Time
+}
+
+// LoadScheduleFile reads and validates a schedule config file. All validation
+// problems are reported together so a broken file can be fixed in one go.
+func (b *ScheduleBuilder) LoadScheduleFile(path string) ([]*DailySchedule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading schedule file: %w", err)
+	}
+	var file ScheduleFile
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		err = json.Unmarshal(data, &file)
+	} else {
+		err = yaml.Unmarshal(data, &file)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parsing schedule file %s: %w", path, err)
+	}
+	schedules, err := b.Build(file)
+	if err != nil {
+		return nil, fmt.Errorf("invalid schedule file %s: %w", path, err)
+	}
+	return schedules, nil
+}
+
+// Build validates the config and converts it to schedules.
+func (b *ScheduleBuilder) Build(file ScheduleFile) ([]*DailySchedule, error) {
+	loc := zone
+	if file.Timezone != "" {
+		l, err := time.LoadLocation(file.Timezone)
+		if err != nil {
+			return nil, fmt.Errorf("timezone %q: %w", file.Timezone, err)
+		}
+		loc = l
+	}
+
+	var errs []error
+	seen := make(map[string]bool)
+	schedules := make([]*DailySchedule, 0, len(file.Schedules))
+	for i, cfg := range file.Schedules {
+		sch, err := b.buildSchedule(cfg, loc)
+		if err == nil && seen[cfg.Name] {
+			err = errors.New("duplicate schedule name")
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("schedule #%d (%q): %w", i+1, cfg.Name, err))
+			continue
+		}
+		seen[cfg.Name] = true
+		schedules = append(schedules, sch)
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return schedules, nil
+}
+
+func (b *ScheduleBuilder) buildSchedule(cfg ScheduleConfig, loc *time.Location) (*DailySchedule, error) {
+	if strings.TrimSpace(cfg.Name) == "" {
+		return nil, errors.New("name is required")
+	}
+	var trigger Trigger
+	var offAction func(context.Context) error
+	var err error
+	kinds := 0
+	for _, set := range []bool{cfg.Trigger != "", cfg.Cheapest != nil, cfg.Cron != "", cfg.Every != ""} {
+		if set {
+			kinds++
+		}
+	}
+	if kinds > 1 {
+		return nil, errors.New("use only one of trigger, cheapest, cron and every")
+	}
+	if cfg.OffAction != "" && cfg.Cheapest == nil {
+		return nil, errors.New("off_action is only used with cheapest")
+	}
+	if cfg.Between != "" && cfg.Every == "" {
+		return nil, errors.New("between is only used with every")
+	}
+	if len(cfg.Days) > 0 && (cfg.Cheapest != nil || cfg.Cron != "") {
+		return nil, errors.New("days is only used with trigger or every")
+	}
+	switch {
+	case cfg.Cheapest != nil:
+		if trigger, err = b.parseCheapest(*cfg.Cheapest, loc); err != nil {
+			return nil, err
+		}
+		if offAction, err = b.resolveAction(cfg.OffAction); err != nil {
+			return nil, fmt.Errorf("off_action: %w", err)
+		}
+	case cfg.Cron != "" || cfg.Every != "" || len(cfg.Days) > 0:
+		if trigger, err = b.parseRecurrence(cfg, loc); err != nil {
+			return nil, err
+		}
+	default:
+		if trigger, err = b.parseTrigger(cfg.Trigger, loc); err != nil {
+			return nil, err
+		}
+	}
+	action, err := b.resolveAction(cfg.Action)
+	if err != nil {
+		return nil, err
+	}
+	logic := AndOrType(strings.ToLower(cfg.FilterLogic))
+	if logic != "" && logic != AND && logic != OR {
+		return nil, fmt.Errorf("invalid filter_logic %q (want %q or %q)", cfg.FilterLogic, AND, OR)
+	}
+	catchUp, missedBy, err := parseCatchUp(cfg)
+	if err != nil {
+		return nil, err
+	}
+	retry, err := parseRetry(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("retry: %w", err)
+	}
+	filters := make([]Filter, 0, len(cfg.Filters))
+	for j, fc := range cfg.Filters {
+		f, err := parseFilter(fc, loc)
+		if err != nil {
+			return nil, fmt.Errorf("filter #%d: %w", j+1, err)
+		}
+		filters = append(filters, f)
+	}
+	return &DailySchedule{
+		Name:        cfg.Name,
+		Category:    cfg.Category,
+		Trigger:     trigger,
+		FilterLogic: logic,
+		Filters:     filters,
+		Action:      action,
+		OffAction:   offAction,
+		CatchUp:     catchUp,
+		MissedBy:    missedBy,
+		Retry:       retry,
+	}, nil
+}
+
+func parseCatchUp(cfg ScheduleConfig) (CatchUpPolicy, time.Duration, error) {
+	policy := CatchUpPolicy(cfg.CatchUp)
+	switch {
+	case policy == "" && cfg.MissedBy == "":
+		return "", 0, nil
+	case cfg.Cheapest != nil:
+		return "", 0, errors.New("catch_up is not used with cheapest")
+	case policy == SkipIfMissedBy:
+		d, err := time.ParseDuration(cfg.MissedBy)
+		if err != nil || d <= 0 {
+			return "", 0, fmt.Errorf("invalid missed_by %q", cfg.MissedBy)
+		}
+		return policy, d, nil
+	case cfg.MissedBy != "":
+		return "", 0, fmt.Errorf("missed_by is only used with catch_up %q", SkipIfMissedBy)
+	case policy == FireOnlyLatestInCategory && cfg.Category == "":
+		return "", 0, fmt.Errorf("catch_up %q needs a category", policy)
+	case policy == FireLate || policy == FireOnlyLatestInCategory:
+		return policy, 0, nil
+	}
+	return "", 0, fmt.Errorf("invalid catch_up %q (want %q, %q or %q)", cfg.CatchUp, FireLate, SkipIfMissedBy, FireOnlyLatestInCategory)
+}
+
+// defaultJitter varies retry backoffs when the config sets no jitter.
+const defaultJitter = 0.2
+
+func parseRetry(cfg ScheduleConfig) (RetryPolicy, error) {
+	rc := cfg.Retry
+	if rc == nil {
+		return RetryPolicy{}, nil
+	}
+	if cfg.Cheapest != nil {
+		return RetryPolicy{}, errors.New("not used with cheapest, which switches again on every evaluation")
+	}
+	if rc.MaxAttempts < 0 {
+		return RetryPolicy{}, fmt.Errorf("invalid max_attempts %d", rc.MaxAttempts)
+	}
+	p := RetryPolicy{MaxAttempts: rc.MaxAttempts}
+	var err error
+	for _, d := range []struct {
+		name, value string
+		to          *time.Duration
+	}{{"backoff", rc.Backoff, &p.Backoff}, {"max_backoff", rc.MaxBackoff, &p.MaxBackoff}, {"deadline", rc.Deadline, &p.Deadline}} {
+		if d.value == "" {
+			continue
+		}
+		if *d.to, err = time.ParseDuration(d.value); err != nil || *d.to <= 0 {
+			return RetryPolicy{}, fmt.Errorf("invalid %s %q", d.name, d.value)
+		}
+	}
+	if p.MaxBackoff > 0 && p.MaxBackoff < p.Backoff {
+		return RetryPolicy{}, errors.New("max_backoff is shorter than backoff")
+	}
+	if p.MaxBackoff > 0 && p.Backoff == 0 {
+		return RetryPolicy{}, errors.New("max_backoff is only used with backoff")
+	}
+	if rc.Jitter != nil {
+		if *rc.Jitter < 0 || *rc.Jitter >= 1 {
+			return RetryPolicy{}, fmt.Errorf("invalid jitter %v (want 0 to 0.99)", *rc.Jitter)
+		}
+		p.Jitter = *rc.Jitter
+	} else if p.Backoff > 0 {
+		p.Jitter = defaultJitter
+	}
+	return p, nil
+}
+
+var actionExpr = regexp.MustCompile(`^([\w.]+)\((.+)\)$`)
+
+// resolveAction looks up a plain action reference ("shelly.on") or builds a
+// parameterised one ("shelly.on(garden-lights)").
+func (b *ScheduleBuilder) resolveAction(ref string) (func(context.Context) error, error) {
+	ref = strings.TrimSpace(ref)
+	if action, ok := b.Actions[ref]; ok {
+		return action, nil
+	}
+	if factory, ok := b.Factories[ref]; ok {
+		action, err := factory("")
+		if err != nil {
+			return nil, fmt.Errorf("action %q: %w", ref, err)
+		}
+		return action, nil
+	}
+	if m := actionExpr.FindStringSubmatch(ref); m != nil {
+		if factory, ok := b.Factories[m[1]]; ok {
+			action, err := factory(strings.TrimSpace(m[2]))
+			if err != nil {
+				return nil, fmt.Errorf("action %q: %w", ref, err)
+			}
+			return action, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown action %q", ref)
+}
+
+var (
+	clockExpr = regexp.MustCompile(`^(\d{1,2}):(\d{2})$`)
+	sunExpr   = regexp.MustCompile(`^(sunrise|sunset|dawn|dusk)\s*(?:([+-])\s*(\S+))?$`)
+)
+
+// parseTrigger understands wall clock times ("23:00") and sun events with an
+// optional offset ("sunset", "sunrise-15m", "dusk+1h30m").
+func (b *ScheduleBuilder) parseTrigger(expr string, loc *time.Location) (Trigger, error) {
+	at, err := b.parseTriggerAt(expr, loc)
+	if err != nil {
+		return Trigger{}, err
+	}
+	now := b.now()
+	return Trigger{Time: func() time.Time { return at(now().In(loc)) }}, nil
+}
+
+func (b *ScheduleBuilder) now() func() time.Time {
+	if b.Now == nil {
+		return time.Now
+	}
+	return b.Now
+}
+
+// parseTriggerAt returns a function giving the trigger time on a day. It
+// returns zero on days without the sun event, which means no trigger.
+func (b *ScheduleBuilder) parseTriggerAt(expr string, loc *time.Location) (func(day time.Time) time.Time, error) {
+	expr = strings.ToLower(strings.TrimSpace(expr))
+	if expr == "" {
+		return nil, errors.New("trigger is required")
+	}
+
+	if m := clockExpr.FindStringSubmatch(expr); m != nil {
+		hour, _ := strconv.Atoi(m[1])
+		minute, _ := strconv.Atoi(m[2])
+		if hour > 23 || minute > 59 {
+			return nil, fmt.Errorf("invalid trigger time %q", expr)
+		}
+		return func(day time.Time) time.Time {
+			return time.Date(day.Year(), day.Month(), day.Day(), hour, minute, 0, 0, loc)
+		}, nil
+	}
+
+	if m := sunExpr.FindStringSubmatch(expr); m != nil {
+		if b.Sun == nil {
+			return nil, fmt.Errorf("trigger %q needs sun data", expr)
+		}
+		var offset time.Duration
+		if m[3] != "" {
+			d, err := time.ParseDuration(m[3])
+			if err != nil {
+				return nil, fmt.Errorf("invalid trigger offset in %q: %w", expr, err)
+			}
+			offset = d
+			if m[2] == "-" {
+				offset = -d
+			}
+		}
+		event := m[1]
+		sunData := b.Sun
+		return func(day time.Time) time.Time {
+			data := sunData.GetSunDataForSingleDate(day)
+			if data == nil || data.PolarDay || data.PolarNight {
+				return time.Time{}
+			}
+			var t time.Time
+			switch event {
+			case "sunrise":
+				t = data.Sunrise
+			case "sunset":
+				t = data.Sunset
+			case "dawn":
+				t = data.Dawn
+			case "dusk":
+				t = data.Dusk
+			}
+			if t.IsZero() {
+				// The event does not happen on this day, e.g. no dusk
+				// during the white nights
+				return time.Time{}
+			}
+			return t.Add(offset)
+		}, nil
+	}
+
+	return nil, fmt.Errorf("unrecognised trigger %q (use HH:MM or sunrise/sunset/dawn/dusk with optional ±offset)", expr)
+}
+
+// parseRecurrence builds a cron, interval or weekly trigger. Its Time is the
+// latest occurrence today, or the next one when none is due today.
+func (b *ScheduleBuilder) parseRecurrence(cfg ScheduleConfig, loc *time.Location) (Trigger, error) {
+	days := [7]bool{true, true, true, true, true, true, true}
+	if len(cfg.Days) > 0 {
+		var err error
+		if days, err = parseWeekdays(cfg.Days); err != nil {
+			return Trigger{}, err
+		}
+	}
+	var rec Recurrence
+	switch {
+	case cfg.Cron != "":
+		c, err := parseCron(cfg.Cron, loc)
+		if err != nil {
+			return Trigger{}, err
+		}
+		rec = c
+	case cfg.Every != "":
+		every, err := time.ParseDuration(cfg.Every)
+		if err != nil || every < time.Minute || every%time.Minute != 0 {
+			return Trigger{}, fmt.Errorf("invalid every %q (want whole minutes, e.g. 15m)", cfg.Every)
+		}
+		r := &intervalRecurrence{every: every, end: 24*time.Hour - time.Minute, days: days, loc: loc}
+		if cfg.Between != "" {
+			if r.start, r.end, err = parseClockRange(cfg.Between); err != nil {
+				return Trigger{}, fmt.Errorf("invalid between %q (want HH:MM-HH:MM)", cfg.Between)
+			}
+		}
+		rec = r
+	default:
+		at, err := b.parseTriggerAt(cfg.Trigger, loc)
+		if err != nil {
+			return Trigger{}, err
+		}
+		rec = &weeklyRecurrence{days: days, at: at, loc: loc}
+	}
+	now := b.now()
+	return Trigger{
+		Time:       func() time.Time { return occurrence(rec, now(), loc) },
+		Recurrence: rec,
+		Location:   loc,
+	}, nil
+}
+
+// parseClockRange parses "HH:MM-HH:MM" into offsets from midnight.
+func parseClockRange(expr string) (time.Duration, time.Duration, error) {
+	parts := strings.Split(expr, "-")
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("invalid range %q", expr)
+	}
+	var bounds [2]time.Duration
+	for i, part := range parts {
+		m := clockExpr.FindStringSubmatch(strings.TrimSpace(part))
+		if m == nil {
+			return 0, 0, fmt.Errorf("invalid range %q", expr)
+		}
+		hour, _ := strconv.Atoi(m[1])
+		minute, _ := strconv.Atoi(m[2])
+		if hour > 23 || minute > 59 {
+			return 0, 0, fmt.Errorf("invalid range %q", expr)
+		}
+		bounds[i] = time.Duration(hour)*time.Hour + time.Duration(minute)*time.Minute
+	}
+	return bounds[0], bounds[1], nil
+}
+
+// parseCheapest builds a price window trigger. Its Time is the opening of the
+// current or next window.
+func (b *ScheduleBuilder) parseCheapest(cfg CheapestConfig, loc *time.Location) (Trigger, error) {
+	start, end, err := parseClockRange(cfg.Window)
+	if err != nil {
+		return Trigger{}, fmt.Errorf("invalid cheapest window %q (want HH:MM-HH:MM)", cfg.Window)
+	}
+	duration, err := time.ParseDuration(cfg.Duration)
+	if err != nil || duration <= 0 {
+		return Trigger{}, fmt.Errorf("invalid cheapest duration %q", cfg.Duration)
+	}
+	slot := time.Hour
+	if cfg.Slot != "" {
+		if slot, err = time.ParseDuration(cfg.Slot); err != nil || (slot != time.Hour && slot != 15*time.Minute) {
+			return Trigger{}, fmt.Errorf("invalid cheapest slot %q (want 1h or 15m)", cfg.Slot)
+		}
+	}
+	w := &PriceWindow{Start: start, End: end, Duration: duration, Slot: slot, Contiguous: cfg.Contiguous, Location: loc}
+	if w.Start%slot != 0 || w.End%slot != 0 {
+		return Trigger{}, fmt.Errorf("cheapest window %q must align with %s slots", cfg.Window, slot)
+	}
+	if start, end := w.bounds(time.Date(2000, 1, 1, 0, 0, 0, 0, loc)); duration > end.Sub(start) {
+		return Trigger{}, fmt.Errorf("cheapest duration %s is longer than window %q", duration, cfg.Window)
+	}
+
+	now := b.now()
+	return Trigger{
+		Time: func() time.Time {
+			start, _ := w.windowAt(now())
+			return start
+		},
+		Window: w,
+	}, nil
+}
+
+func parseFilter(fc FilterConfig, loc *time.Location) (Filter, error) {
+	f, err := parseFilterType(fc, loc)
+	if err != nil {
+		return Filter{}, err
+	}
+	f.Negate = fc.Negate
+	return f, nil
+}
+
+func parseFilterType(fc FilterConfig, loc *time.Location) (Filter, error) {
+	switch FilterType(fc.Type) {
+	case FilterDate:
+		cmp := Comparator(fc.Comparator)
+		if cmp != LessThan && cmp != GreaterThan && cmp != Equal {
+			return Filter{}, fmt.Errorf("invalid comparator %q", fc.Comparator)
+		}
+		d, err := time.ParseInLocation("2006-01-02", fc.Date, loc)
+		if err != nil {
+			return Filter{}, fmt.Errorf("invalid date %q (want YYYY-MM-DD)", fc.Date)
+		}
+		return Filter{Type: FilterDate, Date: d, Comparator: cmp}, nil
+	case FilterCalendar:
+		mode := CalendarEventMode(fc.Event)
+		if mode != EventPresent && mode != EventAbsent {
+			return Filter{}, fmt.Errorf("invalid event %q (want %q or %q)", fc.Event, EventPresent, EventAbsent)
+		}
+		var expr string
+		switch {
+		case fc.Match != "" && fc.Tag != "":
+			return Filter{}, errors.New("use either match or tag, not both")
+		case fc.Match != "":
+			expr = "(?i)" + fc.Match
+		case fc.Tag != "":
+			expr = `(?i)(^|\s)#` + regexp.QuoteMeta(fc.Tag) + `\b`
+		default:
+			return Filter{}, errors.New("calendar filter needs match or tag")
+		}
+		pattern, err := regexp.Compile(expr)
+		if err != nil {
+			return Filter{}, fmt.Errorf("invalid match %q: %w", fc.Match, err)
+		}
+		return Filter{Type: FilterCalendar, Pattern: pattern, Event: mode, Location: loc}, nil
+	case FilterWeekday:
+		days, err := parseWeekdays(fc.Days)
+		if err != nil {
+			return Filter{}, err
+		}
+		return Filter{Type: FilterWeekday, Weekdays: days, Location: loc}, nil
+	case FilterDateRange:
+		from, err := parseMonthDay(fc.From, false)
+		if err != nil {
+			return Filter{}, fmt.Errorf("from: %w", err)
+		}
+		to, err := parseMonthDay(fc.To, true)
+		if err != nil {
+			return Filter{}, fmt.Errorf("to: %w", err)
+		}
+		return Filter{Type: FilterDateRange, From: from, To: to, Location: loc}, nil
+	case FilterHoliday:
+		return Filter{Type: FilterHoliday, OfficialOnly: fc.OfficialOnly, Location: loc}, nil
+	case FilterWeather:
+		return parseWeatherFilter(fc)
+	default:
+		return Filter{}, fmt.Errorf("unknown filter type %q", fc.Type)
+	}
+}
+
+func parseWeatherFilter(fc FilterConfig) (Filter, error) {
+	f := Filter{Type: FilterWeather, Source: WeatherSource(fc.Source), Measure: fc.Value, Comparator: Comparator(fc.Comparator)}
+	if f.Source == "" {
+		f.Source = WeatherObservation
+	}
+	if f.Source != WeatherObservation && f.Source != WeatherForecast {
+		return Filter{}, fmt.Errorf("invalid source %q (want %q or %q)", fc.Source, WeatherObservation, WeatherForecast)
+	}
+	if _, ok := weatherValues[f.Measure]; !ok {
+		return Filter{}, fmt.Errorf("invalid weather value %q", fc.Value)
+	}
+	if f.Comparator != LessThan && f.Comparator != GreaterThan {
+		return Filter{}, fmt.Errorf("invalid comparator %q (want %q or %q)", fc.Comparator, LessThan, GreaterThan)
+	}
+	if fc.Threshold == nil {
+		return Filter{}, errors.New("weather filter needs a threshold")
+	}
+	f.Threshold = *fc.Threshold
+	switch {
+	case fc.Within == "" && f.Source == WeatherForecast:
+		f.Within = defaultForecastWindow
+	case fc.Within != "" && f.Source != WeatherForecast:
+		return Filter{}, errors.New("within is only used with source forecast")
+	case fc.Within != "":
+		d, err := time.ParseDuration(fc.Within)
+		if err != nil || d <= 0 {
+			return Filter{}, fmt.Errorf("invalid within %q", fc.Within)
+		}
+		f.Within = d
+	}
+	return f, nil
+}
+
+// WatchScheduleFile polls path for modifications and swaps in the reloaded
+// schedules. A file that fails to load or validate is logged and ignored so the
+// scheduler keeps running with the last good configuration.
+func (s *Scheduler) WatchScheduleFile(path string, load func(string) ([]*DailySchedule, error), interval time.Duration) {
+	lastMod := fileModTime(path)
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		for {
+			select {
+			case <-s.ctx.Done():
+				return
+			case <-s.clock.After(interval):
+			}
+			mod := fileModTime(path)
+			if mod.IsZero() || mod.Equal(lastMod) {
+				continue
+			}
+			lastMod = mod
+			schedules, err := load(path)
+			if err != nil {
+				log.Error().Err(err).Str("event", "schedule_reload_error").Str("path", path).Msg("schedule file reload failed; keeping current schedules")
+				continue
+			}
+			s.ReplaceSchedules(schedules)
+			log.Info().Str("event", "schedule_reload").Str("path", path).Int("schedule_count", len(schedules)).Msg("schedule file reloaded")
+		}
+	}()
+}
+
+func fileModTime(path string) time.Time {
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
@@ -0,0 +1,276 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mikahozz/gohome/integrations/sun"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func noopAction(ctx context.Context) error { return nil }
+
+func testBuilder(now time.Time) *ScheduleBuilder {
+	return &ScheduleBuilder{
+		Actions: ActionRegistry{"shelly.on": noopAction, "shelly.off": noopAction},
+		Sun:     sunDataInstance,
+		Now:     func() time.Time { return now },
+	}
+}
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	return path
+}
+
+func TestLoadScheduleFile_YAML(t *testing.T) {
+	now := time.Date(2025, 11, 8, 12, 0, 0, 0, zone)
+	path := writeFile(t, "schedules.yaml", `
+timezone: Europe/Helsinki
+schedules:
+  - name: Lights ON at sunset
+    category: lights
+    trigger: sunset
+    action: shelly.on
+  - name: Lights OFF
+    category: lights
+    trigger: "23:00"
+    action: shelly.off
+    filter_logic: or
+    filters:
+      - type: date
+        comparator: greater_than
+        date: "2025-01-01"
+`)
+	schedules, err := testBuilder(now).LoadScheduleFile(path)
+	require.NoError(t, err)
+	require.Len(t, schedules, 2)
+
+	sunset := sunDataInstance.GetSunDataForSingleDate(now).Sunset
+	assert.Equal(t, "Lights ON at sunset", schedules[0].Name)
+	assert.Equal(t, "lights", schedules[0].Category)
+	assert.True(t, schedules[0].Trigger.Time().Equal(sunset))
+
+	assert.True(t, schedules[1].Trigger.Time().Equal(time.Date(2025, 11, 8, 23, 0, 0, 0, zone)))
+	assert.Equal(t, OR, schedules[1].FilterLogic)
+	require.Len(t, schedules[1].Filters, 1)
+	assert.Equal(t, GreaterThan, schedules[1].Filters[0].Comparator)
+}
+
+func TestLoadScheduleFile_JSON(t *testing.T) {
+	now := time.Date(2025, 11, 8, 12, 0, 0, 0, zone)
+	path := writeFile(t, "schedules.json", `{
+	"schedules": [
+		{"name": "Morning", "trigger": "6:45", "action": "shelly.on"}
+	]
+}`)
+	schedules, err := testBuilder(now).LoadScheduleFile(path)
+	require.NoError(t, err)
+	require.Len(t, schedules, 1)
+	assert.True(t, schedules[0].Trigger.Time().Equal(time.Date(2025, 11, 8, 6, 45, 0, 0, zone)))
+}
+
+func TestParseTrigger_SunOffsets(t *testing.T) {
+	now := time.Date(2026, 2, 2, 1, 0, 0, 0, zone)
+	b := testBuilder(now)
+	data := sunDataInstance.GetSunDataForSingleDate(now)
+
+	tests := []struct {
+		expr string
+		want time.Time
+	}{
+		{"sunrise", data.Sunrise},
+		{"sunrise-15m", data.Sunrise.Add(-15 * time.Minute)},
+		{"sunset+1h30m", data.Sunset.Add(90 * time.Minute)},
+		{"Dusk - 10m", data.Dusk.Add(-10 * time.Minute)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			trig, err := b.parseTrigger(tt.expr, zone)
+			require.NoError(t, err)
+			assert.True(t, trig.Time().Equal(tt.want), "got %v want %v", trig.Time(), tt.want)
+		})
+	}
+}
+
+// TestParseTrigger_PolarDay checks that sun triggers do not fire on days
+// without the event.
+func TestParseTrigger_PolarDay(t *testing.T) {
+	midsummer := time.Date(2025, 6, 21, 12, 0, 0, 0, zone)
+	b := testBuilder(midsummer)
+	b.Sun = sun.NewCalculator(69.9078, 27.0265, zone) // Utsjoki
+
+	for _, expr := range []string{"sunset", "sunrise+30m", "dusk-10m"} {
+		t.Run(expr, func(t *testing.T) {
+			trig, err := b.parseTrigger(expr, zone)
+			require.NoError(t, err)
+			assert.True(t, trig.Time().IsZero(), "got %v", trig.Time())
+
+			s := NewScheduler()
+			sch := &DailySchedule{Name: expr, Trigger: trig, Action: noopAction}
+			s.AddSchedule(sch)
+			assert.False(t, s.shouldTrigger(sch, midsummer))
+			assert.Empty(t, s.decideMissed([]*DailySchedule{sch}, midsummer))
+		})
+	}
+
+	trig, err := b.parseRecurrence(ScheduleConfig{Trigger: "sunset", Days: []string{"mon", "tue", "wed", "thu", "fri", "sat", "sun"}}, zone)
+	require.NoError(t, err)
+	assert.True(t, trig.Time().IsZero(), "weekly sunset has no occurrence during the polar day")
+}
+
+func TestBuild_ReportsAllErrors(t *testing.T) {
+	b := testBuilder(time.Now())
+	_, err := b.Build(ScheduleFile{Schedules: []ScheduleConfig{
+		{Name: "ok", Trigger: "10:00", Action: "shelly.on"},
+		{Name: "bad action", Trigger: "10:00", Action: "shelly.blink"},
+		{Name: "bad trigger", Trigger: "25:00", Action: "shelly.on"},
+		{Name: "ok", Trigger: "11:00", Action: "shelly.off"},
+		{Name: "bad filter", Trigger: "sunset", Action: "shelly.on", Filters: []FilterConfig{{Type: "date", Comparator: "around", Date: "2025-01-01"}}},
+		{Trigger: "sunrise", Action: "shelly.on"},
+	}})
+	require.Error(t, err)
+	msg := err.Error()
+	assert.Contains(t, msg, `schedule #2 ("bad action"): unknown action "shelly.blink"`)
+	assert.Contains(t, msg, `schedule #3 ("bad trigger"): invalid trigger time "25:00"`)
+	assert.Contains(t, msg, `schedule #4 ("ok"): duplicate schedule name`)
+	assert.Contains(t, msg, `schedule #5 ("bad filter"): filter #1: invalid comparator "around"`)
+	assert.Contains(t, msg, `schedule #6 (""): name is required`)
+}
+
+func TestReplaceSchedulesKeepsLastTriggered(t *testing.T) {
+	s := NewScheduler()
+	triggered := time.Date(2025, 11, 8, 16, 0, 0, 0, zone)
+	s.AddSchedule(&DailySchedule{Name: "Keep", Trigger: Trigger{Time: time.Now}, Action: noopAction, LastTriggered: triggered})
+	s.AddSchedule(&DailySchedule{Name: "Removed", Trigger: Trigger{Time: time.Now}, Action: noopAction, LastTriggered: triggered})
+
+	s.ReplaceSchedules([]*DailySchedule{
+		{Name: "Keep", Trigger: Trigger{Time: time.Now}, Action: noopAction},
+		{Name: "New", Trigger: Trigger{Time: time.Now}, Action: noopAction},
+	})
+
+	require.Len(t, s.schedules, 2)
+	assert.Equal(t, triggered, s.schedules[0].LastTriggered)
+	assert.True(t, s.schedules[1].LastTriggered.IsZero())
+}
+
+func TestWatchScheduleFile_Reload(t *testing.T) {
+	now := time.Date(2025, 11, 8, 12, 0, 0, 0, zone)
+	fc := NewFakeClock(now)
+	b := testBuilder(now)
+	path := writeFile(t, "schedules.yaml", "schedules:\n  - {name: A, trigger: \"10:00\", action: shelly.on}\n")
+	schedules, err := b.LoadScheduleFile(path)
+	require.NoError(t, err)
+
+	s := NewSchedulerWithClock(fc)
+	for _, sch := range schedules {
+		s.AddSchedule(sch)
+	}
+	s.schedules[0].LastTriggered = now
+	s.WatchScheduleFile(path, b.LoadScheduleFile, time.Second)
+	defer s.Stop()
+
+	// Invalid content keeps the current schedules
+	require.NoError(t, os.WriteFile(path, []byte("schedules:\n  - {name: A, trigger: nope, action: shelly.on}\n"), 0o644))
+	require.NoError(t, os.Chtimes(path, now, now.Add(time.Minute)))
+	fc.Advance(time.Second)
+	time.Sleep(20 * time.Millisecond)
+	s.mu.RLock()
+	assert.Len(t, s.schedules, 1)
+	s.mu.RUnlock()
+
+	require.NoError(t, os.WriteFile(path, []byte("schedules:\n  - {name: A, trigger: \"10:00\", action: shelly.on}\n  - {name: B, trigger: \"11:00\", action: shelly.off}\n"), 0o644))
+	require.NoError(t, os.Chtimes(path, now, now.Add(2*time.Minute)))
+	fc.Advance(time.Second)
+	time.Sleep(20 * time.Millisecond)
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	require.Len(t, s.schedules, 2)
+	assert.Equal(t, now, s.schedules[0].LastTriggered, "reload must keep LastTriggered")
+	assert.Equal(t, "B", s.schedules[1].Name)
+}
+
+func TestLoadScheduleFile_ParameterisedActions(t *testing.T) {
+	now := time.Date(2025, 11, 8, 12, 0, 0, 0, zone)
+	var switched []string
+	b := testBuilder(now)
+	b.Factories = map[string]ActionFactory{
+		"shelly.on": func(device string) (func(context.Context) error, error) {
+			if device != "garden-lights" {
+				return nil, fmt.Errorf("unknown shelly device %q", device)
+			}
+			return func(ctx context.Context) error {
+				switched = append(switched, device)
+				return nil
+			}, nil
+		},
+	}
+	path := writeFile(t, "schedules.yaml", `
+schedules:
+  - name: Garden lights ON
+    trigger: sunset
+    action: shelly.on(garden-lights)
+  - name: Default device OFF
+    trigger: "23:00"
+    action: shelly.off
+`)
+	schedules, err := b.LoadScheduleFile(path)
+	require.NoError(t, err)
+	require.NoError(t, schedules[0].Action(context.Background()))
+	assert.Equal(t, []string{"garden-lights"}, switched)
+
+	path = writeFile(t, "bad.yaml", `
+schedules:
+  - name: Unknown device
+    trigger: sunset
+    action: shelly.on(attic)
+  - name: Unknown factory
+    trigger: sunset
+    action: hue.on(kitchen)
+`)
+	_, err = b.LoadScheduleFile(path)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `unknown shelly device "attic"`)
+	assert.Contains(t, err.Error(), `unknown action "hue.on(kitchen)"`)
+}
+
+func TestLoadScheduleFile_PlainFactoryReference(t *testing.T) {
+	b := testBuilder(time.Date(2025, 11, 8, 12, 0, 0, 0, zone))
+	b.Actions = nil
+	var devices []string
+	b.Factories = map[string]ActionFactory{
+		"shelly.on": func(device string) (func(context.Context) error, error) {
+			devices = append(devices, device)
+			return noopAction, nil
+		},
+		"shelly.off": func(device string) (func(context.Context) error, error) {
+			return nil, fmt.Errorf("unknown shelly device %q", "default")
+		},
+	}
+	path := writeFile(t, "schedules.yaml", `
+schedules:
+  - name: Default device ON
+    trigger: "07:00"
+    action: shelly.on
+`)
+	_, err := b.LoadScheduleFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, []string{""}, devices)
+
+	path = writeFile(t, "bad.yaml", `
+schedules:
+  - name: Default device OFF
+    trigger: "23:00"
+    action: shelly.off
+`)
+	_, err = b.LoadScheduleFile(path)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `action "shelly.off": unknown shelly device "default"`)
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// StateStore persists successful schedule triggers so a restarted scheduler
+// knows which actions already ran in the current period.
+type StateStore interface {
+	// Load returns the latest trigger time per schedule name.
+	Load(ctx context.Context) (map[string]time.Time, error)
+	// RecordTrigger stores a successful trigger of a schedule.
+	RecordTrigger(ctx context.Context, schedule *DailySchedule, at time.Time) error
+}
+
+// FileStateStore keeps the latest trigger per schedule in a JSON file.
+type FileStateStore struct {
+	path string
+	mu   sync.Mutex
+}
+
+// NewFileStateStore returns a store writing to path. The file and its parent
+// directory are created on first write.
+func NewFileStateStore(path string) *FileStateStore {
+	return &FileStateStore{path: path}
+}
+
+// CheckWritable creates the state directory and writes a probe file next to
+// the state file, so a read-only location fails at startup instead of on the
+// first trigger.
+func (f *FileStateStore) CheckWritable() error {
+	if err := os.MkdirAll(filepath.Dir(f.path), 0o755); err != nil {
+		return fmt.Errorf("creating state directory: %w", err)
+	}
+	probe, err := os.CreateTemp(filepath.Dir(f.path), ".write-check-*")
+	if err != nil {
+		return fmt.Errorf("state directory %s is not writable: %w", filepath.Dir(f.path), err)
+	}
+	probe.Close()
+	return os.Remove(probe.Name())
+}
+
+func (f *FileStateStore) Load(ctx context.Context) (map[string]time.Time, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.read()
+}
+
+func (f *FileStateStore) RecordTrigger(ctx context.Context, schedule *DailySchedule, at time.Time) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	state, err := f.read()
+	if err != nil {
+		return err
+	}
+	state[schedule.Name] = at
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(f.path), 0o755); err != nil {
+		return fmt.Errorf("creating state directory: %w", err)
+	}
+	// Write to a temp file and rename so a crash never leaves a truncated file.
+	tmp := f.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("writing state file: %w", err)
+	}
+	return os.Rename(tmp, f.path)
+}
+
+func (f *FileStateStore) read() (map[string]time.Time, error) {
+	state := make(map[string]time.Time)
+	data, err := os.ReadFile(f.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading state file: %w", err)
+	}
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("parsing state file %s: %w", f.path, err)
+	}
+	return state, nil
+}
+
+// PostgresStateStore keeps the latest trigger per schedule in the
+// scheduler_triggers table (db/init/04_init_scheduler_state.sql).
+type PostgresStateStore struct {
+	db *sql.DB
+}
+
+func NewPostgresStateStore(db *sql.DB) *PostgresStateStore {
+	return &PostgresStateStore{db: db}
+}
+
+func (p *PostgresStateStore) Load(ctx context.Context) (map[string]time.Time, error) {
+	rows, err := p.db.QueryContext(ctx, `
+		SELECT schedule_name, triggered_at FROM scheduler_triggers`)
+	if err != nil {
+		return nil, fmt.Errorf("querying scheduler triggers: %w", err)
+	}
+	defer rows.Close()
+	state := make(map[string]time.Time)
+	for rows.Next() {
+		var name string
+		var at time.Time
+		if err := rows.Scan(&name, &at); err != nil {
+			return nil, err
+		}
+		state[name] = at
+	}
+	return state, rows.Err()
+}
+
+func (p *PostgresStateStore) RecordTrigger(ctx context.Context, schedule *DailySchedule, at time.Time) error {
+	var category sql.NullString
+	if schedule.Category != "" {
+		category = sql.NullString{String: schedule.Category, Valid: true}
+	}
+	_, err := p.db.ExecContext(ctx,
+		`INSERT INTO scheduler_triggers (schedule_name, category, triggered_at) VALUES ($1, $2, $3)
+		ON CONFLICT (schedule_name) DO UPDATE
+		SET category = EXCLUDED.category, triggered_at = GREATEST(scheduler_triggers.triggered_at, EXCLUDED.triggered_at)`,
+		schedule.Name, category, at)
+	if err != nil {
+		return fmt.Errorf("recording scheduler trigger: %w", err)
+	}
+	return nil
+}
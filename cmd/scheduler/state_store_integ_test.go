@@ -0,0 +1,43 @@
+//go:build integration
+
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mikahozz/gohome/config"
+	"github.com/mikahozz/gohome/db"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPostgresStateStore(t *testing.T) {
+	config.LoadEnv()
+	conn, err := db.Open()
+	if err != nil {
+		t.Skipf("database not available: %v", err)
+	}
+	defer conn.Close()
+
+	ctx := context.Background()
+	store := NewPostgresStateStore(conn)
+	name := "integration test " + time.Now().Format(time.RFC3339Nano)
+	defer conn.Exec(`DELETE FROM scheduler_triggers WHERE schedule_name = $1`, name)
+
+	first := time.Now().Add(-time.Hour).Truncate(time.Second)
+	second := first.Add(30 * time.Minute)
+	require.NoError(t, store.RecordTrigger(ctx, &DailySchedule{Name: name, Category: "test"}, first))
+	require.NoError(t, store.RecordTrigger(ctx, &DailySchedule{Name: name, Category: "test"}, second))
+
+	// An out of order trigger does not move the state back
+	require.NoError(t, store.RecordTrigger(ctx, &DailySchedule{Name: name, Category: "test"}, first))
+
+	state, err := store.Load(ctx)
+	require.NoError(t, err)
+	require.True(t, state[name].Equal(second), "got %v want %v", state[name], second)
+
+	var rows int
+	require.NoError(t, conn.QueryRow(`SELECT COUNT(*) FROM scheduler_triggers WHERE schedule_name = $1`, name).Scan(&rows))
+	require.Equal(t, 1, rows, "one row per schedule")
+}
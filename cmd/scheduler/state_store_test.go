@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileStateStore_RoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nested", "state.json")
+	store := NewFileStateStore(path)
+	ctx := context.Background()
+
+	state, err := store.Load(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, state, "missing file should load as empty state")
+
+	first := time.Date(2025, 11, 8, 16, 8, 0, 0, zone)
+	second := time.Date(2025, 11, 8, 23, 0, 0, 0, zone)
+	require.NoError(t, store.RecordTrigger(ctx, &DailySchedule{Name: "ON"}, first))
+	require.NoError(t, store.RecordTrigger(ctx, &DailySchedule{Name: "OFF"}, first))
+	require.NoError(t, store.RecordTrigger(ctx, &DailySchedule{Name: "OFF"}, second))
+
+	state, err = NewFileStateStore(path).Load(ctx)
+	require.NoError(t, err)
+	assert.True(t, state["ON"].Equal(first))
+	assert.True(t, state["OFF"].Equal(second))
+}
+
+// TestRestartDoesNotRerunAction simulates a scheduler restart at 23:05 after the
+// sunset schedule already ran at 16:08.
+func TestRestartDoesNotRerunAction(t *testing.T) {
+	store := NewFileStateStore(filepath.Join(t.TempDir(), "state.json"))
+	trig := func() time.Time { return time.Date(2025, 11, 8, 16, 8, 0, 0, zone) }
+	var calls int32
+	act := func(ctx context.Context) error { atomic.AddInt32(&calls, 1); return nil }
+
+	before := NewScheduler()
+	require.NoError(t, before.UseStateStore(context.Background(), store))
+	before.AddSchedule(&DailySchedule{Name: "Night lights ON at sunset", Trigger: Trigger{Time: trig}, Action: act})
+	before.evaluate(time.Date(2025, 11, 8, 16, 9, 0, 0, zone))
+	time.Sleep(50 * time.Millisecond)
+	require.Equal(t, int32(1), atomic.LoadInt32(&calls))
+
+	after := NewScheduler()
+	after.AddSchedule(&DailySchedule{Name: "Night lights ON at sunset", Trigger: Trigger{Time: trig}, Action: act})
+	require.NoError(t, after.UseStateStore(context.Background(), store))
+	after.evaluate(time.Date(2025, 11, 8, 23, 5, 0, 0, zone))
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls), "action must not re-run after restart")
+	assert.True(t, after.schedules[0].LastTriggered.Equal(time.Date(2025, 11, 8, 16, 9, 0, 0, zone)))
+}
+
+func TestFileStateStore_CheckWritable(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, NewFileStateStore(filepath.Join(dir, "data", "state.json")).CheckWritable())
+	entries, err := os.ReadDir(filepath.Join(dir, "data"))
+	require.NoError(t, err)
+	assert.Empty(t, entries, "probe file should be removed")
+
+	require.NoError(t, os.Chmod(dir, 0o500))
+	t.Cleanup(func() { os.Chmod(dir, 0o700) })
+	if os.Geteuid() == 0 {
+		t.Skip("root can write to read-only directories")
+	}
+	assert.Error(t, NewFileStateStore(filepath.Join(dir, "other", "state.json")).CheckWritable())
+}
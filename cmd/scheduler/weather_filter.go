@@ -0,0 +1,211 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/mikahozz/gohome/integrations/fmi"
+	"github.com/rs/zerolog/log"
+)
+
+// WeatherSource selects the FMI data a weather filter reads.
+type WeatherSource string
+
+const (
+	// WeatherObservation uses the latest observation of the station.
+	WeatherObservation WeatherSource = "observation"
+	// WeatherForecast passes if any forecast hour within the filter's
+	// window meets the condition, e.g. rain expected in the next 3 hours.
+	WeatherForecast WeatherSource = "forecast"
+)
+
+const (
+	// weatherRefreshInterval is how long fetched weather is used before a refresh.
+	weatherRefreshInterval = 10 * time.Minute
+	// weatherRetryInterval is the wait after a failed fetch.
+	weatherRetryInterval = 5 * time.Minute
+	// weatherMaxAge is the oldest observation a filter is decided on.
+	weatherMaxAge = 2 * time.Hour
+	// defaultForecastWindow is used when a forecast filter has no within.
+	defaultForecastWindow = 3 * time.Hour
+)
+
+// weatherValues maps the value names used in filters to the FMI data. Cloud
+// cover is in oktas (0-8); the forecast reports percent and is converted.
+var weatherValues = map[string]func(w fmi.WeatherData, source WeatherSource) float64{
+	"temperature": func(w fmi.WeatherData, _ WeatherSource) float64 { return w.Temp },
+	"humidity":    func(w fmi.WeatherData, _ WeatherSource) float64 { return w.Humidity },
+	"wind_speed":  func(w fmi.WeatherData, _ WeatherSource) float64 { return w.WindSpeed },
+	"wind_gust":   func(w fmi.WeatherData, _ WeatherSource) float64 { return w.MaxWindSpeed },
+	"rain":        func(w fmi.WeatherData, _ WeatherSource) float64 { return w.Rain },
+	"pressure":    func(w fmi.WeatherData, _ WeatherSource) float64 { return w.Pressure },
+	"visibility":  func(w fmi.WeatherData, _ WeatherSource) float64 { return w.Visibility },
+	"snow_depth":  func(w fmi.WeatherData, _ WeatherSource) float64 { return w.SnowDepth },
+	"clouds": func(w fmi.WeatherData, source WeatherSource) float64 {
+		if source == WeatherForecast {
+			return w.CloudCover * 8 / 100
+		}
+		return w.CloudCover
+	},
+}
+
+// weatherFields are the fmi.WeatherData JSON names of the values, used to
+// skip rows where FMI had no reading.
+var weatherFields = map[string]string{
+	"temperature": "temperature",
+	"humidity":    "humidity",
+	"wind_speed":  "wind_speed",
+	"wind_gust":   "max_wind",
+	"rain":        "rain",
+	"pressure":    "pressure",
+	"visibility":  "visibility",
+	"snow_depth":  "snow",
+	"clouds":      "clouds",
+}
+
+// hourlyObservations are observed once an hour; the rows in between are empty.
+var hourlyObservations = map[string]bool{"rain": true, "clouds": true}
+
+type weatherEntry struct {
+	data      []fmi.WeatherData
+	loaded    bool
+	fetchedAt time.Time
+	failedAt  time.Time
+	fetching  bool
+}
+
+// weatherCache keeps the last fetched observations and forecast. Like the
+// calendar cache it refreshes in the background and keeps stale data when
+// FMI cannot be reached.
+type weatherCache struct {
+	fetch func(fmi.RequestType) (fmi.WeatherDataModel, error)
+
+	mu      sync.Mutex
+	entries map[fmi.RequestType]*weatherEntry
+}
+
+func newWeatherCache(fetch func(fmi.RequestType) (fmi.WeatherDataModel, error)) *weatherCache {
+	return &weatherCache{fetch: fetch, entries: map[fmi.RequestType]*weatherEntry{
+		fmi.Observations: {},
+		fmi.Forecast:     {},
+	}}
+}
+
+// UseWeather sets the weather data used by weather filters.
+func (s *Scheduler) UseWeather(fetch func(fmi.RequestType) (fmi.WeatherDataModel, error)) {
+	s.mu.Lock()
+	s.weather = newWeatherCache(fetch)
+	s.mu.Unlock()
+}
+
+// fmiWeather fetches observations from station and the forecast for place.
+func fmiWeather(station, place string) func(fmi.RequestType) (fmi.WeatherDataModel, error) {
+	return func(requestType fmi.RequestType) (fmi.WeatherDataModel, error) {
+		if requestType == fmi.Forecast {
+			return fmi.GetWeatherData(fmi.StationId(place), requestType)
+		}
+		return fmi.GetWeatherData(fmi.StationId(station), requestType)
+	}
+}
+
+// Data returns the cached rows of requestType and whether any fetch has
+// succeeded yet. A refresh is started in the background when they are stale.
+func (c *weatherCache) Data(requestType fmi.RequestType, now time.Time) ([]fmi.WeatherData, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e := c.entries[requestType]
+	stale := !e.loaded || now.Sub(e.fetchedAt) >= weatherRefreshInterval
+	retryWait := !e.failedAt.IsZero() && now.Sub(e.failedAt) < weatherRetryInterval
+	if stale && !retryWait && !e.fetching {
+		e.fetching = true
+		go c.refresh(requestType, now)
+	}
+	return e.data, e.loaded
+}
+
+// refresh fetches the rows of requestType. On failure the previous rows are kept.
+func (c *weatherCache) refresh(requestType fmi.RequestType, now time.Time) {
+	data, err := c.fetch(requestType)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e := c.entries[requestType]
+	e.fetching = false
+	if err != nil {
+		e.failedAt = now
+		log.Error().Err(err).Str("event", "weather_fetch_error").Int("request_type", int(requestType)).Bool("stale_data", e.loaded).Msg("Failed to fetch weather data")
+		return
+	}
+	e.data, e.loaded, e.fetchedAt, e.failedAt = data.WeatherData, true, now, time.Time{}
+	log.Info().Str("event", "weather_fetched").Int("request_type", int(requestType)).Int("rows", len(data.WeatherData)).Msg("Weather data refreshed")
+}
+
+// weatherFilterPass evaluates a weather filter and describes the value it was
+// decided on, e.g. "temperature -2.3 (observation), want less_than -5".
+// Without data the filter does not pass.
+func (s *Scheduler) weatherFilterPass(filter Filter, now time.Time) (bool, string) {
+	s.mu.RLock()
+	cache := s.weather
+	s.mu.RUnlock()
+	want := fmt.Sprintf("want %s %g", filter.Comparator, filter.Threshold)
+	if cache == nil {
+		log.Warn().Str("event", "weather_not_configured").Msg("Weather filter used without weather data")
+		return false, fmt.Sprintf("%s unavailable, %s", filter.Measure, want)
+	}
+	value := weatherValues[filter.Measure]
+	field := weatherFields[filter.Measure]
+
+	if filter.Source == WeatherForecast {
+		rows, _ := cache.Data(fmi.Forecast, now)
+		end := now.Add(filter.Within)
+		extreme, found := 0.0, false
+		for _, w := range rows {
+			t, err := time.Parse(time.RFC3339, w.Time)
+			if err != nil || !t.After(now) || t.After(end) || !w.Has(field) {
+				continue
+			}
+			v := value(w, WeatherForecast)
+			if !found || (filter.Comparator == LessThan && v < extreme) || (filter.Comparator == GreaterThan && v > extreme) {
+				extreme = v
+			}
+			found = true
+		}
+		if !found {
+			return false, fmt.Sprintf("%s forecast unavailable, %s", filter.Measure, want)
+		}
+		return compareWeather(extreme, filter), fmt.Sprintf("%s %g in the next %s (forecast), %s", filter.Measure, round1(extreme), filter.Within, want)
+	}
+
+	rows, _ := cache.Data(fmi.Observations, now)
+	w, at, found := latestObservation(rows, now, field, hourlyObservations[filter.Measure])
+	if !found || now.Sub(at) > weatherMaxAge {
+		return false, fmt.Sprintf("%s observation unavailable, %s", filter.Measure, want)
+	}
+	v := value(w, WeatherObservation)
+	return compareWeather(v, filter), fmt.Sprintf("%s %g at %s (observation), %s", filter.Measure, round1(v), at.In(zone).Format("15:04"), want)
+}
+
+// latestObservation returns the newest row not after now that has a value
+// for field, with hourly only the rows on the hour.
+func latestObservation(rows []fmi.WeatherData, now time.Time, field string, hourly bool) (fmi.WeatherData, time.Time, bool) {
+	for i := len(rows) - 1; i >= 0; i-- {
+		t, err := time.Parse(time.RFC3339, rows[i].Time)
+		if err != nil || t.After(now) || (hourly && t.Minute() != 0) || !rows[i].Has(field) {
+			continue
+		}
+		return rows[i], t, true
+	}
+	return fmi.WeatherData{}, time.Time{}, false
+}
+
+func compareWeather(v float64, filter Filter) bool {
+	if filter.Comparator == LessThan {
+		return v < filter.Threshold
+	}
+	return v > filter.Threshold
+}
+
+func round1(v float64) float64 {
+	return math.Round(v*10) / 10
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mikahozz/gohome/integrations/fmi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func utc(t time.Time) string { return t.UTC().Format(time.RFC3339) }
+
+// testWeather returns observations every 10 minutes until now (clouds and
+// rain only on the hour, as FMI reports them) and an hourly forecast.
+func testWeather(now time.Time) func(fmi.RequestType) (fmi.WeatherDataModel, error) {
+	return func(requestType fmi.RequestType) (fmi.WeatherDataModel, error) {
+		var rows []fmi.WeatherData
+		if requestType == fmi.Forecast {
+			start := now.Truncate(time.Hour)
+			for i := 0; i < 6; i++ {
+				w := fmi.WeatherData{Time: utc(start.Add(time.Duration(i) * time.Hour)), Temp: -3, CloudCover: 100}
+				if i == 4 {
+					w.Rain = 1.2
+				}
+				rows = append(rows, w)
+			}
+			return fmi.WeatherDataModel{WeatherData: rows}, nil
+		}
+		for t := now.Truncate(10 * time.Minute).Add(-time.Hour); !t.After(now); t = t.Add(10 * time.Minute) {
+			w := fmi.WeatherData{Time: utc(t), Temp: -6.24}
+			if t.Minute() == 0 {
+				w.CloudCover = 7
+			}
+			rows = append(rows, w)
+		}
+		return fmi.WeatherDataModel{WeatherData: rows}, nil
+	}
+}
+
+func TestScheduleConfig_WeatherFilters(t *testing.T) {
+	b := testBuilder(time.Date(2025, 1, 17, 12, 0, 0, 0, zone))
+	path := writeFile(t, "schedules.yaml", `
+schedules:
+  - name: Car heater
+    trigger: "06:00"
+    action: shelly.on
+    filters:
+      - {type: weather, value: temperature, comparator: less_than, threshold: -5}
+      - {type: weather, source: forecast, value: rain, comparator: greater_than, threshold: 0, within: 2h}
+      - {type: weather, source: forecast, value: clouds, comparator: greater_than, threshold: 6}
+`)
+	schedules, err := b.LoadScheduleFile(path)
+	require.NoError(t, err)
+	f := schedules[0].Filters
+	assert.Equal(t, Filter{Type: FilterWeather, Source: WeatherObservation, Measure: "temperature", Comparator: LessThan, Threshold: -5}, f[0])
+	assert.Equal(t, 2*time.Hour, f[1].Within)
+	assert.Equal(t, defaultForecastWindow, f[2].Within)
+
+	path = writeFile(t, "bad.yaml", `
+schedules:
+  - name: No threshold
+    trigger: "06:00"
+    action: shelly.on
+    filters: [{type: weather, value: temperature, comparator: less_than}]
+  - name: Unknown value
+    trigger: "06:00"
+    action: shelly.on
+    filters: [{type: weather, value: sunshine, comparator: less_than, threshold: 1}]
+  - name: Equal
+    trigger: "06:00"
+    action: shelly.on
+    filters: [{type: weather, value: temperature, comparator: equal, threshold: 1}]
+  - name: Observation window
+    trigger: "06:00"
+    action: shelly.on
+    filters: [{type: weather, value: rain, comparator: greater_than, threshold: 0, within: 3h}]
+`)
+	_, err = b.LoadScheduleFile(path)
+	require.Error(t, err)
+	for _, want := range []string{`"No threshold"`, `"Unknown value"`, `"Equal"`, `"Observation window"`} {
+		assert.Contains(t, err.Error(), want)
+	}
+}
+
+func TestScheduler_WeatherFilter(t *testing.T) {
+	now := time.Date(2025, 1, 17, 6, 5, 0, 0, zone)
+	s := NewSchedulerWithClock(NewFakeClock(now))
+	s.UseWeather(testWeather(now))
+	s.weather.refresh(fmi.Observations, now)
+	s.weather.refresh(fmi.Forecast, now)
+
+	for _, tc := range []struct {
+		name   string
+		filter Filter
+		pass   bool
+		detail string
+	}{
+		{"cold", Filter{Type: FilterWeather, Source: WeatherObservation, Measure: "temperature", Comparator: LessThan, Threshold: -5},
+			true, "temperature -6.2 at 06:00 (observation), want less_than -5"},
+		{"not colder", Filter{Type: FilterWeather, Source: WeatherObservation, Measure: "temperature", Comparator: LessThan, Threshold: -10},
+			false, "temperature -6.2 at 06:00 (observation), want less_than -10"},
+		{"clouds on the hour", Filter{Type: FilterWeather, Source: WeatherObservation, Measure: "clouds", Comparator: GreaterThan, Threshold: 6},
+			true, "clouds 7 at 06:00 (observation), want greater_than 6"},
+		{"rain later", Filter{Type: FilterWeather, Source: WeatherForecast, Measure: "rain", Comparator: GreaterThan, Within: 2 * time.Hour},
+			false, "rain 0 in the next 2h0m0s (forecast), want greater_than 0"},
+		{"rain within", Filter{Type: FilterWeather, Source: WeatherForecast, Measure: "rain", Comparator: GreaterThan, Within: 4 * time.Hour},
+			true, "rain 1.2 in the next 4h0m0s (forecast), want greater_than 0"},
+		{"forecast clouds in oktas", Filter{Type: FilterWeather, Source: WeatherForecast, Measure: "clouds", Comparator: GreaterThan, Threshold: 6, Within: time.Hour},
+			true, "clouds 8 in the next 1h0m0s (forecast), want greater_than 6"},
+		{"negated", Filter{Type: FilterWeather, Source: WeatherForecast, Measure: "rain", Comparator: GreaterThan, Within: 4 * time.Hour, Negate: true},
+			false, "not rain 1.2 in the next 4h0m0s (forecast), want greater_than 0"},
+	} {
+		pass, detail := s.filterResult(tc.filter, now)
+		assert.Equal(t, tc.pass, pass, tc.name)
+		assert.Equal(t, tc.detail, detail, tc.name)
+	}
+
+	sch := &DailySchedule{
+		Name:    "Car heater",
+		Trigger: Trigger{Time: func() time.Time { return time.Date(2025, 1, 17, 6, 0, 0, 0, zone) }},
+		Filters: []Filter{
+			{Type: FilterWeekday, Weekdays: [7]bool{false, true, true, true, true, true, false}},
+			{Type: FilterWeather, Source: WeatherObservation, Measure: "temperature", Comparator: LessThan, Threshold: -10},
+		},
+	}
+	assert.Equal(t, []string{"temperature -6.2 at 06:00 (observation), want less_than -10"}, s.failedFilters(sch, now))
+}
+
+func TestScheduler_WeatherUnavailable(t *testing.T) {
+	now := time.Date(2025, 1, 17, 6, 5, 0, 0, zone)
+	cold := Filter{Type: FilterWeather, Source: WeatherObservation, Measure: "temperature", Comparator: LessThan, Threshold: -5}
+
+	s := NewSchedulerWithClock(NewFakeClock(now))
+	pass, detail := s.filterResult(cold, now)
+	assert.False(t, pass, "no weather configured")
+	assert.Contains(t, detail, "unavailable")
+
+	var fetches int32
+	s.UseWeather(func(fmi.RequestType) (fmi.WeatherDataModel, error) {
+		atomic.AddInt32(&fetches, 1)
+		return fmi.WeatherDataModel{}, errors.New("FMI down")
+	})
+	s.weather.refresh(fmi.Observations, now)
+	pass, detail = s.filterResult(cold, now)
+	assert.False(t, pass)
+	assert.True(t, strings.HasPrefix(detail, "temperature observation unavailable"), detail)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&fetches), "failed fetch is not retried immediately")
+
+	// Old observations are not used
+	s.UseWeather(testWeather(now.Add(-3 * time.Hour)))
+	s.weather.refresh(fmi.Observations, now)
+	pass, _ = s.filterResult(cold, now)
+	assert.False(t, pass)
+}
+
+func TestScheduler_WeatherMissingValues(t *testing.T) {
+	now := time.Date(2025, 1, 17, 6, 5, 0, 0, zone)
+	s := NewSchedulerWithClock(NewFakeClock(now))
+	s.UseWeather(func(requestType fmi.RequestType) (fmi.WeatherDataModel, error) {
+		if requestType == fmi.Forecast {
+			return fmi.WeatherDataModel{WeatherData: []fmi.WeatherData{
+				{Time: utc(now.Add(time.Hour)), Temp: -8},
+				{Time: utc(now.Add(2 * time.Hour)), Missing: []string{"temperature"}},
+			}}, nil
+		}
+		// The newest row has no temperature (NaN from FMI)
+		return fmi.WeatherDataModel{WeatherData: []fmi.WeatherData{
+			{Time: utc(now.Add(-15 * time.Minute)), Temp: -6.24},
+			{Time: utc(now.Add(-5 * time.Minute)), Humidity: 80, Missing: []string{"temperature"}},
+		}}, nil
+	})
+	s.weather.refresh(fmi.Observations, now)
+	s.weather.refresh(fmi.Forecast, now)
+
+	pass, detail := s.filterResult(Filter{Type: FilterWeather, Source: WeatherObservation, Measure: "temperature", Comparator: GreaterThan, Threshold: -5}, now)
+	assert.False(t, pass, "a missing reading is not 0 °C")
+	assert.Equal(t, "temperature -6.2 at 05:50 (observation), want greater_than -5", detail)
+
+	pass, detail = s.filterResult(Filter{Type: FilterWeather, Source: WeatherForecast, Measure: "temperature", Comparator: GreaterThan, Threshold: -5, Within: 3 * time.Hour}, now)
+	assert.False(t, pass)
+	assert.Equal(t, "temperature -8 in the next 3h0m0s (forecast), want greater_than -5", detail)
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Frequency is how often a synced entry is refreshed while its target date is
+// current. Values match the sync_frequency enum.
+type Frequency string
+
+const (
+	Hourly  Frequency = "HOURLY"
+	Daily   Frequency = "DAILY"
+	Weekly  Frequency = "WEEKLY"
+	Monthly Frequency = "MONTHLY"
+)
+
+// Interval returns the refresh interval of the frequency.
+func (f Frequency) Interval() time.Duration {
+	switch f {
+	case Hourly:
+		return time.Hour
+	case Weekly:
+		return 7 * 24 * time.Hour
+	case Monthly:
+		return 30 * 24 * time.Hour
+	default:
+		return 24 * time.Hour
+	}
+}
+
+// Status values match the sync_status enum.
+type Status string
+
+const (
+	StatusSynced    Status = "SYNCED"
+	StatusNotSynced Status = "NOT_SYNCED"
+	StatusError     Status = "ERROR"
+)
+
+// Entry is a row in sync_entries: one sync type for one target date.
+type Entry struct {
+	ID           int64
+	SyncType     string
+	TargetDate   string // YYYY-MM-DD
+	Frequency    Frequency
+	Status       Status
+	LastAttempt  time.Time // zero if never attempted
+	ErrorMessage string
+	RetryCount   int
+}
+
+// EntryStore persists sync entries.
+type EntryStore interface {
+	// Ensure creates the entry if it does not exist yet.
+	Ensure(ctx context.Context, syncType, targetDate string, freq Frequency) error
+	// Pending returns entries that are not synced or were synced on or after
+	// the given date and may need a refresh. ERROR entries that already failed
+	// maxRetries times are left out.
+	Pending(ctx context.Context, fromDate string, maxRetries int) ([]Entry, error)
+	MarkSynced(ctx context.Context, id int64, at time.Time) error
+	MarkError(ctx context.Context, id int64, at time.Time, message string) error
+	// MarkPending records an attempt whose data was not available yet,
+	// keeping the status and retry count.
+	MarkPending(ctx context.Context, id int64, at time.Time, message string) error
+}
+
+// PostgresEntryStore stores entries in the sync_entries table.
+type PostgresEntryStore struct {
+	db *sql.DB
+}
+
+func NewPostgresEntryStore(db *sql.DB) *PostgresEntryStore {
+	return &PostgresEntryStore{db: db}
+}
+
+func (p *PostgresEntryStore) Ensure(ctx context.Context, syncType, targetDate string, freq Frequency) error {
+	_, err := p.db.ExecContext(ctx, `
+		INSERT INTO sync_entries (sync_type, target_date, frequency)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (sync_type, target_date) DO NOTHING`, syncType, targetDate, string(freq))
+	if err != nil {
+		return fmt.Errorf("creating sync entry %s %s: %w", syncType, targetDate, err)
+	}
+	return nil
+}
+
+func (p *PostgresEntryStore) Pending(ctx context.Context, fromDate string, maxRetries int) ([]Entry, error) {
+	rows, err := p.db.QueryContext(ctx, `
+		SELECT id, sync_type, to_char(target_date, 'YYYY-MM-DD'), frequency, status,
+		       last_attempt, COALESCE(error_message, ''), COALESCE(retry_count, 0)
+		FROM sync_entries
+		WHERE (status <> 'SYNCED' OR target_date >= $1)
+		  AND NOT (status = 'ERROR' AND COALESCE(retry_count, 0) >= $2)
+		ORDER BY target_date, sync_type`, fromDate, maxRetries)
+	if err != nil {
+		return nil, fmt.Errorf("querying sync entries: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []Entry
+	for rows.Next() {
+		var e Entry
+		var freq, status string
+		var lastAttempt sql.NullTime
+		if err := rows.Scan(&e.ID, &e.SyncType, &e.TargetDate, &freq, &status, &lastAttempt, &e.ErrorMessage, &e.RetryCount); err != nil {
+			return nil, fmt.Errorf("scanning sync entry: %w", err)
+		}
+		e.Frequency = Frequency(freq)
+		e.Status = Status(status)
+		if lastAttempt.Valid {
+			// last_attempt is a TIMESTAMP without time zone, always written in UTC
+			e.LastAttempt = time.Date(lastAttempt.Time.Year(), lastAttempt.Time.Month(), lastAttempt.Time.Day(),
+				lastAttempt.Time.Hour(), lastAttempt.Time.Minute(), lastAttempt.Time.Second(), lastAttempt.Time.Nanosecond(), time.UTC)
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+func (p *PostgresEntryStore) MarkSynced(ctx context.Context, id int64, at time.Time) error {
+	_, err := p.db.ExecContext(ctx, `
+		UPDATE sync_entries
+		SET status = 'SYNCED', last_attempt = $2, error_message = NULL, retry_count = 0, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1`, id, utcTimestamp(at))
+	if err != nil {
+		return fmt.Errorf("marking sync entry %d synced: %w", id, err)
+	}
+	return nil
+}
+
+func (p *PostgresEntryStore) MarkError(ctx context.Context, id int64, at time.Time, message string) error {
+	_, err := p.db.ExecContext(ctx, `
+		UPDATE sync_entries
+		SET status = 'ERROR', last_attempt = $2, error_message = $3, retry_count = COALESCE(retry_count, 0) + 1, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1`, id, utcTimestamp(at), message)
+	if err != nil {
+		return fmt.Errorf("marking sync entry %d failed: %w", id, err)
+	}
+	return nil
+}
+
+func (p *PostgresEntryStore) MarkPending(ctx context.Context, id int64, at time.Time, message string) error {
+	_, err := p.db.ExecContext(ctx, `
+		UPDATE sync_entries
+		SET last_attempt = $2, error_message = $3, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1`, id, utcTimestamp(at), message)
+	if err != nil {
+		return fmt.Errorf("marking sync entry %d pending: %w", id, err)
+	}
+	return nil
+}
+
+// utcTimestamp formats t for a TIMESTAMP without time zone column.
+func utcTimestamp(t time.Time) string {
+	return t.UTC().Format("2006-01-02 15:04:05.999999")
+}
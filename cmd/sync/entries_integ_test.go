@@ -0,0 +1,67 @@
+//go:build integration
+
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mikahozz/gohome/config"
+	"github.com/mikahozz/gohome/db"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPostgresEntryStore(t *testing.T) {
+	config.LoadEnv()
+	conn, err := db.Open()
+	if err != nil {
+		t.Skipf("database not available: %v", err)
+	}
+	defer conn.Close()
+
+	ctx := context.Background()
+	store := NewPostgresEntryStore(conn)
+	syncType := "INTEGRATION_TEST"
+	date := "2001-01-01"
+	defer conn.Exec(`DELETE FROM sync_entries WHERE sync_type = $1`, syncType)
+
+	require.NoError(t, store.Ensure(ctx, syncType, date, Hourly))
+	require.NoError(t, store.Ensure(ctx, syncType, date, Hourly), "ensure must be idempotent")
+
+	find := func() Entry {
+		entries, err := store.Pending(ctx, "2000-12-31", 10)
+		require.NoError(t, err)
+		for _, e := range entries {
+			if e.SyncType == syncType {
+				return e
+			}
+		}
+		t.Fatalf("entry not found")
+		return Entry{}
+	}
+	e := find()
+	assert.Equal(t, StatusNotSynced, e.Status)
+	assert.True(t, e.LastAttempt.IsZero())
+
+	at := time.Date(2001, 1, 1, 12, 30, 0, 0, time.UTC)
+	require.NoError(t, store.MarkError(ctx, e.ID, at, "failed"))
+	require.NoError(t, store.MarkError(ctx, e.ID, at, "failed again"))
+	e = find()
+	assert.Equal(t, StatusError, e.Status)
+	assert.Equal(t, 2, e.RetryCount)
+	assert.Equal(t, "failed again", e.ErrorMessage)
+	assert.True(t, e.LastAttempt.Equal(at), "got %v", e.LastAttempt)
+	entries, err := store.Pending(ctx, "2000-12-31", 2)
+	require.NoError(t, err)
+	for _, other := range entries {
+		assert.NotEqual(t, syncType, other.SyncType, "entries past the retry limit are not selected")
+	}
+
+	require.NoError(t, store.MarkSynced(ctx, e.ID, at.Add(time.Hour)))
+	e = find()
+	assert.Equal(t, StatusSynced, e.Status)
+	assert.Equal(t, 0, e.RetryCount)
+	assert.Empty(t, e.ErrorMessage)
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/mikahozz/gohome/config"
+	"github.com/mikahozz/gohome/db"
+	"github.com/mikahozz/gohome/integrations/fmi"
+	"github.com/mikahozz/gohome/integrations/mqtt"
+	"github.com/mikahozz/gohome/integrations/notify"
+	"github.com/mikahozz/gohome/integrations/shelly"
+	"github.com/mikahozz/gohome/integrations/spot"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+func main() {
+	// Flag defaults below are read from the environment
+	config.LoadEnv()
+	station := flag.String("station", string(fmi.ConfiguredStation()), "FMI station id (fmisid) for weather observations (default from FMI_STATION)")
+	place := flag.String("place", fmi.ConfiguredPlace(), "FMI place for the weather forecast (default from FMI_PLACE)")
+	interval := flag.Duration("interval", time.Minute, "how often due sync entries are checked")
+	powerInterval := flag.Duration("power-interval", time.Minute, "how often Shelly power meters are read (0 disables)")
+	flag.Parse()
+
+	zerolog.TimeFieldFormat = time.RFC3339
+	zerolog.SetGlobalLevel(zerolog.DebugLevel)
+
+	zone, err := time.LoadLocation("Europe/Helsinki")
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to load timezone")
+	}
+	conn, err := db.Open()
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to connect to database")
+	}
+	defer conn.Close()
+	source, err := spot.NewSpotServiceFromEnv()
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to create spot price client")
+	}
+
+	syncer := NewSyncer(NewPostgresEntryStore(conn), zone)
+	if syncer.Notifier, err = notify.FromEnv(); err != nil {
+		log.Fatal().Err(err).Msg("Invalid notification settings")
+	}
+	syncer.Register(spotPriceSync(spot.NewPriceRepository(conn, source)))
+	syncer.Register(weatherSync(SyncWeatherObservations, fmi.StationId(*station), fmi.Observations, fmi.GetWeatherData, conn))
+	syncer.Register(weatherSync(SyncWeatherForecast, fmi.StationId(*place), fmi.Forecast, fmi.GetWeatherData, conn))
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if *powerInterval > 0 {
+		if registry, err := shelly.Default(); err != nil {
+			log.Warn().Err(err).Msg("Shelly devices not configured, power readings are not collected")
+		} else {
+			go shelly.NewPowerPoller(registry, shelly.NewPowerRepository(conn)).Run(ctx, *powerInterval)
+		}
+	}
+
+	if cfg, err := mqtt.Default(); err != nil {
+		log.Warn().Err(err).Msg("MQTT not configured, sensor messages are not collected")
+	} else {
+		subscriber, err := mqtt.NewSubscriber(cfg, mqtt.NewDBStore(conn))
+		if err != nil {
+			log.Fatal().Err(err).Msg("Invalid MQTT configuration")
+		}
+		go func() {
+			if err := subscriber.Run(ctx); err != nil {
+				log.Error().Err(err).Str("event", "mqtt_error").Msg("MQTT subscriber stopped")
+			}
+		}()
+	}
+
+	log.Info().Str("event", "sync_started").Dur("interval", *interval).Msg("sync service running")
+	syncer.Run(ctx, *interval)
+	log.Info().Str("event", "sync_stopped").Msg("sync service stopped")
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/mikahozz/gohome/integrations/notify"
+	"github.com/rs/zerolog/log"
+)
+
+// ErrNotYetAvailable is returned by a Sync when the data of the date has not
+// been published yet.
+var ErrNotYetAvailable = errors.New("not yet available")
+
+// SyncType is a kind of data ingested per target date, e.g. SPOT_PRICE.
+type SyncType struct {
+	Name      string
+	Frequency Frequency
+	// Targets returns the dates that should have an entry at now.
+	Targets func(now time.Time) []time.Time
+	// Sync ingests data for the date (midnight in the syncer's location).
+	Sync func(ctx context.Context, date time.Time) error
+	// AvailableBy returns when the data of a date is published at the
+	// latest. Until then ErrNotYetAvailable leaves the entry pending without
+	// counting a retry or alerting. Nil treats it like any other error.
+	AvailableBy func(date time.Time) time.Time
+}
+
+// Syncer creates sync entries for the registered types and runs the due ones.
+type Syncer struct {
+	store    EntryStore
+	types    map[string]SyncType
+	order    []string
+	location *time.Location
+	now      func() time.Time
+
+	// RetryBase is the delay after the first failure, doubled on every retry
+	// up to RetryMax. After MaxRetries failures an entry is left in ERROR.
+	RetryBase  time.Duration
+	RetryMax   time.Duration
+	MaxRetries int
+	// Timeout bounds a single sync run.
+	Timeout time.Duration
+	// Notifier is told about failed syncs (optional).
+	Notifier notify.Notifier
+}
+
+func NewSyncer(store EntryStore, location *time.Location) *Syncer {
+	return &Syncer{
+		store:      store,
+		types:      make(map[string]SyncType),
+		location:   location,
+		now:        time.Now,
+		RetryBase:  5 * time.Minute,
+		RetryMax:   time.Hour,
+		MaxRetries: 24,
+		Timeout:    2 * time.Minute,
+	}
+}
+
+// Register adds a sync type. Types run in registration order.
+func (s *Syncer) Register(t SyncType) {
+	if _, ok := s.types[t.Name]; !ok {
+		s.order = append(s.order, t.Name)
+	}
+	s.types[t.Name] = t
+}
+
+// Run calls RunOnce immediately and then every interval until ctx is done.
+func (s *Syncer) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := s.RunOnce(ctx); err != nil {
+			log.Error().Err(err).Str("event", "sync_cycle_error").Msg("sync cycle failed")
+			s.alert(ctx, notify.Message{Title: "Sync cycle failed", Body: err.Error(), Key: "sync_cycle_error"})
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce creates missing entries and runs every entry that is due.
+func (s *Syncer) RunOnce(ctx context.Context) error {
+	now := s.now().In(s.location)
+	for _, name := range s.order {
+		t := s.types[name]
+		for _, date := range t.Targets(now) {
+			if err := s.store.Ensure(ctx, t.Name, date.Format("2006-01-02"), t.Frequency); err != nil {
+				return err
+			}
+		}
+	}
+
+	yesterday := now.AddDate(0, 0, -1).Format("2006-01-02")
+	entries, err := s.store.Pending(ctx, yesterday, s.MaxRetries)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		t, ok := s.types[e.SyncType]
+		if !ok || !s.isDue(e, now) {
+			continue
+		}
+		s.run(ctx, t, e)
+	}
+	return nil
+}
+
+func (s *Syncer) run(ctx context.Context, t SyncType, e Entry) {
+	date, err := time.ParseInLocation("2006-01-02", e.TargetDate, s.location)
+	if err != nil {
+		log.Error().Err(err).Str("event", "sync_error").Int64("id", e.ID).Msg("invalid target date")
+		return
+	}
+	logger := log.With().Str("sync_type", e.SyncType).Str("target_date", e.TargetDate).Logger()
+	logger.Info().Str("event", "sync_start").Int("retry_count", e.RetryCount).Msg("syncing")
+
+	runCtx, cancel := context.WithTimeout(ctx, s.Timeout)
+	err = safeSync(runCtx, t, date)
+	cancel()
+
+	at := s.now()
+	if errors.Is(err, ErrNotYetAvailable) && t.AvailableBy != nil && at.Before(t.AvailableBy(date)) {
+		logger.Info().Err(err).Str("event", "sync_not_available").Time("available_by", t.AvailableBy(date)).
+			Dur("retry_in", s.RetryBase).Msg("data not published yet")
+		if markErr := s.store.MarkPending(ctx, e.ID, at, err.Error()); markErr != nil {
+			logger.Error().Err(markErr).Msg("")
+		}
+		return
+	}
+	if err != nil {
+		logger.Error().Err(err).Str("event", "sync_error").Int("retry_count", e.RetryCount+1).
+			Dur("retry_in", s.backoff(e.RetryCount+1)).Msg("sync failed")
+		if markErr := s.store.MarkError(ctx, e.ID, at, err.Error()); markErr != nil {
+			logger.Error().Err(markErr).Msg("")
+		}
+		s.alertFailed(ctx, e, err)
+		return
+	}
+	logger.Info().Str("event", "sync_success").Msg("synced")
+	if e.Status == StatusError {
+		s.alert(ctx, notify.Message{
+			Title:    fmt.Sprintf("Sync recovered: %s %s", e.SyncType, e.TargetDate),
+			Body:     fmt.Sprintf("Synced after %d failed attempts.", e.RetryCount),
+			Priority: notify.Low,
+			Key:      "sync_recovered:" + e.SyncType,
+		})
+	}
+	if markErr := s.store.MarkSynced(ctx, e.ID, at); markErr != nil {
+		logger.Error().Err(markErr).Msg("")
+	}
+}
+
+// alertFailed reports a failed sync, with high priority once the entry is
+// no longer retried.
+func (s *Syncer) alertFailed(ctx context.Context, e Entry, err error) {
+	retries := e.RetryCount + 1
+	m := notify.Message{
+		Title: fmt.Sprintf("Sync failed: %s %s", e.SyncType, e.TargetDate),
+		Body:  fmt.Sprintf("%v\nAttempt %d, retrying in %s.", err, retries, s.backoff(retries)),
+		Key:   "sync_error:" + e.SyncType,
+	}
+	if retries >= s.MaxRetries {
+		m.Title = fmt.Sprintf("Sync gave up: %s %s", e.SyncType, e.TargetDate)
+		m.Body = fmt.Sprintf("%v\nNot retried after %d attempts.", err, retries)
+		m.Priority = notify.High
+		m.Key = "sync_gave_up:" + e.SyncType
+	}
+	s.alert(ctx, m)
+}
+
+// alert sends a notification when a notifier is set. Failures are only logged.
+func (s *Syncer) alert(ctx context.Context, m notify.Message) {
+	if s.Notifier == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	if err := s.Notifier.Notify(ctx, m); err != nil {
+		log.Error().Err(err).Str("event", "notify_error").Str("key", m.Key).Msg("failed to send notification")
+	}
+}
+
+func safeSync(ctx context.Context, t SyncType, date time.Time) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return t.Sync(ctx, date)
+}
+
+// isDue reports whether an entry should run at now.
+//   - NOT_SYNCED entries run right away, and every RetryBase while their data
+//     is not yet available.
+//   - ERROR entries are retried with exponential backoff until MaxRetries.
+//   - SYNCED entries are refreshed every Frequency while the target date is
+//     still going on, and once more after it ends to pick up its last hours.
+func (s *Syncer) isDue(e Entry, now time.Time) bool {
+	switch e.Status {
+	case StatusNotSynced:
+		return e.LastAttempt.IsZero() || !now.Before(e.LastAttempt.Add(s.RetryBase))
+	case StatusError:
+		if e.RetryCount >= s.MaxRetries {
+			return false
+		}
+		return !now.Before(e.LastAttempt.Add(s.backoff(e.RetryCount)))
+	case StatusSynced:
+		date, err := time.ParseInLocation("2006-01-02", e.TargetDate, s.location)
+		if err != nil {
+			return false
+		}
+		dayEnd := date.AddDate(0, 0, 1)
+		if !e.LastAttempt.Before(dayEnd) {
+			return false
+		}
+		return !now.Before(dayEnd) || !now.Before(e.LastAttempt.Add(e.Frequency.Interval()))
+	}
+	return false
+}
+
+// backoff returns the wait before retry number retries (1 = first retry).
+func (s *Syncer) backoff(retries int) time.Duration {
+	d := s.RetryBase
+	for i := 1; i < retries && d < s.RetryMax; i++ {
+		d *= 2
+	}
+	if d > s.RetryMax {
+		d = s.RetryMax
+	}
+	return d
+}
@@ -0,0 +1,324 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/mikahozz/gohome/integrations/fmi"
+	"github.com/mikahozz/gohome/integrations/notify"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var zone, _ = time.LoadLocation("Europe/Helsinki")
+
+// memoryEntryStore mirrors PostgresEntryStore semantics in memory.
+type memoryEntryStore struct {
+	entries []*Entry
+}
+
+func (m *memoryEntryStore) find(syncType, date string) *Entry {
+	for _, e := range m.entries {
+		if e.SyncType == syncType && e.TargetDate == date {
+			return e
+		}
+	}
+	return nil
+}
+
+func (m *memoryEntryStore) Ensure(ctx context.Context, syncType, targetDate string, freq Frequency) error {
+	if m.find(syncType, targetDate) == nil {
+		m.entries = append(m.entries, &Entry{ID: int64(len(m.entries) + 1), SyncType: syncType, TargetDate: targetDate, Frequency: freq, Status: StatusNotSynced})
+	}
+	return nil
+}
+
+func (m *memoryEntryStore) Pending(ctx context.Context, fromDate string, maxRetries int) ([]Entry, error) {
+	var result []Entry
+	for _, e := range m.entries {
+		if e.Status == StatusError && e.RetryCount >= maxRetries {
+			continue
+		}
+		if e.Status != StatusSynced || e.TargetDate >= fromDate {
+			result = append(result, *e)
+		}
+	}
+	return result, nil
+}
+
+func (m *memoryEntryStore) MarkSynced(ctx context.Context, id int64, at time.Time) error {
+	e := m.entries[id-1]
+	e.Status, e.LastAttempt, e.ErrorMessage, e.RetryCount = StatusSynced, at, "", 0
+	return nil
+}
+
+func (m *memoryEntryStore) MarkError(ctx context.Context, id int64, at time.Time, message string) error {
+	e := m.entries[id-1]
+	e.Status, e.LastAttempt, e.ErrorMessage = StatusError, at, message
+	e.RetryCount++
+	return nil
+}
+
+func (m *memoryEntryStore) MarkPending(ctx context.Context, id int64, at time.Time, message string) error {
+	e := m.entries[id-1]
+	e.LastAttempt, e.ErrorMessage = at, message
+	return nil
+}
+
+func newTestSyncer(now *time.Time) (*Syncer, *memoryEntryStore) {
+	store := &memoryEntryStore{}
+	s := NewSyncer(store, zone)
+	s.now = func() time.Time { return *now }
+	return s, store
+}
+
+func TestSyncer_CreatesEntriesAndMarksSynced(t *testing.T) {
+	now := time.Date(2025, 3, 10, 9, 0, 0, 0, zone)
+	s, store := newTestSyncer(&now)
+	var synced []string
+	s.Register(SyncType{
+		Name:      SyncSpotPrice,
+		Frequency: Daily,
+		Targets:   todayAndTomorrow,
+		Sync: func(ctx context.Context, date time.Time) error {
+			synced = append(synced, date.Format("2006-01-02"))
+			assert.Equal(t, zone, date.Location())
+			return nil
+		},
+	})
+
+	require.NoError(t, s.RunOnce(context.Background()))
+	assert.Equal(t, []string{"2025-03-10", "2025-03-11"}, synced)
+	require.Len(t, store.entries, 2)
+	for _, e := range store.entries {
+		assert.Equal(t, StatusSynced, e.Status)
+		assert.Equal(t, Daily, e.Frequency)
+	}
+
+	// Nothing is due again within the day
+	now = now.Add(time.Hour)
+	require.NoError(t, s.RunOnce(context.Background()))
+	assert.Len(t, synced, 2)
+}
+
+func TestSyncer_RetriesWithBackoff(t *testing.T) {
+	now := time.Date(2025, 3, 10, 9, 0, 0, 0, zone)
+	s, store := newTestSyncer(&now)
+	s.MaxRetries = 3
+	attempts := 0
+	s.Register(SyncType{
+		Name:      SyncWeatherObservations,
+		Frequency: Hourly,
+		Targets:   today,
+		Sync: func(ctx context.Context, date time.Time) error {
+			attempts++
+			if attempts < 3 {
+				return errors.New("fmi unavailable")
+			}
+			return nil
+		},
+	})
+
+	ctx := context.Background()
+	require.NoError(t, s.RunOnce(ctx))
+	e := store.entries[0]
+	assert.Equal(t, StatusError, e.Status)
+	assert.Equal(t, "fmi unavailable", e.ErrorMessage)
+	assert.Equal(t, 1, e.RetryCount)
+
+	// First retry waits RetryBase
+	now = now.Add(4 * time.Minute)
+	require.NoError(t, s.RunOnce(ctx))
+	assert.Equal(t, 1, attempts)
+	now = now.Add(time.Minute)
+	require.NoError(t, s.RunOnce(ctx))
+	assert.Equal(t, 2, attempts)
+	assert.Equal(t, 2, e.RetryCount)
+
+	// Second retry waits twice as long
+	now = now.Add(5 * time.Minute)
+	require.NoError(t, s.RunOnce(ctx))
+	assert.Equal(t, 2, attempts)
+	now = now.Add(5 * time.Minute)
+	require.NoError(t, s.RunOnce(ctx))
+	assert.Equal(t, 3, attempts)
+	assert.Equal(t, StatusSynced, e.Status)
+	assert.Equal(t, 0, e.RetryCount)
+	assert.Empty(t, e.ErrorMessage)
+}
+
+func TestSyncer_GivesUpAfterMaxRetries(t *testing.T) {
+	now := time.Date(2025, 3, 10, 9, 0, 0, 0, zone)
+	s, store := newTestSyncer(&now)
+	s.MaxRetries = 2
+	attempts := 0
+	s.Register(SyncType{
+		Name: SyncWeatherForecast, Frequency: Hourly, Targets: today,
+		Sync: func(ctx context.Context, date time.Time) error { attempts++; panic("boom") },
+	})
+	for i := 0; i < 5; i++ {
+		require.NoError(t, s.RunOnce(context.Background()))
+		now = now.Add(2 * time.Hour)
+	}
+	assert.Equal(t, 2, attempts)
+	assert.Equal(t, StatusError, store.entries[0].Status)
+	assert.Contains(t, store.entries[0].ErrorMessage, "panic: boom")
+	pending, err := store.Pending(context.Background(), "2025-03-09", s.MaxRetries)
+	require.NoError(t, err)
+	assert.Empty(t, pending, "entries that gave up are no longer selected")
+}
+
+func TestSyncer_RefreshesSyncedEntries(t *testing.T) {
+	now := time.Date(2025, 3, 10, 23, 10, 0, 0, zone)
+	s, _ := newTestSyncer(&now)
+	var synced []string
+	s.Register(SyncType{
+		Name: SyncWeatherObservations, Frequency: Hourly, Targets: today,
+		Sync: func(ctx context.Context, date time.Time) error {
+			synced = append(synced, date.Format("2006-01-02")+" "+now.Format("15:04"))
+			return nil
+		},
+	})
+	ctx := context.Background()
+	require.NoError(t, s.RunOnce(ctx))
+
+	// Not refreshed within the hour
+	now = now.Add(30 * time.Minute)
+	require.NoError(t, s.RunOnce(ctx))
+	// After midnight the previous day runs once more and today's entry starts
+	now = now.Add(30 * time.Minute)
+	require.NoError(t, s.RunOnce(ctx))
+	now = now.Add(2 * time.Hour)
+	require.NoError(t, s.RunOnce(ctx))
+
+	assert.Equal(t, []string{
+		"2025-03-10 23:10",
+		"2025-03-10 00:10",
+		"2025-03-11 00:10",
+		"2025-03-11 02:10",
+	}, synced)
+}
+
+func TestWeatherMeasurements(t *testing.T) {
+	data := fmi.WeatherDataModel{WeatherData: []fmi.WeatherData{
+		{Time: "2025-03-09T21:50:00Z", Temp: 1},
+		{Time: "2025-03-09T22:00:00Z", Temp: 2}, // 00:00 in Helsinki
+		{Time: "2025-03-10T21:50:00Z", Temp: 3},
+		{Time: "2025-03-10T22:00:00Z", Temp: 4},
+	}}
+	date := time.Date(2025, 3, 10, 0, 0, 0, 0, zone)
+	ms, err := weatherMeasurements(data, "fmi_observations_101004", date)
+	require.NoError(t, err)
+	require.Len(t, ms, 2)
+	assert.Equal(t, 2.0, *ms[0].MainValue)
+	assert.Equal(t, 3.0, *ms[1].MainValue)
+	assert.Equal(t, "fmi_observations_101004", ms[0].SensorID)
+	assert.Equal(t, data.WeatherData[1], ms[0].Value)
+
+	_, err = weatherMeasurements(fmi.WeatherDataModel{WeatherData: []fmi.WeatherData{{Time: "bad"}}}, "x", date)
+	assert.Error(t, err)
+}
+
+type recordingNotifier struct {
+	messages []notify.Message
+}
+
+func (r *recordingNotifier) Notify(ctx context.Context, m notify.Message) error {
+	r.messages = append(r.messages, m)
+	return nil
+}
+
+func TestSyncer_Notifies(t *testing.T) {
+	now := time.Date(2025, 3, 10, 9, 0, 0, 0, zone)
+	s, _ := newTestSyncer(&now)
+	s.MaxRetries = 2
+	rec := &recordingNotifier{}
+	s.Notifier = rec
+	failing := true
+	s.Register(SyncType{
+		Name:      SyncSpotPrice,
+		Frequency: Daily,
+		Targets:   today,
+		Sync: func(ctx context.Context, date time.Time) error {
+			if failing {
+				return errors.New("ENTSO-E unavailable")
+			}
+			return nil
+		},
+	})
+
+	ctx := context.Background()
+	require.NoError(t, s.RunOnce(ctx))
+	now = now.Add(5 * time.Minute)
+	require.NoError(t, s.RunOnce(ctx))
+	require.Len(t, rec.messages, 2)
+	assert.Equal(t, "Sync failed: SPOT_PRICE 2025-03-10", rec.messages[0].Title)
+	assert.Equal(t, "ENTSO-E unavailable\nAttempt 1, retrying in 5m0s.", rec.messages[0].Body)
+	assert.Equal(t, "sync_error:SPOT_PRICE", rec.messages[0].Key)
+	assert.Equal(t, "Sync gave up: SPOT_PRICE 2025-03-10", rec.messages[1].Title)
+	assert.Equal(t, notify.High, rec.messages[1].Priority)
+
+	// Tomorrow's entry fails once and then recovers
+	now = time.Date(2025, 3, 11, 9, 0, 0, 0, zone)
+	require.NoError(t, s.RunOnce(ctx))
+	failing = false
+	now = now.Add(5 * time.Minute)
+	require.NoError(t, s.RunOnce(ctx))
+	require.Len(t, rec.messages, 4)
+	assert.Equal(t, "Sync recovered: SPOT_PRICE 2025-03-11", rec.messages[3].Title)
+	assert.Equal(t, notify.Low, rec.messages[3].Priority)
+}
+
+func TestSyncer_NotYetAvailable(t *testing.T) {
+	now := time.Date(2025, 3, 10, 13, 0, 0, 0, zone)
+	s, store := newTestSyncer(&now)
+	rec := &recordingNotifier{}
+	s.Notifier = rec
+	published := time.Date(2025, 3, 10, 16, 0, 0, 0, zone)
+	attempts := 0
+	spot := spotPriceSync(nil)
+	s.Register(SyncType{
+		Name:        SyncSpotPrice,
+		Frequency:   Daily,
+		Targets:     func(now time.Time) []time.Time { return []time.Time{now.AddDate(0, 0, 1)} },
+		AvailableBy: spot.AvailableBy,
+		Sync: func(ctx context.Context, date time.Time) error {
+			attempts++
+			if now.Before(published) {
+				return fmt.Errorf("%w: got 0 of 24 prices", ErrNotYetAvailable)
+			}
+			return nil
+		},
+	})
+
+	// Before 15:00 the entry stays pending and is checked every RetryBase
+	ctx := context.Background()
+	require.NoError(t, s.RunOnce(ctx))
+	e := store.entries[0]
+	assert.Equal(t, StatusNotSynced, e.Status)
+	assert.Equal(t, 0, e.RetryCount)
+	assert.Contains(t, e.ErrorMessage, "not yet available")
+	now = now.Add(4 * time.Minute)
+	require.NoError(t, s.RunOnce(ctx))
+	assert.Equal(t, 1, attempts)
+	now = now.Add(time.Minute)
+	require.NoError(t, s.RunOnce(ctx))
+	assert.Equal(t, 2, attempts)
+	assert.Equal(t, 0, e.RetryCount)
+	assert.Empty(t, rec.messages)
+
+	// Still missing after 15:00 is a failure
+	now = time.Date(2025, 3, 10, 15, 0, 0, 0, zone)
+	require.NoError(t, s.RunOnce(ctx))
+	assert.Equal(t, StatusError, e.Status)
+	assert.Equal(t, 1, e.RetryCount)
+	require.Len(t, rec.messages, 1)
+	assert.Equal(t, "Sync failed: SPOT_PRICE 2025-03-11", rec.messages[0].Title)
+
+	now = published.Add(5 * time.Minute)
+	require.NoError(t, s.RunOnce(ctx))
+	assert.Equal(t, StatusSynced, e.Status)
+}
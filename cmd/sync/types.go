@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/mikahozz/gohome/db"
+	"github.com/mikahozz/gohome/integrations/fmi"
+	"github.com/mikahozz/gohome/integrations/spot"
+)
+
+// Sync type names stored in sync_entries.sync_type.
+const (
+	SyncSpotPrice           = "SPOT_PRICE"
+	SyncWeatherObservations = "WEATHER_OBSERVATIONS"
+	SyncWeatherForecast     = "WEATHER_FORECAST"
+)
+
+func today(now time.Time) []time.Time {
+	return []time.Time{now}
+}
+
+func todayAndTomorrow(now time.Time) []time.Time {
+	return []time.Time{now, now.AddDate(0, 0, 1)}
+}
+
+// spotPricesPublishedBy is the local hour of the previous day by which a day's
+// spot prices are published (normally around 14:00 Finnish time).
+const spotPricesPublishedBy = 15
+
+// spotPriceSync stores the day's spot prices. Tomorrow's prices are published
+// in the early afternoon; until spotPricesPublishedBy missing prices leave the
+// entry pending.
+func spotPriceSync(repo *spot.PriceRepository) SyncType {
+	return SyncType{
+		Name:      SyncSpotPrice,
+		Frequency: Daily,
+		Targets:   todayAndTomorrow,
+		AvailableBy: func(date time.Time) time.Time {
+			y, m, d := date.Date()
+			return time.Date(y, m, d-1, spotPricesPublishedBy, 0, 0, 0, date.Location())
+		},
+		Sync: func(ctx context.Context, date time.Time) error {
+			end := date.AddDate(0, 0, 1).Add(-repo.Resolution)
+			prices, err := repo.GetPrices(ctx, date, end, date.Location())
+			if err != nil {
+				return err
+			}
+			expected := int(end.Sub(date)/repo.Resolution) + 1
+			if len(prices.Prices) < expected {
+				return fmt.Errorf("%w: got %d of %d prices", ErrNotYetAvailable, len(prices.Prices), expected)
+			}
+			return nil
+		},
+	}
+}
+
+// weatherSync stores the FMI weather data of a station. Every fetched point
+// goes to the weather history (forecasts under their issue time), and the
+// points falling on the target date to measurements as
+// fmi_observations_<station> or fmi_forecast_<station>.
+func weatherSync(name string, station fmi.StationId, requestType fmi.RequestType, fetch func(fmi.StationId, fmi.RequestType) (fmi.WeatherDataModel, error), conn *sql.DB) SyncType {
+	sensorID := "fmi_observations_" + string(station)
+	if requestType == fmi.Forecast {
+		sensorID = "fmi_forecast_" + string(station)
+	}
+	history := fmi.NewWeatherRepository(conn)
+	return SyncType{
+		Name:      name,
+		Frequency: Hourly,
+		Targets:   today,
+		Sync: func(ctx context.Context, date time.Time) error {
+			data, err := fetch(station, requestType)
+			if err != nil {
+				return err
+			}
+			if _, err := history.Store(ctx, station, requestType, data); err != nil {
+				return fmt.Errorf("storing weather history: %w", err)
+			}
+			measurements, err := weatherMeasurements(data, sensorID, date)
+			if err != nil {
+				return err
+			}
+			if len(measurements) == 0 {
+				return fmt.Errorf("no weather data for %s", date.Format("2006-01-02"))
+			}
+			return db.UpsertMeasurements(ctx, conn, measurements)
+		},
+	}
+}
+
+// weatherMeasurements converts the weather data points falling on date
+// (midnight in its location) to measurements. Temperature is the main value,
+// NULL when FMI had no reading.
+func weatherMeasurements(data fmi.WeatherDataModel, sensorID string, date time.Time) ([]db.Measurement, error) {
+	dayEnd := date.AddDate(0, 0, 1)
+	var measurements []db.Measurement
+	for _, w := range data.WeatherData {
+		ts, err := time.Parse(time.RFC3339, w.Time)
+		if err != nil {
+			return nil, fmt.Errorf("parsing weather time %q: %w", w.Time, err)
+		}
+		if ts.Before(date) || !ts.Before(dayEnd) {
+			continue
+		}
+		m := db.Measurement{Timestamp: ts, SensorID: sensorID, Value: w}
+		if w.Has("temperature") {
+			temp := w.Temp
+			m.MainValue = &temp
+		}
+		measurements = append(measurements, m)
+	}
+	return measurements, nil
+}
@@ -0,0 +1,58 @@
+// Package db opens connections to the gohome PostgreSQL database defined in
+// docker-compose / db/init. Connection settings come from the same environment
+// variables used by the db container (see .env.tmpl).
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"net/url"
+	"os"
+
+	_ "github.com/lib/pq"
+)
+
+// DSN builds a PostgreSQL connection string from the environment. The
+// application user (DB_APP_USER) is used so services only get the privileges
+// granted in db/init/03_create_user.sh.
+func DSN() (string, error) {
+	user := os.Getenv("DB_APP_USER")
+	password := os.Getenv("DB_APP_PASSWORD")
+	name := os.Getenv("POSTGRES_DB")
+	if user == "" || name == "" {
+		return "", fmt.Errorf("DB_APP_USER and POSTGRES_DB must be set in environment")
+	}
+	host := os.Getenv("POSTGRES_HOST")
+	if host == "" {
+		host = "localhost"
+	}
+	port := os.Getenv("POSTGRES_PORT")
+	if port == "" {
+		port = "5432"
+	}
+	u := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(user, password),
+		Host:     host + ":" + port,
+		Path:     name,
+		RawQuery: "sslmode=disable",
+	}
+	return u.String(), nil
+}
+
+// Open connects to the database and verifies the connection with a ping.
+func Open() (*sql.DB, error) {
+	dsn, err := DSN()
+	if err != nil {
+		return nil, err
+	}
+	conn, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("opening database: %w", err)
+	}
+	if err := conn.Ping(); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("connecting to database: %w", err)
+	}
+	return conn, nil
+}
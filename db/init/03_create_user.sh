@@ -7,4 +7,9 @@ GRANT CONNECT ON DATABASE $POSTGRES_DB TO $DB_APP_USER;
 GRANT USAGE ON SCHEMA public TO $DB_APP_USER;
 GRANT SELECT, INSERT, UPDATE ON measurements TO $DB_APP_USER;
 GRANT USAGE, SELECT ON SEQUENCE measurements_id_seq TO $DB_APP_USER;
+GRANT SELECT, INSERT, UPDATE ON sync_entries TO $DB_APP_USER;
+GRANT USAGE, SELECT ON SEQUENCE sync_entries_id_seq TO $DB_APP_USER;
+-- Tables created by later init scripts
+ALTER DEFAULT PRIVILEGES IN SCHEMA public GRANT SELECT, INSERT, UPDATE ON TABLES TO $DB_APP_USER;
+ALTER DEFAULT PRIVILEGES IN SCHEMA public GRANT USAGE, SELECT ON SEQUENCES TO $DB_APP_USER;
 EOSQL
@@ -0,0 +1,19 @@
+-- The latest successful trigger of each scheduler schedule. The scheduler
+-- reads it on startup so a restart does not re-run actions that already fired
+-- today. The script is idempotent and can be run on existing databases.
+CREATE TABLE IF NOT EXISTS scheduler_triggers (
+    id BIGSERIAL PRIMARY KEY,
+    schedule_name VARCHAR(200) NOT NULL,
+    category VARCHAR(100),
+    triggered_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Earlier versions kept a row per trigger: keep only the latest per schedule
+DELETE FROM scheduler_triggers t
+USING scheduler_triggers newer
+WHERE t.schedule_name = newer.schedule_name
+  AND (t.triggered_at, t.id) < (newer.triggered_at, newer.id);
+
+DROP INDEX IF EXISTS idx_scheduler_triggers_name_time;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_scheduler_triggers_name ON scheduler_triggers(schedule_name);
@@ -0,0 +1,24 @@
+-- FMI observations and forecasts per station (fmisid or place). Every forecast
+-- run is kept under its issue time so forecasts can be compared with the
+-- observations later; for observations issue_time is the observation time.
+-- The script is idempotent and can be run on existing databases.
+CREATE TABLE IF NOT EXISTS weather_data (
+    id BIGSERIAL PRIMARY KEY,
+    station_id VARCHAR(100) NOT NULL,
+    request_type VARCHAR(20) NOT NULL,  -- 'OBSERVATIONS' or 'FORECAST'
+    issue_time TIMESTAMPTZ NOT NULL,
+    timestamp TIMESTAMPTZ NOT NULL,
+    temperature NUMERIC,                -- NULL when FMI had no reading
+    data JSONB NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (station_id, request_type, issue_time, timestamp)
+);
+
+CREATE INDEX IF NOT EXISTS idx_weather_data_station_time ON weather_data(station_id, request_type, timestamp DESC);
+
+DROP TRIGGER IF EXISTS update_weather_data_updated_at ON weather_data;
+CREATE TRIGGER update_weather_data_updated_at
+    BEFORE UPDATE ON weather_data
+    FOR EACH ROW
+    EXECUTE FUNCTION update_updated_at_column();
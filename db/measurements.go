@@ -0,0 +1,52 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Measurement is a row in the measurements table. Value is stored as JSONB;
+// MainValue is the number most queries need and may be nil.
+type Measurement struct {
+	Timestamp time.Time
+	SensorID  string
+	MainValue *float64
+	Value     interface{}
+}
+
+// UpsertMeasurements stores measurements in a single transaction, replacing
+// existing rows with the same timestamp and sensor.
+func UpsertMeasurements(ctx context.Context, conn *sql.DB, measurements []Measurement) error {
+	if len(measurements) == 0 {
+		return nil
+	}
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("beginning transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, `
+		INSERT INTO measurements (timestamp, sensor_id, main_value, value)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (timestamp, sensor_id)
+		DO UPDATE SET main_value = EXCLUDED.main_value, value = EXCLUDED.value`)
+	if err != nil {
+		return fmt.Errorf("preparing measurement upsert: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, m := range measurements {
+		value, err := json.Marshal(m.Value)
+		if err != nil {
+			return fmt.Errorf("encoding %s measurement at %s: %w", m.SensorID, m.Timestamp, err)
+		}
+		if _, err := stmt.ExecContext(ctx, m.Timestamp.UTC(), m.SensorID, m.MainValue, value); err != nil {
+			return fmt.Errorf("upserting %s measurement at %s: %w", m.SensorID, m.Timestamp, err)
+		}
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,170 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// ErrInvalidSeriesQuery is returned for queries outside the limits below.
+var ErrInvalidSeriesQuery = errors.New("invalid series query")
+
+// Limits protecting the database from expensive series queries.
+const (
+	MaxSeriesSensors = 10
+	MaxSeriesRange   = 366 * 24 * time.Hour
+	MinSeriesBucket  = time.Minute
+	// MaxSeriesPoints is the number of buckets a single series may have.
+	MaxSeriesPoints = 5000
+)
+
+// Aggregate combines the values in a bucket.
+type Aggregate string
+
+const (
+	AggAvg  Aggregate = "avg"
+	AggMin  Aggregate = "min"
+	AggMax  Aggregate = "max"
+	AggLast Aggregate = "last"
+)
+
+var aggregates = map[Aggregate]string{
+	AggAvg:  "avg(v)",
+	AggMin:  "min(v)",
+	AggMax:  "max(v)",
+	AggLast: "(array_agg(v ORDER BY timestamp DESC))[1]",
+}
+
+// MainValue is the field selecting measurements.main_value.
+const MainValue = "main_value"
+
+var fieldKey = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+
+// ParseField parses a field selection: "main_value" (or empty) for the
+// main_value column, "value" for a JSON value that is itself a number, or a
+// path into the JSON value such as "value->humidity" or
+// "value->ENERGY->Power". It returns nil for main_value.
+func ParseField(field string) ([]string, error) {
+	if field == "" || field == MainValue {
+		return nil, nil
+	}
+	parts := strings.Split(field, "->")
+	if parts[0] != "value" {
+		return nil, fmt.Errorf("%w: field %q, use main_value or value->key", ErrInvalidSeriesQuery, field)
+	}
+	path := parts[1:]
+	for _, key := range path {
+		if !fieldKey.MatchString(key) {
+			return nil, fmt.Errorf("%w: field %q, keys may contain letters, digits and _", ErrInvalidSeriesQuery, field)
+		}
+	}
+	return path, nil
+}
+
+// SeriesQuery selects time-bucketed values of sensors between Start
+// (inclusive) and End (exclusive). Buckets are aligned to Start.
+type SeriesQuery struct {
+	Sensors []string
+	Field   string // see ParseField
+	Start   time.Time
+	End     time.Time
+	Bucket  time.Duration
+	Agg     Aggregate
+}
+
+// Validate checks the query against the limits.
+func (q SeriesQuery) Validate() error {
+	if len(q.Sensors) == 0 {
+		return fmt.Errorf("%w: no sensor", ErrInvalidSeriesQuery)
+	}
+	if len(q.Sensors) > MaxSeriesSensors {
+		return fmt.Errorf("%w: %d sensors, at most %d", ErrInvalidSeriesQuery, len(q.Sensors), MaxSeriesSensors)
+	}
+	if _, ok := aggregates[q.Agg]; !ok {
+		return fmt.Errorf("%w: aggregate %q, use avg, min, max or last", ErrInvalidSeriesQuery, q.Agg)
+	}
+	if _, err := ParseField(q.Field); err != nil {
+		return err
+	}
+	if !q.End.After(q.Start) {
+		return fmt.Errorf("%w: end must be after start", ErrInvalidSeriesQuery)
+	}
+	if q.End.Sub(q.Start) > MaxSeriesRange {
+		return fmt.Errorf("%w: range longer than %d days", ErrInvalidSeriesQuery, MaxSeriesRange/(24*time.Hour))
+	}
+	if q.Bucket < MinSeriesBucket || q.Bucket%time.Second != 0 {
+		return fmt.Errorf("%w: bucket must be whole seconds and at least %s", ErrInvalidSeriesQuery, MinSeriesBucket)
+	}
+	if points := (q.End.Sub(q.Start) + q.Bucket - 1) / q.Bucket; points > MaxSeriesPoints {
+		return fmt.Errorf("%w: %d buckets, at most %d; use a larger bucket", ErrInvalidSeriesQuery, points, MaxSeriesPoints)
+	}
+	return nil
+}
+
+// Point is the aggregate of a bucket; Count is the number of values in it.
+type Point struct {
+	Time  time.Time `json:"time"`
+	Value float64   `json:"value"`
+	Count int       `json:"count"`
+}
+
+// Series is the result for one sensor. Buckets without values are omitted.
+type Series struct {
+	Sensor string  `json:"sensor"`
+	Points []Point `json:"points"`
+}
+
+// QuerySeries runs a validated query and returns a series per sensor in the
+// order given. Rows whose selected field is not a number are ignored.
+func QuerySeries(ctx context.Context, conn *sql.DB, q SeriesQuery) ([]Series, error) {
+	if err := q.Validate(); err != nil {
+		return nil, err
+	}
+	path, _ := ParseField(q.Field)
+	args := []interface{}{fmt.Sprintf("%d seconds", int64(q.Bucket/time.Second)), q.Start.UTC(), q.End.UTC(), pq.Array(q.Sensors)}
+	value := "main_value::float8"
+	if path != nil {
+		value = "CASE WHEN jsonb_typeof(value #> $5) = 'number' THEN (value #>> $5)::float8 END"
+		args = append(args, pq.Array(path))
+	}
+	rows, err := conn.QueryContext(ctx, fmt.Sprintf(`
+		SELECT sensor_id, date_bin($1::interval, timestamp, $2) AS bucket, %s, count(v)
+		FROM (
+			SELECT sensor_id, timestamp, %s AS v FROM measurements
+			WHERE sensor_id = ANY($4) AND timestamp >= $2 AND timestamp < $3
+		) m
+		WHERE v IS NOT NULL
+		GROUP BY sensor_id, bucket
+		ORDER BY sensor_id, bucket`, aggregates[q.Agg], value), args...)
+	if err != nil {
+		return nil, fmt.Errorf("querying series: %w", err)
+	}
+	defer rows.Close()
+
+	points := make(map[string][]Point, len(q.Sensors))
+	for rows.Next() {
+		var sensor string
+		var p Point
+		if err := rows.Scan(&sensor, &p.Time, &p.Value, &p.Count); err != nil {
+			return nil, fmt.Errorf("scanning series: %w", err)
+		}
+		points[sensor] = append(points[sensor], p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("reading series: %w", err)
+	}
+	series := make([]Series, len(q.Sensors))
+	for i, sensor := range q.Sensors {
+		series[i] = Series{Sensor: sensor, Points: points[sensor]}
+		if series[i].Points == nil {
+			series[i].Points = []Point{}
+		}
+	}
+	return series, nil
+}
@@ -0,0 +1,62 @@
+//go:build integration
+
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mikahozz/gohome/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQuerySeries(t *testing.T) {
+	config.LoadEnv()
+	conn, err := Open()
+	if err != nil {
+		t.Skipf("database not available: %v", err)
+	}
+	defer conn.Close()
+
+	sensors := []string{"series_test_a", "series_test_b"}
+	cleanup := func() {
+		conn.Exec(`DELETE FROM measurements WHERE sensor_id = ANY($1)`, "{series_test_a,series_test_b}")
+	}
+	cleanup()
+	defer cleanup()
+
+	ctx := context.Background()
+	start := time.Date(2001, 1, 1, 0, 0, 0, 0, time.UTC)
+	value := func(v float64) *float64 { return &v }
+	require.NoError(t, UpsertMeasurements(ctx, conn, []Measurement{
+		{Timestamp: start, SensorID: sensors[0], MainValue: value(20), Value: map[string]interface{}{"humidity": 40}},
+		{Timestamp: start.Add(20 * time.Minute), SensorID: sensors[0], MainValue: value(22), Value: map[string]interface{}{"humidity": 50}},
+		{Timestamp: start.Add(70 * time.Minute), SensorID: sensors[0], MainValue: value(23), Value: map[string]interface{}{"humidity": "n/a"}},
+		{Timestamp: start.Add(30 * time.Minute), SensorID: sensors[1], MainValue: value(5), Value: 5},
+	}))
+
+	q := SeriesQuery{Sensors: append(sensors, "series_test_missing"), Start: start, End: start.Add(3 * time.Hour), Bucket: time.Hour, Agg: AggAvg}
+	series, err := QuerySeries(ctx, conn, q)
+	require.NoError(t, err)
+	require.Len(t, series, 3)
+	require.Len(t, series[0].Points, 2)
+	assert.True(t, series[0].Points[0].Time.Equal(start))
+	assert.Equal(t, 21.0, series[0].Points[0].Value)
+	assert.Equal(t, 2, series[0].Points[0].Count)
+	assert.Equal(t, 5.0, series[1].Points[0].Value)
+	assert.Empty(t, series[2].Points)
+
+	q.Agg, q.Field = AggLast, "value->humidity"
+	series, err = QuerySeries(ctx, conn, q)
+	require.NoError(t, err)
+	require.Len(t, series[0].Points, 1, "non-numeric humidity is ignored")
+	assert.Equal(t, 50.0, series[0].Points[0].Value)
+	assert.Empty(t, series[1].Points)
+
+	q.Field = "value"
+	series, err = QuerySeries(ctx, conn, q)
+	require.NoError(t, err)
+	assert.Equal(t, 5.0, series[1].Points[0].Value)
+}
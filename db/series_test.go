@@ -0,0 +1,51 @@
+package db
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseField(t *testing.T) {
+	for field, want := range map[string][]string{
+		"":                     nil,
+		"main_value":           nil,
+		"value":                {},
+		"value->humidity":      {"humidity"},
+		"value->ENERGY->Power": {"ENERGY", "Power"},
+	} {
+		path, err := ParseField(field)
+		require.NoError(t, err, field)
+		assert.Equal(t, want, path, field)
+	}
+	for _, field := range []string{"humidity", "value->", "value->a b", "value->>humidity", "value->'x'"} {
+		_, err := ParseField(field)
+		assert.True(t, errors.Is(err, ErrInvalidSeriesQuery), field)
+	}
+}
+
+func TestSeriesQuery_Validate(t *testing.T) {
+	start := time.Date(2025, 11, 1, 0, 0, 0, 0, time.UTC)
+	valid := SeriesQuery{Sensors: []string{"indoor_upstairs"}, Start: start, End: start.Add(24 * time.Hour), Bucket: time.Hour, Agg: AggAvg}
+	require.NoError(t, valid.Validate())
+
+	for name, modify := range map[string]func(*SeriesQuery){
+		"no sensor":     func(q *SeriesQuery) { q.Sensors = nil },
+		"many sensors":  func(q *SeriesQuery) { q.Sensors = make([]string, MaxSeriesSensors+1) },
+		"aggregate":     func(q *SeriesQuery) { q.Agg = "sum" },
+		"field":         func(q *SeriesQuery) { q.Field = "value->a;b" },
+		"end <= start":  func(q *SeriesQuery) { q.End = q.Start },
+		"long range":    func(q *SeriesQuery) { q.End = q.Start.Add(MaxSeriesRange + time.Hour) },
+		"small bucket":  func(q *SeriesQuery) { q.Bucket = time.Second },
+		"bucket millis": func(q *SeriesQuery) { q.Bucket = time.Minute + time.Millisecond },
+		"many buckets":  func(q *SeriesQuery) { q.End = q.Start.Add(30 * 24 * time.Hour); q.Bucket = time.Minute },
+	} {
+		q := valid
+		modify(&q)
+		err := q.Validate()
+		assert.True(t, errors.Is(err, ErrInvalidSeriesQuery), "%s: %v", name, err)
+	}
+}
@@ -1,11 +1,11 @@
 package cal
 
 import (
+	"errors"
 	"fmt"
 	"os"
+	"sync"
 	"time"
-
-	"github.com/joho/godotenv"
 )
 
 type Config struct {
@@ -16,35 +16,46 @@ type Config struct {
 	baseTimezone *time.Location
 }
 
-var config Config
+var (
+	config     Config
+	configOnce sync.Once
+	configErr  error
+)
 
-func init() {
-	err := godotenv.Load()
-	if err != nil {
-		panic(fmt.Sprintf("error loading .env file: %v", err))
-	}
+// loadConfig reads the calendar settings from the environment (and .env when
+// present) on first use, so importing the package does not require them.
+func loadConfig() error {
+	configOnce.Do(func() {
+		configErr = readConfig()
+	})
+	return configErr
+}
+
+func readConfig() error {
 	config.username = os.ExpandEnv("$CAL_USERNAME")
 	if config.username == "" {
-		panic("CAL_USERNAME env not set")
+		return errors.New("CAL_USERNAME env not set")
 	}
 	config.password = os.ExpandEnv("$CAL_PASSWORD")
 	if config.password == "" {
-		panic("CAL_PASSWORD env not set")
+		return errors.New("CAL_PASSWORD env not set")
 	}
 	config.calUrl = os.ExpandEnv("$CAL_URL")
 	if config.calUrl == "" {
-		panic("CAL_URL env not set")
+		return errors.New("CAL_URL env not set")
 	}
 	config.calName = os.ExpandEnv("$CAL_NAME")
 	if config.calName == "" {
-		panic("CAL_NAME env not set")
+		return errors.New("CAL_NAME env not set")
 	}
 	zone := os.ExpandEnv("$CAL_BASE_TIMEZONE")
 	if zone == "" {
-		panic("CAL_BASE_TIMEZONE env not set")
+		return errors.New("CAL_BASE_TIMEZONE env not set")
 	}
+	var err error
 	config.baseTimezone, err = time.LoadLocation(zone)
 	if err != nil {
-		panic(fmt.Sprintf("error loading timezone. It should be a valid IANA Time Zone: %v", err))
+		return fmt.Errorf("error loading timezone. It should be a valid IANA Time Zone: %w", err)
 	}
+	return nil
 }
@@ -48,6 +48,9 @@ func (d DateOffset) String() string {
 // The function returns a slice of Event structs and an error. If the function succeeds, the error is nil.
 // If the function fails, the slice is nil and the error contains details about the failure.
 func GetFamilyCalendarEvents(from DateOffset, to DateOffset) ([]Event, error) {
+	if err := loadConfig(); err != nil {
+		return nil, fmt.Errorf("calendar not configured: %w", err)
+	}
 	reqStart := time.Now().AddDate(from.Years, from.Months, from.Days)
 	reqEnd := time.Now().AddDate(to.Years, to.Months, to.Days)
 
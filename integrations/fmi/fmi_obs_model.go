@@ -4,7 +4,6 @@ import (
 	"encoding/xml"
 	"fmt"
 	"io/ioutil"
-	"math"
 	"net/http"
 	"regexp"
 	"strconv"
@@ -26,6 +25,10 @@ type ObservationCollection struct {
 	EndPosition   string     `xml:"member>GridSeriesObservation>phenomenonTime>TimePeriod>endPosition" validate:"required,ISO8601date"`
 	Measures      string     `xml:"member>GridSeriesObservation>result>MultiPointCoverage>rangeSet>DataBlock>doubleOrNilReasonTupleList" validate:"required"`
 	Fields        []Field    `xml:"member>GridSeriesObservation>result>MultiPointCoverage>rangeType>DataRecord>field" validate:"gt=3,dive"`
+	// ResultTime is when the data was produced; AnalysisTime is the model run
+	// a forecast is based on.
+	ResultTime   string `xml:"member>GridSeriesObservation>resultTime>TimeInstant>timePosition"`
+	AnalysisTime string `xml:"member>GridSeriesObservation>parameter>NamedValue>value>TimeInstant>timePosition"`
 }
 type Field struct {
 	Name string `xml:"name,attr" validate:"required"`
@@ -44,17 +47,31 @@ const (
 	Forecast
 )
 
+// LoadObservations loads observations of the station with fmisid location,
+// or the forecast for the place named by location.
 func (obs *FMI_ObservationsModel) LoadObservations(location StationId, requestType RequestType) error {
+	if requestType == Forecast {
+		return obs.LoadWeather(Location{Place: string(location)}, requestType)
+	}
+	return obs.LoadWeather(Location{FMISID: location}, requestType)
+}
+
+// LoadWeather loads observations or the forecast for a station or place.
+func (obs *FMI_ObservationsModel) LoadWeather(location Location, requestType RequestType) error {
+	where, err := location.query()
+	if err != nil {
+		return err
+	}
 	q := ""
 	switch requestType {
 	case Observations:
 		obs.Observations.Resolution = Minutes
-		q = fmt.Sprintf("http://opendata.fmi.fi/wfs?service=WFS&version=2.0.0&request=getFeature&storedquery_id=fmi::observations::weather::multipointcoverage&fmisid=%s",
-			location)
+		q = fmt.Sprintf("http://opendata.fmi.fi/wfs?service=WFS&version=2.0.0&request=getFeature&storedquery_id=fmi::observations::weather::multipointcoverage&%s",
+			where)
 	case Forecast:
 		obs.Observations.Resolution = Hours
-		q = fmt.Sprintf("http://opendata.fmi.fi/wfs?service=WFS&version=2.0.0&request=getFeature&storedquery_id=fmi::forecast::harmonie::surface::point::multipointcoverage&parameters=Temperature,Humidity,WindSpeedMS,WindGust,WindDirection,precipitation1h,Pressure,DewPoint,Visibility,TotalCloudCover,SmartSymbol&place=%s",
-			location)
+		q = fmt.Sprintf("http://opendata.fmi.fi/wfs?service=WFS&version=2.0.0&request=getFeature&storedquery_id=fmi::forecast::harmonie::surface::point::multipointcoverage&parameters=Temperature,Humidity,WindSpeedMS,WindGust,WindDirection,precipitation1h,Pressure,DewPoint,Visibility,TotalCloudCover,SmartSymbol&%s",
+			where)
 	default:
 		return errors.Errorf("Invalid requestType: %v", requestType)
 	}
@@ -110,6 +127,12 @@ func (fm FMI_ObservationsModel) ConvertToWeatherData() (WeatherDataModel, error)
 	if err != nil {
 		return wData, errors.Wrapf(err, "Failed to parse date: %s", obs.BeginPosition)
 	}
+	for _, issued := range []string{obs.AnalysisTime, obs.ResultTime} {
+		if t, err := time.Parse(time.RFC3339, issued); err == nil {
+			wData.IssueTime = t.UTC()
+			break
+		}
+	}
 	dt := beginDate
 	var timeAdd time.Duration
 	if obs.Resolution == Hours {
@@ -133,39 +156,39 @@ func (fm FMI_ObservationsModel) ConvertToWeatherData() (WeatherDataModel, error)
 			}
 			switch field.Name {
 			case "TA_PT1H_AVG", "t2m", "Temperature":
-				w.Temp = valueOrZero(value)
+				w.Temp = w.value("temperature", value)
 			case "TA_PT1H_MAX":
-				w.TempMax = valueOrZero(value)
+				w.TempMax = w.value("temp_max", value)
 			case "TA_PT1H_MIN":
-				w.TempMin = valueOrZero(value)
+				w.TempMin = w.value("temp_min", value)
 			case "RH_PT1H_AVG", "rh", "Humidity":
-				w.Humidity = valueOrZero(value)
+				w.Humidity = w.value("humidity", value)
 			case "WS_PT1H_AVG", "ws_10min", "WindSpeedMS":
-				w.WindSpeed = valueOrZero(value)
+				w.WindSpeed = w.value("wind_speed", value)
 			case "WS_PT1H_MAX", "wg_10min", "WindGust":
-				w.MaxWindSpeed = valueOrZero(value)
+				w.MaxWindSpeed = w.value("max_wind", value)
 			case "WS_PT1H_MIN":
-				w.MinWindSpeed = valueOrZero(value)
+				w.MinWindSpeed = w.value("min_wind", value)
 			case "WD_PT1H_AVG", "wd_10min", "WindDirection":
-				w.WindDirection = valueOrZero(value)
+				w.WindDirection = w.value("wind_dir", value)
 			case "PRA_PT1H_ACC", "r_1h", "precipitation1h":
-				w.Rain = valueOrZero(value)
+				w.Rain = w.value("rain", value)
 			case "PRI_PT1H_MAX", "ri_10min":
-				w.MaxRainIntensity = valueOrZero(value)
+				w.MaxRainIntensity = w.value("max_rain", value)
 			case "PA_PT1H_AVG", "p_sea", "Pressure":
-				w.Pressure = valueOrZero(value)
+				w.Pressure = w.value("pressure", value)
 			case "WAWA_PT1H_RANK", "wawa":
-				w.Weather = int(valueOrZero(value))
+				w.Weather = int(w.value("weather", value))
 			case "td", "DewPoint":
-				w.DewPoint = valueOrZero(value)
+				w.DewPoint = w.value("dew", value)
 			case "snow_aws":
-				w.SnowDepth = valueOrZero(value)
+				w.SnowDepth = w.value("snow", value)
 			case "vis", "Visibility":
-				w.Visibility = valueOrZero(value)
+				w.Visibility = w.value("visibility", value)
 			case "n_man", "TotalCloudCover":
-				w.CloudCover = valueOrZero(value)
+				w.CloudCover = w.value("clouds", value)
 			case "SmartSymbol":
-				w.Weather = int(valueOrZero(value))
+				w.Weather = int(w.value("weather", value))
 			}
 		}
 		wData.WeatherData = append(wData.WeatherData, w)
@@ -173,10 +196,3 @@ func (fm FMI_ObservationsModel) ConvertToWeatherData() (WeatherDataModel, error)
 	}
 	return wData, nil
 }
-
-func valueOrZero(v float64) float64 {
-	if math.IsNaN(v) {
-		return 0.0
-	}
-	return v
-}
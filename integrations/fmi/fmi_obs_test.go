@@ -136,3 +136,22 @@ func weatherDataTests(t *testing.T, test TestValues) {
 		t.Errorf("last weather time != LastObservationTime, got %s, want %s", weather.WeatherData[len(weather.WeatherData)-1].Time, test.LastObservationTime)
 	}
 }
+
+func TestConvertRecordsMissingValues(t *testing.T) {
+	fmiObs := FMI_ObservationsModel{}
+	LoadXml(t, "testdata/exampleMinutes.xml", &fmiObs, Minutes)
+	wData, err := fmiObs.ConvertToWeatherData()
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, second := wData.WeatherData[0], wData.WeatherData[1]
+	if first.Has("rain") || first.Rain != 0 {
+		t.Errorf("NaN rain should be missing and zero, got Has=%v Rain=%v", first.Has("rain"), first.Rain)
+	}
+	if !first.Has("temperature") || first.Temp != 9.3 {
+		t.Errorf("temperature should be 9.3, got Has=%v Temp=%v", first.Has("temperature"), first.Temp)
+	}
+	if !second.Has("rain") {
+		t.Error("a reported 0.0 rain is a value")
+	}
+}
@@ -20,7 +20,12 @@ type StationCollection struct {
 type Station struct {
 	Id    StationId `xml:"identifier" validate:"required"`
 	Names []Name    `xml:"name" validate:"gt=1,dive"`
-	Point string    `xml:"representativePoint>Point>pos" validate:"required"`
+	Point string    `xml:"representativePoint>Point>pos" validate:"required"` // "lat lon"
+	// Networks the station belongs to, e.g. WeatherStationNetwork
+	Networks []Network `xml:"belongsTo"`
+}
+type Network struct {
+	Title string `xml:"title,attr"`
 }
 type StationId string
 type Name struct {
@@ -0,0 +1,177 @@
+package fmi
+
+import (
+	"fmt"
+	"math"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Defaults used when FMI_STATION and FMI_PLACE are not set.
+const (
+	DefaultStation StationId = "101004" // Helsinki Kumpula
+	DefaultPlace             = "Tapanila,Helsinki"
+)
+
+// WeatherStationNetwork is the network of the automatic weather stations,
+// the ones with weather observations. Tide gauges, air quality and
+// precipitation stations are in the same station list.
+const WeatherStationNetwork = "Automaattinen sääasema"
+
+// Location selects the station or the place of a weather request. FMISID
+// takes precedence.
+type Location struct {
+	FMISID StationId
+	Place  string
+}
+
+func (l Location) String() string {
+	if l.FMISID != "" {
+		return "fmisid " + string(l.FMISID)
+	}
+	return l.Place
+}
+
+func (l Location) query() (string, error) {
+	switch {
+	case l.FMISID != "":
+		return "fmisid=" + url.QueryEscape(string(l.FMISID)), nil
+	case l.Place != "":
+		return "place=" + url.QueryEscape(l.Place), nil
+	}
+	return "", errors.New("Location has no fmisid or place")
+}
+
+// ConfiguredStation is the observation station from FMI_STATION (an fmisid),
+// DefaultStation when unset.
+func ConfiguredStation() StationId {
+	if s := os.Getenv("FMI_STATION"); s != "" {
+		return StationId(s)
+	}
+	return DefaultStation
+}
+
+// ConfiguredPlace is the forecast place from FMI_PLACE, DefaultPlace when unset.
+func ConfiguredPlace() string {
+	if s := os.Getenv("FMI_PLACE"); s != "" {
+		return s
+	}
+	return DefaultPlace
+}
+
+// Coordinates parses the station's position.
+func (s Station) Coordinates() (lat, lon float64, err error) {
+	fields := strings.Fields(s.Point)
+	if len(fields) != 2 {
+		return 0, 0, errors.Errorf("Invalid position %q of station %s", s.Point, s.Id)
+	}
+	if lat, err = strconv.ParseFloat(fields[0], 64); err == nil {
+		lon, err = strconv.ParseFloat(fields[1], 64)
+	}
+	if err != nil {
+		return 0, 0, errors.Wrapf(err, "Invalid position %q of station %s", s.Point, s.Id)
+	}
+	return lat, lon, nil
+}
+
+// InNetwork reports whether the station belongs to the network.
+func (s Station) InNetwork(network string) bool {
+	for _, n := range s.Networks {
+		if n.Title == network {
+			return true
+		}
+	}
+	return false
+}
+
+// NearestWeatherStation returns the automatic weather station closest to
+// lat, lon and its distance in kilometres.
+func NearestWeatherStation(stations []Station, lat, lon float64) (Station, float64, error) {
+	var nearest Station
+	best := math.Inf(1)
+	for _, s := range stations {
+		if !s.InNetwork(WeatherStationNetwork) {
+			continue
+		}
+		sLat, sLon, err := s.Coordinates()
+		if err != nil {
+			continue
+		}
+		if d := distanceKm(lat, lon, sLat, sLon); d < best {
+			nearest, best = s, d
+		}
+	}
+	if math.IsInf(best, 1) {
+		return Station{}, 0, errors.New("No weather stations to choose from")
+	}
+	return nearest, best, nil
+}
+
+// distanceKm is the great-circle distance between two points.
+func distanceKm(lat1, lon1, lat2, lon2 float64) float64 {
+	const earthRadiusKm = 6371.0
+	rad := math.Pi / 180
+	dLat := (lat2 - lat1) * rad
+	dLon := (lon2 - lon1) * rad
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1*rad)*math.Cos(lat2*rad)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadiusKm * math.Asin(math.Sqrt(a))
+}
+
+// StationFinder resolves coordinates to the nearest weather station. The
+// station list is loaded on first use and reloaded after TTL.
+type StationFinder struct {
+	TTL  time.Duration
+	load func() ([]Station, error)
+	now  func() time.Time
+
+	mu       sync.Mutex
+	stations []Station
+	loaded   time.Time
+}
+
+// NewStationFinder loads the station list with LoadWeatherStations and keeps
+// it for a day.
+func NewStationFinder() *StationFinder {
+	return &StationFinder{
+		TTL: 24 * time.Hour,
+		load: func() ([]Station, error) {
+			fmis := &FMI_StationsModel{}
+			if err := fmis.LoadWeatherStations(); err != nil {
+				return nil, err
+			}
+			return fmis.StationsCol.Stations, nil
+		},
+		now: time.Now,
+	}
+}
+
+// Nearest returns the weather station closest to lat, lon and its distance
+// in kilometres. A stale station list is used when reloading fails.
+func (f *StationFinder) Nearest(lat, lon float64) (Station, float64, error) {
+	if lat < -90 || lat > 90 || lon < -180 || lon > 180 {
+		return Station{}, 0, fmt.Errorf("invalid coordinates %v,%v", lat, lon)
+	}
+	f.mu.Lock()
+	if f.stations == nil || f.now().Sub(f.loaded) > f.TTL {
+		stations, err := f.load()
+		switch {
+		case err == nil:
+			f.stations = stations
+		case f.stations == nil:
+			f.mu.Unlock()
+			return Station{}, 0, errors.Wrap(err, "Error loading weather stations")
+		}
+		// Stations rarely change: after a failed reload the old list is kept
+		// for another TTL instead of retrying on every request
+		f.loaded = f.now()
+	}
+	stations := f.stations
+	f.mu.Unlock()
+	return NearestWeatherStation(stations, lat, lon)
+}
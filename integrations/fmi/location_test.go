@@ -0,0 +1,126 @@
+package fmi
+
+import (
+	"encoding/xml"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func loadTestStations(t *testing.T) []Station {
+	t.Helper()
+	data, err := os.ReadFile("testdata/exampleStations.xml")
+	require.NoError(t, err)
+	fmis := &FMI_StationsModel{}
+	require.NoError(t, xml.Unmarshal(data, &fmis.StationsCol))
+	return fmis.StationsCol.Stations
+}
+
+func TestLocation_Query(t *testing.T) {
+	q, err := Location{FMISID: "101004", Place: "ignored"}.query()
+	require.NoError(t, err)
+	assert.Equal(t, "fmisid=101004", q)
+	q, err = Location{Place: "Tapanila,Helsinki"}.query()
+	require.NoError(t, err)
+	assert.Equal(t, "place=Tapanila%2CHelsinki", q)
+	_, err = Location{}.query()
+	assert.Error(t, err)
+}
+
+func TestStationNetworksAndCoordinates(t *testing.T) {
+	stations := loadTestStations(t)
+	kemi := stations[0]
+	assert.True(t, kemi.InNetwork("Mareografiasema"))
+	assert.False(t, kemi.InNetwork(WeatherStationNetwork))
+	lat, lon, err := kemi.Coordinates()
+	require.NoError(t, err)
+	assert.Equal(t, 65.67337, lat)
+	assert.Equal(t, 24.51526, lon)
+
+	_, _, err = Station{Id: "1", Point: "60.1"}.Coordinates()
+	assert.Error(t, err)
+}
+
+func TestNearestWeatherStation(t *testing.T) {
+	stations := loadTestStations(t)
+
+	station, km, err := NearestWeatherStation(stations, 60.20307, 24.96131) // Kumpula
+	require.NoError(t, err)
+	assert.Equal(t, StationId("101004"), station.Id)
+	assert.Less(t, km, 0.5)
+
+	// The tide gauge at Kemi Ajos has no weather observations
+	station, km, err = NearestWeatherStation(stations, 65.67337, 24.51526)
+	require.NoError(t, err)
+	assert.NotEqual(t, StationId("100539"), station.Id)
+	assert.True(t, station.InNetwork(WeatherStationNetwork))
+	assert.Greater(t, km, 0.0)
+
+	_, _, err = NearestWeatherStation(stations[:1], 60, 25)
+	assert.Error(t, err)
+}
+
+func TestDistanceKm(t *testing.T) {
+	// Helsinki - Tampere is about 160 km
+	assert.InDelta(t, 160, distanceKm(60.1699, 24.9384, 61.4978, 23.7610), 2)
+	assert.Zero(t, distanceKm(60, 25, 60, 25))
+}
+
+func TestStationFinder_CachesStations(t *testing.T) {
+	stations := loadTestStations(t)
+	now := time.Date(2025, 3, 10, 9, 0, 0, 0, time.UTC)
+	loads := 0
+	var loadErr error
+	f := &StationFinder{
+		TTL: 24 * time.Hour,
+		load: func() ([]Station, error) {
+			loads++
+			if loadErr != nil {
+				return nil, loadErr
+			}
+			return stations, nil
+		},
+		now: func() time.Time { return now },
+	}
+
+	loadErr = errors.New("fmi down")
+	_, _, err := f.Nearest(60.2, 24.96)
+	assert.ErrorContains(t, err, "fmi down")
+
+	loadErr = nil
+	station, _, err := f.Nearest(60.20307, 24.96131)
+	require.NoError(t, err)
+	assert.Equal(t, StationId("101004"), station.Id)
+	_, _, err = f.Nearest(61.5, 23.76)
+	require.NoError(t, err)
+	assert.Equal(t, 2, loads, "the list is cached")
+
+	// A failed reload keeps the old list for another day
+	now = now.Add(25 * time.Hour)
+	loadErr = errors.New("fmi down")
+	station, _, err = f.Nearest(60.20307, 24.96131)
+	require.NoError(t, err)
+	assert.Equal(t, StationId("101004"), station.Id)
+	_, _, err = f.Nearest(60.20307, 24.96131)
+	require.NoError(t, err)
+	assert.Equal(t, 3, loads)
+
+	_, _, err = f.Nearest(91, 0)
+	assert.Error(t, err)
+}
+
+func TestConfiguredLocation(t *testing.T) {
+	t.Setenv("FMI_STATION", "")
+	t.Setenv("FMI_PLACE", "")
+	assert.Equal(t, DefaultStation, ConfiguredStation())
+	assert.Equal(t, DefaultPlace, ConfiguredPlace())
+
+	t.Setenv("FMI_STATION", "100971")
+	t.Setenv("FMI_PLACE", "Oulu")
+	assert.Equal(t, StationId("100971"), ConfiguredStation())
+	assert.Equal(t, "Oulu", ConfiguredPlace())
+}
@@ -1,8 +1,18 @@
 package fmi
 
+// GetWeatherData fetches observations of the station with fmisid id, or the
+// forecast for the place named by id.
 func GetWeatherData(id StationId, requestType RequestType) (WeatherDataModel, error) {
+	if requestType == Forecast {
+		return GetWeatherDataAt(Location{Place: string(id)}, requestType)
+	}
+	return GetWeatherDataAt(Location{FMISID: id}, requestType)
+}
+
+// GetWeatherDataAt fetches observations or the forecast for a station or place.
+func GetWeatherDataAt(location Location, requestType RequestType) (WeatherDataModel, error) {
 	fmi := &FMI_ObservationsModel{}
-	err := fmi.LoadObservations(id, requestType)
+	err := fmi.LoadWeather(location, requestType)
 	if err != nil {
 		return WeatherDataModel{}, err
 	}
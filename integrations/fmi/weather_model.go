@@ -1,7 +1,15 @@
 package fmi
 
+import (
+	"math"
+	"time"
+)
+
 type WeatherDataModel struct {
 	WeatherData []WeatherData
+	// IssueTime is the analysis time of the model run a forecast is based on
+	// (the result time when FMI does not report one). Zero when unknown.
+	IssueTime time.Time `json:"-"`
 }
 type WeatherData struct {
 	Time             string  `json:"datetime"`
@@ -21,4 +29,26 @@ type WeatherData struct {
 	SnowDepth        float64 `json:"snow"`
 	Visibility       float64 `json:"visibility"`
 	CloudCover       float64 `json:"clouds"`
+	// Missing lists the JSON names of the fields FMI reported as NaN. They
+	// are zero in the struct, so check Has before using a value as a reading.
+	Missing []string `json:"missing,omitempty"`
+}
+
+// Has reports whether the field, by its JSON name, had a value.
+func (w WeatherData) Has(field string) bool {
+	for _, m := range w.Missing {
+		if m == field {
+			return false
+		}
+	}
+	return true
+}
+
+// value returns v, or zero for a NaN, which is recorded in Missing.
+func (w *WeatherData) value(field string, v float64) float64 {
+	if math.IsNaN(v) {
+		w.Missing = append(w.Missing, field)
+		return 0
+	}
+	return v
 }
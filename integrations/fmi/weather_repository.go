@@ -0,0 +1,145 @@
+package fmi
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+func (t RequestType) String() string {
+	switch t {
+	case Observations:
+		return "OBSERVATIONS"
+	case Forecast:
+		return "FORECAST"
+	}
+	return fmt.Sprintf("RequestType(%d)", int64(t))
+}
+
+// StoredWeather is a row of the weather_data table.
+type StoredWeather struct {
+	StationID   StationId
+	RequestType RequestType
+	// IssueTime is the forecast's issue time, or the observation time.
+	IssueTime time.Time
+	Timestamp time.Time
+	WeatherData
+}
+
+// WeatherRepository keeps the history of observations and forecasts in the
+// weather_data table.
+type WeatherRepository struct {
+	db *sql.DB
+}
+
+func NewWeatherRepository(db *sql.DB) *WeatherRepository {
+	return &WeatherRepository{db: db}
+}
+
+// weatherRows tags the data points with the station and request type.
+// Forecasts need an issue time so earlier forecasts are not overwritten.
+func weatherRows(station StationId, requestType RequestType, data WeatherDataModel) ([]StoredWeather, error) {
+	if requestType == Forecast && data.IssueTime.IsZero() {
+		return nil, fmt.Errorf("forecast for %s has no issue time", station)
+	}
+	rows := make([]StoredWeather, 0, len(data.WeatherData))
+	for _, w := range data.WeatherData {
+		ts, err := time.Parse(time.RFC3339, w.Time)
+		if err != nil {
+			return nil, fmt.Errorf("parsing weather time %q: %w", w.Time, err)
+		}
+		issued := data.IssueTime
+		if requestType == Observations {
+			issued = ts
+		}
+		rows = append(rows, StoredWeather{StationID: station, RequestType: requestType, IssueTime: issued, Timestamp: ts, WeatherData: w})
+	}
+	return rows, nil
+}
+
+// temperature is the value of the temperature column, NULL when FMI had no
+// reading.
+func (w StoredWeather) temperature() sql.NullFloat64 {
+	return sql.NullFloat64{Float64: w.Temp, Valid: w.Has("temperature")}
+}
+
+// Store upserts the data points of an observation or forecast request. It
+// returns the number of points stored.
+func (r *WeatherRepository) Store(ctx context.Context, station StationId, requestType RequestType, data WeatherDataModel) (int, error) {
+	rows, err := weatherRows(station, requestType, data)
+	if err != nil || len(rows) == 0 {
+		return 0, err
+	}
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("beginning transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, `
+		INSERT INTO weather_data (station_id, request_type, issue_time, timestamp, temperature, data)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (station_id, request_type, issue_time, timestamp)
+		DO UPDATE SET temperature = EXCLUDED.temperature, data = EXCLUDED.data`)
+	if err != nil {
+		return 0, fmt.Errorf("preparing weather upsert: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, row := range rows {
+		value, err := json.Marshal(row.WeatherData)
+		if err != nil {
+			return 0, fmt.Errorf("encoding weather at %s: %w", row.Timestamp, err)
+		}
+		if _, err := stmt.ExecContext(ctx, string(station), requestType.String(), row.IssueTime.UTC(), row.Timestamp.UTC(), row.temperature(), value); err != nil {
+			return 0, fmt.Errorf("upserting weather at %s: %w", row.Timestamp, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("committing weather: %w", err)
+	}
+	return len(rows), nil
+}
+
+// Observations returns the observations of a station between start and end
+// (both inclusive), oldest first.
+func (r *WeatherRepository) Observations(ctx context.Context, station StationId, start, end time.Time) ([]StoredWeather, error) {
+	return r.query(ctx, `
+		SELECT issue_time, timestamp, data FROM weather_data
+		WHERE station_id = $1 AND request_type = $2 AND timestamp >= $3 AND timestamp <= $4
+		ORDER BY timestamp`, station, Observations, start, end)
+}
+
+// Forecasts returns every stored forecast for the times between start and
+// end (both inclusive), ordered by time and issue time. Comparing them with
+// Observations shows how the forecast for a time changed as it got closer.
+func (r *WeatherRepository) Forecasts(ctx context.Context, station StationId, start, end time.Time) ([]StoredWeather, error) {
+	return r.query(ctx, `
+		SELECT issue_time, timestamp, data FROM weather_data
+		WHERE station_id = $1 AND request_type = $2 AND timestamp >= $3 AND timestamp <= $4
+		ORDER BY timestamp, issue_time`, station, Forecast, start, end)
+}
+
+func (r *WeatherRepository) query(ctx context.Context, query string, station StationId, requestType RequestType, start, end time.Time) ([]StoredWeather, error) {
+	rows, err := r.db.QueryContext(ctx, query, string(station), requestType.String(), start.UTC(), end.UTC())
+	if err != nil {
+		return nil, fmt.Errorf("querying weather: %w", err)
+	}
+	defer rows.Close()
+
+	var result []StoredWeather
+	for rows.Next() {
+		w := StoredWeather{StationID: station, RequestType: requestType}
+		var data []byte
+		if err := rows.Scan(&w.IssueTime, &w.Timestamp, &data); err != nil {
+			return nil, fmt.Errorf("scanning weather: %w", err)
+		}
+		if err := json.Unmarshal(data, &w.WeatherData); err != nil {
+			return nil, fmt.Errorf("decoding weather at %s: %w", w.Timestamp, err)
+		}
+		result = append(result, w)
+	}
+	return result, rows.Err()
+}
@@ -0,0 +1,60 @@
+//go:build integration
+
+package fmi
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mikahozz/gohome/config"
+	"github.com/mikahozz/gohome/db"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWeatherRepository_StoreAndRead(t *testing.T) {
+	config.LoadEnv()
+	conn, err := db.Open()
+	if err != nil {
+		t.Skipf("database not available: %v", err)
+	}
+	defer conn.Close()
+
+	const station StationId = "integration-test"
+	cleanup := func() {
+		conn.Exec(`DELETE FROM weather_data WHERE station_id = $1`, string(station))
+	}
+	cleanup()
+	defer cleanup()
+
+	ctx := context.Background()
+	repo := NewWeatherRepository(conn)
+	start := time.Date(2001, 1, 1, 0, 0, 0, 0, time.UTC)
+	points := []WeatherData{{Time: "2001-01-01T12:00:00Z", Temp: -5}, {Time: "2001-01-01T13:00:00Z", Temp: -4}}
+
+	// Two forecast runs for the same hours are both kept
+	n, err := repo.Store(ctx, station, Forecast, WeatherDataModel{IssueTime: start, WeatherData: points})
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+	points[0].Temp = -6
+	_, err = repo.Store(ctx, station, Forecast, WeatherDataModel{IssueTime: start.Add(6 * time.Hour), WeatherData: points[:1]})
+	require.NoError(t, err)
+	// Observations are replaced when fetched again
+	_, err = repo.Store(ctx, station, Observations, WeatherDataModel{WeatherData: []WeatherData{{Time: "2001-01-01T12:00:00Z", Temp: -7}}})
+	require.NoError(t, err)
+	_, err = repo.Store(ctx, station, Observations, WeatherDataModel{WeatherData: []WeatherData{{Time: "2001-01-01T12:00:00Z", Temp: -7.5}}})
+	require.NoError(t, err)
+
+	forecasts, err := repo.Forecasts(ctx, station, start, start.Add(24*time.Hour))
+	require.NoError(t, err)
+	require.Len(t, forecasts, 3)
+	assert.Equal(t, -5.0, forecasts[0].Temp)
+	assert.Equal(t, -6.0, forecasts[1].Temp)
+	assert.True(t, forecasts[1].IssueTime.Equal(start.Add(6*time.Hour)))
+
+	observations, err := repo.Observations(ctx, station, start, start.Add(24*time.Hour))
+	require.NoError(t, err)
+	require.Len(t, observations, 1)
+	assert.Equal(t, -7.5, observations[0].Temp)
+}
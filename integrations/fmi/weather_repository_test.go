@@ -0,0 +1,62 @@
+package fmi
+
+import (
+	"database/sql"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestForecastIssueTime(t *testing.T) {
+	fmiObs := &FMI_ObservationsModel{}
+	LoadXml(t, "testdata/exampleForecast.xml", fmiObs, Hours)
+	weather, err := fmiObs.ConvertToWeatherData()
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2022, 11, 2, 12, 0, 0, 0, time.UTC), weather.IssueTime, "analysis time of the model run")
+}
+
+func TestWeatherRows(t *testing.T) {
+	issued := time.Date(2025, 3, 10, 6, 0, 0, 0, time.UTC)
+	data := WeatherDataModel{IssueTime: issued, WeatherData: []WeatherData{
+		{Time: "2025-03-10T09:00:00Z", Temp: 1},
+		{Time: "2025-03-10T10:00:00Z", Temp: 2},
+	}}
+
+	rows, err := weatherRows("Tapanila,Helsinki", Forecast, data)
+	require.NoError(t, err)
+	require.Len(t, rows, 2)
+	assert.Equal(t, issued, rows[1].IssueTime)
+	assert.Equal(t, time.Date(2025, 3, 10, 10, 0, 0, 0, time.UTC), rows[1].Timestamp)
+	assert.Equal(t, 2.0, rows[1].Temp)
+
+	rows, err = weatherRows("101004", Observations, data)
+	require.NoError(t, err)
+	assert.Equal(t, rows[0].Timestamp, rows[0].IssueTime, "observations are issued when observed")
+
+	_, err = weatherRows("Tapanila,Helsinki", Forecast, WeatherDataModel{WeatherData: data.WeatherData})
+	assert.ErrorContains(t, err, "no issue time")
+	_, err = weatherRows("101004", Observations, WeatherDataModel{WeatherData: []WeatherData{{Time: "bad"}}})
+	assert.Error(t, err)
+}
+
+func TestWeatherRows_MissingTemperature(t *testing.T) {
+	data := WeatherDataModel{WeatherData: []WeatherData{
+		{Time: "2025-03-10T09:00:00Z", Temp: 0},
+		{Time: "2025-03-10T09:10:00Z", Humidity: 80, Missing: []string{"temperature"}},
+	}}
+	rows, err := weatherRows("101004", Observations, data)
+	require.NoError(t, err)
+	assert.Equal(t, sql.NullFloat64{Float64: 0, Valid: true}, rows[0].temperature(), "a measured 0 °C is a value")
+	assert.False(t, rows[1].temperature().Valid, "a missing temperature is stored as NULL")
+
+	// Missing round-trips through the data column
+	encoded, err := json.Marshal(rows[1].WeatherData)
+	require.NoError(t, err)
+	var decoded WeatherData
+	require.NoError(t, json.Unmarshal(encoded, &decoded))
+	assert.False(t, decoded.Has("temperature"))
+	assert.True(t, decoded.Has("humidity"))
+}
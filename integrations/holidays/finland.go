@@ -0,0 +1,79 @@
+// Package holidays computes Finnish public holidays, including the moveable
+// feasts that depend on Easter or fall on a Saturday within a date range.
+package holidays
+
+import (
+	"sort"
+	"time"
+)
+
+// Holiday is a day off in Finland. Official is false for the eves
+// (Midsummer Eve, Christmas Eve) that are not public holidays by law but are
+// days off in practice.
+type Holiday struct {
+	Date     time.Time `json:"date"` // midnight UTC of the day
+	Name     string    `json:"name"`
+	NameFi   string    `json:"name_fi"`
+	Official bool      `json:"official"`
+}
+
+// Easter returns Easter Sunday of the Gregorian calendar (anonymous Gregorian
+// algorithm).
+func Easter(year int) time.Time {
+	a := year % 19
+	b, c := year/100, year%100
+	d, e := b/4, b%4
+	f := (b + 8) / 25
+	g := (b - f + 1) / 3
+	h := (19*a + b - d - g + 15) % 30
+	i, k := c/4, c%4
+	l := (32 + 2*e + 2*i - h - k) % 7
+	m := (a + 11*h + 22*l) / 451
+	month := (h + l - 7*m + 114) / 31
+	day := (h+l-7*m+114)%31 + 1
+	return date(year, time.Month(month), day)
+}
+
+// Finland returns the holidays of year in date order.
+func Finland(year int) []Holiday {
+	easter := Easter(year)
+	holidays := []Holiday{
+		{date(year, time.January, 1), "New Year's Day", "Uudenvuodenpäivä", true},
+		{date(year, time.January, 6), "Epiphany", "Loppiainen", true},
+		{easter.AddDate(0, 0, -2), "Good Friday", "Pitkäperjantai", true},
+		{easter, "Easter Sunday", "Pääsiäispäivä", true},
+		{easter.AddDate(0, 0, 1), "Easter Monday", "2. pääsiäispäivä", true},
+		{date(year, time.May, 1), "May Day", "Vappu", true},
+		{easter.AddDate(0, 0, 39), "Ascension Day", "Helatorstai", true},
+		{easter.AddDate(0, 0, 49), "Whit Sunday", "Helluntaipäivä", true},
+		{weekdayOnOrAfter(date(year, time.June, 19), time.Friday), "Midsummer Eve", "Juhannusaatto", false},
+		{weekdayOnOrAfter(date(year, time.June, 20), time.Saturday), "Midsummer Day", "Juhannuspäivä", true},
+		{weekdayOnOrAfter(date(year, time.October, 31), time.Saturday), "All Saints' Day", "Pyhäinpäivä", true},
+		{date(year, time.December, 6), "Independence Day", "Itsenäisyyspäivä", true},
+		{date(year, time.December, 24), "Christmas Eve", "Jouluaatto", false},
+		{date(year, time.December, 25), "Christmas Day", "Joulupäivä", true},
+		{date(year, time.December, 26), "St. Stephen's Day", "Tapaninpäivä", true},
+	}
+	sort.SliceStable(holidays, func(i, j int) bool { return holidays[i].Date.Before(holidays[j].Date) })
+	return holidays
+}
+
+// Lookup returns the holiday on the calendar date of t (in t's location).
+func Lookup(t time.Time) (Holiday, bool) {
+	day := date(t.Year(), t.Month(), t.Day())
+	for _, h := range Finland(t.Year()) {
+		if h.Date.Equal(day) {
+			return h, true
+		}
+	}
+	return Holiday{}, false
+}
+
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+// weekdayOnOrAfter returns the first day on or after d that is a wd.
+func weekdayOnOrAfter(d time.Time, wd time.Weekday) time.Time {
+	return d.AddDate(0, 0, (int(wd)-int(d.Weekday())+7)%7)
+}
@@ -0,0 +1,68 @@
+package holidays
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEaster(t *testing.T) {
+	for year, want := range map[int]string{
+		2019: "2019-04-21",
+		2024: "2024-03-31",
+		2025: "2025-04-20",
+		2026: "2026-04-05",
+		2038: "2038-04-25", // latest possible
+		2285: "2285-03-22", // earliest possible
+	} {
+		assert.Equal(t, want, Easter(year).Format("2006-01-02"), "Easter %d", year)
+	}
+}
+
+func TestFinland(t *testing.T) {
+	dates := func(year int) map[string]string {
+		result := make(map[string]string)
+		for _, h := range Finland(year) {
+			result[h.NameFi] = h.Date.Format("2006-01-02")
+		}
+		return result
+	}
+
+	h2025 := dates(2025)
+	assert.Equal(t, "2025-04-18", h2025["Pitkäperjantai"])
+	assert.Equal(t, "2025-04-21", h2025["2. pääsiäispäivä"])
+	assert.Equal(t, "2025-05-29", h2025["Helatorstai"])
+	assert.Equal(t, "2025-06-08", h2025["Helluntaipäivä"])
+	assert.Equal(t, "2025-06-20", h2025["Juhannusaatto"])
+	assert.Equal(t, "2025-06-21", h2025["Juhannuspäivä"])
+	assert.Equal(t, "2025-11-01", h2025["Pyhäinpäivä"])
+
+	h2026 := dates(2026)
+	assert.Equal(t, "2026-06-19", h2026["Juhannusaatto"], "earliest Midsummer Eve")
+	assert.Equal(t, "2026-06-20", h2026["Juhannuspäivä"])
+	assert.Equal(t, "2026-10-31", h2026["Pyhäinpäivä"])
+	assert.Equal(t, "2026-05-14", h2026["Helatorstai"])
+
+	all := Finland(2025)
+	require.Len(t, all, 15)
+	for i := 1; i < len(all); i++ {
+		assert.True(t, all[i-1].Date.Before(all[i].Date), "sorted by date")
+	}
+}
+
+func TestLookup(t *testing.T) {
+	helsinki, _ := time.LoadLocation("Europe/Helsinki")
+	h, ok := Lookup(time.Date(2025, 12, 6, 23, 30, 0, 0, helsinki))
+	require.True(t, ok, "late evening is still the same local day")
+	assert.Equal(t, "Independence Day", h.Name)
+	assert.True(t, h.Official)
+
+	h, ok = Lookup(time.Date(2025, 12, 24, 8, 0, 0, 0, helsinki))
+	require.True(t, ok)
+	assert.False(t, h.Official)
+
+	_, ok = Lookup(time.Date(2025, 12, 7, 0, 30, 0, 0, helsinki))
+	assert.False(t, ok)
+}
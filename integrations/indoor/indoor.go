@@ -0,0 +1,118 @@
+// Package indoor ingests readings of indoor sensors (Shelly H&T and anything
+// that can POST JSON) and stores them in the measurements table.
+package indoor
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// ErrInvalidReading is returned for readings without values or with values
+// out of range.
+var ErrInvalidReading = errors.New("invalid indoor reading")
+
+// sensorIDPrefix keeps indoor sensors apart from other measurements.
+const sensorIDPrefix = "indoor_"
+
+// SensorID is the sensor_id readings of a sensor are stored under in the
+// measurements table.
+func SensorID(sensor string) string {
+	return sensorIDPrefix + sensor
+}
+
+var sensorName = regexp.MustCompile(`^[a-z0-9_-]+$`)
+
+// ValidSensor reports whether name can be used as a sensor name: lower case
+// letters, digits, "_" and "-", short enough for the sensor_id column.
+func ValidSensor(name string) bool {
+	return sensorName.MatchString(name) && len(SensorID(name)) <= 50
+}
+
+// Reading is a sample of a sensor. Sensors reporting values separately (e.g.
+// a Shelly Plus H&T sends temperature and humidity in their own webhooks)
+// leave the others nil. The JSON format is the one the dashboard reads.
+type Reading struct {
+	Time        time.Time `json:"time"`
+	Temperature *float64  `json:"temperature,omitempty"` // °C
+	Humidity    *float64  `json:"humidity,omitempty"`    // %RH
+	Battery     *float64  `json:"battery,omitempty"`     // %
+}
+
+// Validate checks that the reading has a value and that the values are
+// plausible.
+func (r Reading) Validate() error {
+	if r.Temperature == nil && r.Humidity == nil && r.Battery == nil {
+		return fmt.Errorf("%w: no temperature, humidity or battery", ErrInvalidReading)
+	}
+	for _, v := range []struct {
+		name     string
+		value    *float64
+		min, max float64
+	}{
+		{"temperature", r.Temperature, -50, 100},
+		{"humidity", r.Humidity, 0, 100},
+		{"battery", r.Battery, 0, 100},
+	} {
+		if v.value != nil && (*v.value < v.min || *v.value > v.max) {
+			return fmt.Errorf("%w: %s %v out of range %v..%v", ErrInvalidReading, v.name, *v.value, v.min, v.max)
+		}
+	}
+	return nil
+}
+
+// shellyParams are the query parameters Shelly H&T devices can send: Gen1
+// "report sensor values" URLs add temp, hum and id; Gen2/Plus webhooks use
+// the names given in their URL, e.g. ?tC=${ev.tC} or ?rh=${ev.rh}.
+var shellyParams = map[string][]string{
+	"temperature": {"temp", "tC", "temperature"},
+	"humidity":    {"hum", "rh", "humidity"},
+	"battery":     {"bat", "battery"},
+}
+
+// FromShellyQuery reads a Shelly H&T webhook's query parameters into a reading
+// taken at now.
+func FromShellyQuery(q url.Values, now time.Time) (Reading, error) {
+	r := Reading{Time: now}
+	targets := map[string]**float64{"temperature": &r.Temperature, "humidity": &r.Humidity, "battery": &r.Battery}
+	for field, names := range shellyParams {
+		for _, name := range names {
+			s := q.Get(name)
+			if s == "" {
+				continue
+			}
+			v, err := strconv.ParseFloat(s, 64)
+			if err != nil {
+				return Reading{}, fmt.Errorf("%w: %s %q is not a number", ErrInvalidReading, name, s)
+			}
+			*targets[field] = &v
+			break
+		}
+	}
+	return r, r.Validate()
+}
+
+// Latest combines the newest value of each field from readings sorted newest
+// first, so sensors reporting values separately still show all of them. The
+// time is that of the newest reading.
+func Latest(readings []Reading) (Reading, bool) {
+	if len(readings) == 0 {
+		return Reading{}, false
+	}
+	latest := Reading{Time: readings[0].Time}
+	for _, r := range readings {
+		if latest.Temperature == nil {
+			latest.Temperature = r.Temperature
+		}
+		if latest.Humidity == nil {
+			latest.Humidity = r.Humidity
+		}
+		if latest.Battery == nil {
+			latest.Battery = r.Battery
+		}
+	}
+	return latest, true
+}
@@ -0,0 +1,77 @@
+package indoor
+
+import (
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func float(v float64) *float64 { return &v }
+
+func TestReading_Validate(t *testing.T) {
+	now := time.Date(2025, 11, 3, 7, 0, 0, 0, time.UTC)
+	assert.NoError(t, Reading{Time: now, Temperature: float(22.5)}.Validate())
+	assert.NoError(t, Reading{Time: now, Battery: float(100)}.Validate())
+	for name, r := range map[string]Reading{
+		"no values":   {Time: now},
+		"too hot":     {Time: now, Temperature: float(150)},
+		"humidity<0":  {Time: now, Humidity: float(-1)},
+		"battery>100": {Time: now, Temperature: float(20), Battery: float(101)},
+	} {
+		err := r.Validate()
+		assert.True(t, errors.Is(err, ErrInvalidReading), "%s: %v", name, err)
+	}
+}
+
+func TestValidSensor(t *testing.T) {
+	assert.True(t, ValidSensor("dev_upstairs"))
+	assert.True(t, ValidSensor("sauna-2"))
+	assert.False(t, ValidSensor(""))
+	assert.False(t, ValidSensor("Upstairs"))
+	assert.False(t, ValidSensor("a/b"))
+	assert.False(t, ValidSensor("a_very_long_sensor_name_that_does_not_fit_the_column"))
+}
+
+func TestFromShellyQuery(t *testing.T) {
+	now := time.Date(2025, 11, 3, 7, 0, 0, 0, time.UTC)
+
+	// Gen1 H&T: /api/indoor/upstairs/shelly?hum=27&temp=22.50&id=shellyht-AB12
+	r, err := FromShellyQuery(url.Values{"hum": {"27"}, "temp": {"22.50"}, "id": {"shellyht-AB12"}}, now)
+	require.NoError(t, err)
+	assert.Equal(t, now, r.Time)
+	assert.Equal(t, 22.5, *r.Temperature)
+	assert.Equal(t, 27.0, *r.Humidity)
+	assert.Nil(t, r.Battery)
+
+	// Plus H&T reports temperature and humidity in separate webhooks
+	r, err = FromShellyQuery(url.Values{"tC": {"21.3"}}, now)
+	require.NoError(t, err)
+	assert.Equal(t, 21.3, *r.Temperature)
+	assert.Nil(t, r.Humidity)
+
+	_, err = FromShellyQuery(url.Values{"temp": {"warm"}}, now)
+	assert.True(t, errors.Is(err, ErrInvalidReading))
+	_, err = FromShellyQuery(url.Values{"id": {"shellyht-AB12"}}, now)
+	assert.True(t, errors.Is(err, ErrInvalidReading))
+}
+
+func TestLatest(t *testing.T) {
+	_, ok := Latest(nil)
+	assert.False(t, ok)
+
+	now := time.Date(2025, 11, 3, 7, 0, 0, 0, time.UTC)
+	latest, ok := Latest([]Reading{
+		{Time: now, Temperature: float(22.5)},
+		{Time: now.Add(-time.Minute), Humidity: float(40)},
+		{Time: now.Add(-time.Hour), Temperature: float(20), Humidity: float(45), Battery: float(80)},
+	})
+	require.True(t, ok)
+	assert.Equal(t, now, latest.Time)
+	assert.Equal(t, 22.5, *latest.Temperature)
+	assert.Equal(t, 40.0, *latest.Humidity)
+	assert.Equal(t, 80.0, *latest.Battery)
+}
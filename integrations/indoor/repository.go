@@ -0,0 +1,109 @@
+package indoor
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mikahozz/gohome/db"
+)
+
+// latestWindow is how far back Latest looks for values a sensor reported
+// separately.
+const latestWindow = 24 * time.Hour
+
+// Repository keeps indoor readings in the measurements table with the
+// temperature as main_value.
+type Repository struct {
+	db *sql.DB
+}
+
+func NewRepository(db *sql.DB) *Repository {
+	return &Repository{db: db}
+}
+
+// Store saves readings of a sensor, replacing readings with the same timestamps.
+func (r *Repository) Store(ctx context.Context, sensor string, readings []Reading) error {
+	measurements := make([]db.Measurement, len(readings))
+	for i, reading := range readings {
+		measurements[i] = db.Measurement{
+			Timestamp: reading.Time,
+			SensorID:  SensorID(sensor),
+			MainValue: reading.Temperature,
+			Value:     reading,
+		}
+	}
+	return db.UpsertMeasurements(ctx, r.db, measurements)
+}
+
+// Readings returns the readings of a sensor between start and end (both
+// inclusive), oldest first.
+func (r *Repository) Readings(ctx context.Context, sensor string, start, end time.Time) ([]Reading, error) {
+	return r.query(ctx, `
+		SELECT timestamp, value FROM measurements
+		WHERE sensor_id = $1 AND timestamp >= $2 AND timestamp <= $3
+		ORDER BY timestamp`, SensorID(sensor), start.UTC(), end.UTC())
+}
+
+// Latest returns the newest values of a sensor (see Latest), false when it
+// has not reported within a day of its newest reading.
+func (r *Repository) Latest(ctx context.Context, sensor string) (Reading, bool, error) {
+	readings, err := r.query(ctx, `
+		SELECT timestamp, value FROM measurements
+		WHERE sensor_id = $1 AND timestamp >= (
+			SELECT MAX(timestamp) FROM measurements WHERE sensor_id = $1
+		) - $2::interval
+		ORDER BY timestamp DESC`, SensorID(sensor), fmt.Sprintf("%d seconds", int(latestWindow.Seconds())))
+	if err != nil {
+		return Reading{}, false, err
+	}
+	latest, ok := Latest(readings)
+	return latest, ok, nil
+}
+
+// Sensors returns the names of the sensors with stored readings.
+func (r *Repository) Sensors(ctx context.Context) ([]string, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT DISTINCT sensor_id FROM measurements
+		WHERE sensor_id LIKE $1 ORDER BY sensor_id`, sensorIDPrefix+"%")
+	if err != nil {
+		return nil, fmt.Errorf("querying indoor sensors: %w", err)
+	}
+	defer rows.Close()
+	sensors := []string{}
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("scanning indoor sensor: %w", err)
+		}
+		sensors = append(sensors, strings.TrimPrefix(id, sensorIDPrefix))
+	}
+	return sensors, rows.Err()
+}
+
+func (r *Repository) query(ctx context.Context, query string, args ...interface{}) ([]Reading, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("querying indoor readings: %w", err)
+	}
+	defer rows.Close()
+
+	var readings []Reading
+	for rows.Next() {
+		var ts time.Time
+		var value []byte
+		if err := rows.Scan(&ts, &value); err != nil {
+			return nil, fmt.Errorf("scanning indoor reading: %w", err)
+		}
+		var reading Reading
+		if err := json.Unmarshal(value, &reading); err != nil {
+			return nil, fmt.Errorf("decoding indoor reading at %s: %w", ts, err)
+		}
+		reading.Time = ts
+		readings = append(readings, reading)
+	}
+	return readings, rows.Err()
+}
@@ -0,0 +1,54 @@
+//go:build integration
+
+package indoor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mikahozz/gohome/config"
+	"github.com/mikahozz/gohome/db"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRepository_StoreAndRead(t *testing.T) {
+	config.LoadEnv()
+	conn, err := db.Open()
+	if err != nil {
+		t.Skipf("database not available: %v", err)
+	}
+	defer conn.Close()
+
+	const sensor = "integration-test"
+	cleanup := func() {
+		conn.Exec(`DELETE FROM measurements WHERE sensor_id = $1`, SensorID(sensor))
+	}
+	cleanup()
+	defer cleanup()
+
+	ctx := context.Background()
+	repo := NewRepository(conn)
+	start := time.Date(2001, 1, 1, 0, 0, 0, 0, time.UTC)
+	require.NoError(t, repo.Store(ctx, sensor, []Reading{
+		{Time: start, Temperature: float(20), Humidity: float(45), Battery: float(90)},
+		{Time: start.Add(time.Minute), Temperature: float(21)},
+	}))
+
+	readings, err := repo.Readings(ctx, sensor, start, start.Add(time.Hour))
+	require.NoError(t, err)
+	require.Len(t, readings, 2)
+	assert.True(t, readings[0].Time.Equal(start))
+	assert.Equal(t, 45.0, *readings[0].Humidity)
+
+	latest, ok, err := repo.Latest(ctx, sensor)
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, 21.0, *latest.Temperature)
+	assert.Equal(t, 45.0, *latest.Humidity, "humidity comes from the earlier reading")
+
+	sensors, err := repo.Sensors(ctx)
+	require.NoError(t, err)
+	assert.Contains(t, sensors, sensor)
+}
@@ -0,0 +1,196 @@
+// Package mqtt subscribes to sensor topics on an MQTT broker (Zigbee2MQTT,
+// Tasmota, ...) and stores the messages in the measurements table.
+package mqtt
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Sensor maps the messages of a topic to a sensor_id.
+type Sensor struct {
+	// Topic is a topic filter; "+" matches one level, a trailing "#" the rest.
+	Topic string `yaml:"topic" json:"topic"`
+	// SensorID is stored as measurements.sensor_id. {1}, {2}, ... are
+	// replaced with the topic levels matched by the first, second, ... "+".
+	SensorID string `yaml:"sensor_id" json:"sensor_id"`
+	// Value is the dot separated path of the main value in a JSON payload,
+	// e.g. "temperature" or "DS18B20.Temperature". Messages without it are
+	// skipped. Empty stores payloads without a main value; numeric payloads
+	// are their own main value.
+	Value string `yaml:"value" json:"value"`
+	// Time is the path of the message timestamp (RFC 3339, or local time
+	// without an offset as Tasmota sends it). The receive time is used when
+	// empty or missing.
+	Time string `yaml:"time" json:"time"`
+}
+
+// Config is the on-disk format of the MQTT settings.
+type Config struct {
+	Broker   string `yaml:"broker" json:"broker"` // e.g. tcp://192.168.1.10:1883
+	Username string `yaml:"username" json:"username"`
+	Password string `yaml:"password" json:"password"`
+	ClientID string `yaml:"client_id" json:"client_id"`
+	// TimeZone is the zone of payload timestamps without an offset.
+	TimeZone string   `yaml:"time_zone" json:"time_zone"`
+	Sensors  []Sensor `yaml:"sensors" json:"sensors"`
+}
+
+const (
+	defaultClientID = "gohome"
+	defaultTimeZone = "Europe/Helsinki"
+	// maxSensorID is the size of the measurements.sensor_id column.
+	maxSensorID = 50
+)
+
+var placeholder = regexp.MustCompile(`\{(\d+)\}`)
+
+// route is a validated Sensor.
+type route struct {
+	Sensor
+	filter    []string
+	wildcards int
+}
+
+func (c *Config) validate() ([]route, *time.Location, error) {
+	if strings.TrimSpace(c.Broker) == "" {
+		return nil, nil, errors.New("broker is required")
+	}
+	if c.ClientID == "" {
+		c.ClientID = defaultClientID
+	}
+	if c.TimeZone == "" {
+		c.TimeZone = defaultTimeZone
+	}
+	zone, err := time.LoadLocation(c.TimeZone)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid time_zone %q: %w", c.TimeZone, err)
+	}
+	if len(c.Sensors) == 0 {
+		return nil, nil, errors.New("no sensors configured")
+	}
+	routes := make([]route, 0, len(c.Sensors))
+	var errs []error
+	for i, s := range c.Sensors {
+		r, err := newRoute(s)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("sensor #%d (%q): %w", i+1, s.Topic, err))
+			continue
+		}
+		routes = append(routes, r)
+	}
+	if len(errs) > 0 {
+		return nil, nil, errors.Join(errs...)
+	}
+	return routes, zone, nil
+}
+
+func newRoute(s Sensor) (route, error) {
+	r := route{Sensor: s, filter: strings.Split(s.Topic, "/")}
+	if s.Topic == "" {
+		return r, errors.New("topic is required")
+	}
+	for i, level := range r.filter {
+		switch {
+		case level == "+":
+			r.wildcards++
+		case level == "#" && i == len(r.filter)-1:
+		case strings.ContainsAny(level, "+#"):
+			return r, errors.New("wildcards must be whole levels and # the last one")
+		}
+	}
+	if s.SensorID == "" {
+		return r, errors.New("sensor_id is required")
+	}
+	for _, m := range placeholder.FindAllStringSubmatch(s.SensorID, -1) {
+		if n, _ := strconv.Atoi(m[1]); n < 1 || n > r.wildcards {
+			return r, fmt.Errorf("sensor_id refers to %s but the topic has %d + wildcards", m[0], r.wildcards)
+		}
+	}
+	if r.wildcards == 0 && len(s.SensorID) > maxSensorID {
+		return r, fmt.Errorf("sensor_id longer than %d characters", maxSensorID)
+	}
+	return r, nil
+}
+
+// match reports whether topic matches the route's filter and returns the
+// sensor_id for it.
+func (r route) match(topic string) (string, bool) {
+	levels := strings.Split(topic, "/")
+	var wild []string
+	for i, f := range r.filter {
+		if f == "#" {
+			break
+		}
+		if i >= len(levels) {
+			return "", false
+		}
+		switch f {
+		case "+":
+			wild = append(wild, levels[i])
+		default:
+			if f != levels[i] {
+				return "", false
+			}
+		}
+	}
+	if r.filter[len(r.filter)-1] != "#" && len(levels) != len(r.filter) {
+		return "", false
+	}
+	id := placeholder.ReplaceAllStringFunc(r.SensorID, func(m string) string {
+		n, _ := strconv.Atoi(m[1 : len(m)-1])
+		return wild[n-1]
+	})
+	return id, true
+}
+
+// Load reads the configuration from a YAML or JSON file. ${VAR} references
+// are expanded from the environment.
+func Load(path string) (Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Config{}, fmt.Errorf("reading mqtt config: %w", err)
+	}
+	data = []byte(os.ExpandEnv(string(data)))
+	var c Config
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		err = json.Unmarshal(data, &c)
+	} else {
+		err = yaml.Unmarshal(data, &c)
+	}
+	if err != nil {
+		return Config{}, fmt.Errorf("parsing mqtt config %s: %w", path, err)
+	}
+	return c, nil
+}
+
+// Default loads the file named by MQTT_CONFIG (default mqtt.yaml). MQTT_BROKER,
+// MQTT_USERNAME and MQTT_PASSWORD override the file's settings.
+func Default() (Config, error) {
+	path := os.Getenv("MQTT_CONFIG")
+	if path == "" {
+		path = "mqtt.yaml"
+	}
+	if _, err := os.Stat(path); err != nil {
+		return Config{}, fmt.Errorf("no mqtt sensors configured: %s not found", path)
+	}
+	c, err := Load(path)
+	if err != nil {
+		return Config{}, err
+	}
+	for env, field := range map[string]*string{"MQTT_BROKER": &c.Broker, "MQTT_USERNAME": &c.Username, "MQTT_PASSWORD": &c.Password} {
+		if v := os.Getenv(env); v != "" {
+			*field = v
+		}
+	}
+	return c, nil
+}
@@ -0,0 +1,88 @@
+package mqtt
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfig_Validate(t *testing.T) {
+	valid := Sensor{Topic: "zigbee2mqtt/+", SensorID: "zigbee_{1}", Value: "temperature"}
+	for name, tc := range map[string]struct {
+		config Config
+		err    string
+	}{
+		"no broker":          {Config{Sensors: []Sensor{valid}}, "broker is required"},
+		"no sensors":         {Config{Broker: "tcp://localhost:1883"}, "no sensors configured"},
+		"bad zone":           {Config{Broker: "tcp://localhost:1883", TimeZone: "Mars/Olympus", Sensors: []Sensor{valid}}, "invalid time_zone"},
+		"no topic":           {Config{Broker: "tcp://localhost:1883", Sensors: []Sensor{{SensorID: "x"}}}, "topic is required"},
+		"no sensor_id":       {Config{Broker: "tcp://localhost:1883", Sensors: []Sensor{{Topic: "a/b"}}}, "sensor_id is required"},
+		"partial wildcard":   {Config{Broker: "tcp://localhost:1883", Sensors: []Sensor{{Topic: "a/b+", SensorID: "x"}}}, "whole levels"},
+		"# not last":         {Config{Broker: "tcp://localhost:1883", Sensors: []Sensor{{Topic: "a/#/b", SensorID: "x"}}}, "whole levels"},
+		"unknown wildcard":   {Config{Broker: "tcp://localhost:1883", Sensors: []Sensor{{Topic: "a/+", SensorID: "x_{2}"}}}, "refers to {2}"},
+		"sensor_id too long": {Config{Broker: "tcp://localhost:1883", Sensors: []Sensor{{Topic: "a", SensorID: "a_sensor_id_that_is_too_long_for_the_measurements_table"}}}, "longer than 50"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := NewSubscriber(tc.config, nil)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tc.err)
+		})
+	}
+
+	s, err := NewSubscriber(Config{Broker: "tcp://localhost:1883", Sensors: []Sensor{valid}}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "gohome", s.config.ClientID)
+	assert.Equal(t, "Europe/Helsinki", s.zone.String())
+}
+
+func TestRoute_Match(t *testing.T) {
+	for _, tc := range []struct {
+		filter, sensorID, topic string
+		want                    string
+		ok                      bool
+	}{
+		{"zigbee2mqtt/living_room", "living_room", "zigbee2mqtt/living_room", "living_room", true},
+		{"zigbee2mqtt/living_room", "living_room", "zigbee2mqtt/living_room/set", "", false},
+		{"zigbee2mqtt/+", "zigbee_{1}", "zigbee2mqtt/bedroom", "zigbee_bedroom", true},
+		{"zigbee2mqtt/+", "zigbee_{1}", "zigbee2mqtt", "", false},
+		{"tele/+/+", "tasmota_{1}_{2}", "tele/sauna/SENSOR", "tasmota_sauna_SENSOR", true},
+		{"home/#", "home", "home/a/b/c", "home", true},
+		{"home/+/#", "home_{1}", "home/kitchen/t", "home_kitchen", true},
+	} {
+		r, err := newRoute(Sensor{Topic: tc.filter, SensorID: tc.sensorID})
+		require.NoError(t, err)
+		got, ok := r.match(tc.topic)
+		assert.Equal(t, tc.ok, ok, "%s ~ %s", tc.filter, tc.topic)
+		assert.Equal(t, tc.want, got, "%s ~ %s", tc.filter, tc.topic)
+	}
+}
+
+func TestDefault(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "mqtt.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+broker: tcp://192.168.1.10:1883
+password: ${MQTT_TEST_PASSWORD}
+sensors:
+  - topic: zigbee2mqtt/+
+    sensor_id: zigbee_{1}
+    value: temperature
+`), 0o600))
+	t.Setenv("MQTT_CONFIG", path)
+	t.Setenv("MQTT_TEST_PASSWORD", "secret")
+	t.Setenv("MQTT_BROKER", "tcp://broker:1883")
+
+	c, err := Default()
+	require.NoError(t, err)
+	assert.Equal(t, "tcp://broker:1883", c.Broker, "MQTT_BROKER overrides the file")
+	assert.Equal(t, "secret", c.Password)
+	require.Len(t, c.Sensors, 1)
+	assert.Equal(t, "temperature", c.Sensors[0].Value)
+
+	t.Setenv("MQTT_CONFIG", filepath.Join(dir, "missing.yaml"))
+	_, err = Default()
+	assert.Error(t, err)
+}
@@ -0,0 +1,207 @@
+package mqtt
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	paho "github.com/eclipse/paho.mqtt.golang"
+	"github.com/mikahozz/gohome/db"
+	"github.com/rs/zerolog/log"
+)
+
+// ErrSkipped is returned for messages that are not stored: topics no sensor
+// matches and payloads without the configured value.
+var ErrSkipped = errors.New("mqtt message skipped")
+
+// storeTimeout bounds storing a single message.
+const storeTimeout = 10 * time.Second
+
+// Store saves measurements.
+type Store interface {
+	Store(ctx context.Context, measurements []db.Measurement) error
+}
+
+type dbStore struct{ conn *sql.DB }
+
+func (s dbStore) Store(ctx context.Context, measurements []db.Measurement) error {
+	return db.UpsertMeasurements(ctx, s.conn, measurements)
+}
+
+// NewDBStore upserts measurements into the measurements table.
+func NewDBStore(conn *sql.DB) Store {
+	return dbStore{conn: conn}
+}
+
+// Subscriber stores the messages of the configured sensor topics.
+type Subscriber struct {
+	config Config
+	routes []route
+	zone   *time.Location
+	store  Store
+	now    func() time.Time
+}
+
+// NewSubscriber validates the configuration.
+func NewSubscriber(config Config, store Store) (*Subscriber, error) {
+	routes, zone, err := config.validate()
+	if err != nil {
+		return nil, fmt.Errorf("invalid mqtt config: %w", err)
+	}
+	return &Subscriber{config: config, routes: routes, zone: zone, store: store, now: time.Now}, nil
+}
+
+// Measurement converts a message to a measurement using the first sensor
+// whose topic matches. The full payload is kept as the value.
+func (s *Subscriber) Measurement(topic string, payload []byte) (db.Measurement, error) {
+	for _, r := range s.routes {
+		sensorID, ok := r.match(topic)
+		if !ok {
+			continue
+		}
+		if len(sensorID) > maxSensorID {
+			return db.Measurement{}, fmt.Errorf("sensor_id %q for topic %s is longer than %d characters", sensorID, topic, maxSensorID)
+		}
+		var body interface{}
+		if err := json.Unmarshal(payload, &body); err != nil {
+			return db.Measurement{}, fmt.Errorf("%w: %s payload is not JSON", ErrSkipped, topic)
+		}
+		m := db.Measurement{
+			Timestamp: s.now().Truncate(time.Second),
+			SensorID:  sensorID,
+			Value:     json.RawMessage(payload),
+		}
+		if r.Value != "" {
+			v, ok := number(lookup(body, r.Value))
+			if !ok {
+				return db.Measurement{}, fmt.Errorf("%w: %s has no numeric %s", ErrSkipped, topic, r.Value)
+			}
+			m.MainValue = &v
+		} else if v, ok := body.(float64); ok {
+			m.MainValue = &v
+		}
+		if r.Time != "" {
+			if t, ok := s.timestamp(lookup(body, r.Time)); ok {
+				m.Timestamp = t
+			}
+		}
+		return m, nil
+	}
+	return db.Measurement{}, fmt.Errorf("%w: no sensor for topic %s", ErrSkipped, topic)
+}
+
+// Handle stores a message.
+func (s *Subscriber) Handle(ctx context.Context, topic string, payload []byte) error {
+	m, err := s.Measurement(topic, payload)
+	if err != nil {
+		return err
+	}
+	if err := s.store.Store(ctx, []db.Measurement{m}); err != nil {
+		return fmt.Errorf("storing %s measurement: %w", m.SensorID, err)
+	}
+	log.Debug().Str("event", "mqtt_measurement").Str("topic", topic).Str("sensor_id", m.SensorID).Time("timestamp", m.Timestamp).Msg("Stored MQTT measurement")
+	return nil
+}
+
+// Run connects to the broker and stores messages until ctx is cancelled.
+// The connection is retried and the topics resubscribed after reconnecting.
+func (s *Subscriber) Run(ctx context.Context) error {
+	filters := make(map[string]byte)
+	for _, r := range s.routes {
+		filters[r.Topic] = 1
+	}
+	opts := paho.NewClientOptions().
+		AddBroker(s.config.Broker).
+		SetClientID(s.config.ClientID).
+		SetUsername(s.config.Username).
+		SetPassword(s.config.Password).
+		SetAutoReconnect(true).
+		SetConnectRetry(true).
+		SetConnectRetryInterval(10 * time.Second).
+		SetMaxReconnectInterval(time.Minute).
+		SetOnConnectHandler(func(c paho.Client) {
+			log.Info().Str("event", "mqtt_connected").Str("broker", s.config.Broker).Int("topics", len(filters)).Msg("Connected to MQTT broker")
+			token := c.SubscribeMultiple(filters, s.onMessage(ctx))
+			if token.Wait() && token.Error() != nil {
+				log.Error().Err(token.Error()).Str("event", "mqtt_subscribe_error").Msg("Failed to subscribe to MQTT topics")
+			}
+		}).
+		SetConnectionLostHandler(func(_ paho.Client, err error) {
+			log.Warn().Err(err).Str("event", "mqtt_connection_lost").Msg("Lost connection to MQTT broker, reconnecting")
+		})
+	client := paho.NewClient(opts)
+	token := client.Connect()
+	select {
+	case <-token.Done():
+		if err := token.Error(); err != nil {
+			return fmt.Errorf("connecting to mqtt broker %s: %w", s.config.Broker, err)
+		}
+	case <-ctx.Done():
+	}
+	<-ctx.Done()
+	client.Disconnect(250)
+	return nil
+}
+
+func (s *Subscriber) onMessage(ctx context.Context) paho.MessageHandler {
+	return func(_ paho.Client, msg paho.Message) {
+		storeCtx, cancel := context.WithTimeout(ctx, storeTimeout)
+		defer cancel()
+		err := s.Handle(storeCtx, msg.Topic(), msg.Payload())
+		switch {
+		case errors.Is(err, ErrSkipped):
+			log.Debug().Err(err).Str("event", "mqtt_skipped").Str("topic", msg.Topic()).Msg("Skipped MQTT message")
+		case err != nil:
+			log.Error().Err(err).Str("event", "mqtt_store_error").Str("topic", msg.Topic()).Msg("Failed to store MQTT message")
+		}
+	}
+}
+
+// lookup follows a dot separated path into a decoded JSON document.
+func lookup(body interface{}, path string) interface{} {
+	for _, key := range strings.Split(path, ".") {
+		obj, ok := body.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		body = obj[key]
+	}
+	return body
+}
+
+// number converts JSON numbers, numeric strings and booleans (e.g. a
+// Zigbee2MQTT "occupancy") to a float.
+func number(v interface{}) (float64, bool) {
+	switch v := v.(type) {
+	case float64:
+		return v, true
+	case string:
+		f, err := strconv.ParseFloat(v, 64)
+		return f, err == nil
+	case bool:
+		if v {
+			return 1, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+func (s *Subscriber) timestamp(v interface{}) (time.Time, bool) {
+	str, ok := v.(string)
+	if !ok {
+		return time.Time{}, false
+	}
+	if t, err := time.Parse(time.RFC3339, str); err == nil {
+		return t, true
+	}
+	if t, err := time.ParseInLocation("2006-01-02T15:04:05", str, s.zone); err == nil {
+		return t, true
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,150 @@
+package mqtt
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mikahozz/gohome/db"
+	mochi "github.com/mochi-mqtt/server/v2"
+	"github.com/mochi-mqtt/server/v2/hooks/auth"
+	"github.com/mochi-mqtt/server/v2/listeners"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeStore struct {
+	mu           sync.Mutex
+	measurements []db.Measurement
+	err          error
+}
+
+func (f *fakeStore) Store(ctx context.Context, measurements []db.Measurement) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.err != nil {
+		return f.err
+	}
+	f.measurements = append(f.measurements, measurements...)
+	return nil
+}
+
+func (f *fakeStore) stored() []db.Measurement {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]db.Measurement(nil), f.measurements...)
+}
+
+var testSensors = []Sensor{
+	{Topic: "zigbee2mqtt/bridge/#", SensorID: "ignored"},
+	{Topic: "zigbee2mqtt/+", SensorID: "zigbee_{1}", Value: "temperature", Time: "last_seen"},
+	{Topic: "tele/+/SENSOR", SensorID: "tasmota_{1}", Value: "DS18B20.Temperature", Time: "Time"},
+	{Topic: "home/+/power", SensorID: "power_{1}"},
+}
+
+func newTestSubscriber(t *testing.T, broker string, store Store) *Subscriber {
+	t.Helper()
+	s, err := NewSubscriber(Config{Broker: broker, ClientID: "gohome-test", Sensors: testSensors}, store)
+	require.NoError(t, err)
+	return s
+}
+
+func TestSubscriber_Measurement(t *testing.T) {
+	s := newTestSubscriber(t, "tcp://localhost:1883", nil)
+	now := time.Date(2025, 11, 3, 7, 0, 0, 500, time.UTC)
+	s.now = func() time.Time { return now }
+
+	// Zigbee2MQTT sensor, last_seen in ISO 8601
+	m, err := s.Measurement("zigbee2mqtt/bedroom", []byte(`{"temperature":21.5,"humidity":40,"battery":97,"last_seen":"2025-11-03T08:59:58+02:00"}`))
+	require.NoError(t, err)
+	assert.Equal(t, "zigbee_bedroom", m.SensorID)
+	assert.Equal(t, 21.5, *m.MainValue)
+	assert.True(t, m.Timestamp.Equal(time.Date(2025, 11, 3, 6, 59, 58, 0, time.UTC)))
+	assert.JSONEq(t, `{"temperature":21.5,"humidity":40,"battery":97,"last_seen":"2025-11-03T08:59:58+02:00"}`, string(m.Value.(json.RawMessage)))
+
+	// Tasmota SENSOR telemetry with local time
+	m, err = s.Measurement("tele/sauna/SENSOR", []byte(`{"Time":"2025-11-03T09:00:00","DS18B20":{"Id":"01","Temperature":80.1},"TempUnit":"C"}`))
+	require.NoError(t, err)
+	assert.Equal(t, "tasmota_sauna", m.SensorID)
+	assert.Equal(t, 80.1, *m.MainValue)
+	assert.True(t, m.Timestamp.Equal(time.Date(2025, 11, 3, 7, 0, 0, 0, time.UTC)), "local time in Europe/Helsinki")
+
+	// Plain number without a value path, receive time
+	m, err = s.Measurement("home/garage/power", []byte(`152.3`))
+	require.NoError(t, err)
+	assert.Equal(t, "power_garage", m.SensorID)
+	assert.Equal(t, 152.3, *m.MainValue)
+	assert.Equal(t, now.Truncate(time.Second), m.Timestamp)
+
+	// The first matching sensor wins; it has no value, so none is stored
+	m, err = s.Measurement("zigbee2mqtt/bridge/state", []byte(`{"state":"online"}`))
+	require.NoError(t, err)
+	assert.Equal(t, "ignored", m.SensorID)
+	assert.Nil(t, m.MainValue)
+
+	for topic, payload := range map[string]string{
+		"zigbee2mqtt/switch": `{"state":"ON"}`, // no temperature
+		"home/garage/power":  `ON`,             // not JSON
+		"unknown/topic":      `{}`,
+	} {
+		_, err := s.Measurement(topic, []byte(payload))
+		assert.True(t, errors.Is(err, ErrSkipped), "%s: %v", topic, err)
+	}
+}
+
+func TestSubscriber_Handle(t *testing.T) {
+	store := &fakeStore{err: errors.New("db down")}
+	s := newTestSubscriber(t, "tcp://localhost:1883", store)
+	err := s.Handle(context.Background(), "zigbee2mqtt/bedroom", []byte(`{"temperature":21.5}`))
+	assert.ErrorContains(t, err, "db down")
+}
+
+// startBroker runs an in-process MQTT broker on a free local port.
+func startBroker(t *testing.T) (*mochi.Server, string) {
+	t.Helper()
+	server := mochi.New(&mochi.Options{InlineClient: true})
+	require.NoError(t, server.AddHook(new(auth.AllowHook), nil))
+	tcp := listeners.NewTCP(listeners.Config{ID: "t1", Address: "127.0.0.1:0"})
+	require.NoError(t, server.AddListener(tcp))
+	require.NoError(t, server.Serve())
+	t.Cleanup(func() { server.Close() })
+	return server, fmt.Sprintf("tcp://%s", tcp.Address())
+}
+
+func TestSubscriber_Run(t *testing.T) {
+	server, broker := startBroker(t)
+	store := &fakeStore{}
+	s := newTestSubscriber(t, broker, store)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- s.Run(ctx) }()
+	defer func() {
+		cancel()
+		assert.NoError(t, <-done)
+	}()
+
+	// Publish until the subscription is in place
+	require.Eventually(t, func() bool {
+		require.NoError(t, server.Publish("zigbee2mqtt/bedroom", []byte(`{"temperature":21.5}`), false, 0))
+		return len(store.stored()) > 0
+	}, 5*time.Second, 50*time.Millisecond)
+
+	require.NoError(t, server.Publish("tele/sauna/SENSOR", []byte(`{"DS18B20":{"Temperature":80.1}}`), false, 1))
+	require.NoError(t, server.Publish("other/topic", []byte(`{"temperature":1}`), false, 1))
+	require.Eventually(t, func() bool {
+		for _, m := range store.stored() {
+			if m.SensorID == "tasmota_sauna" {
+				return *m.MainValue == 80.1
+			}
+		}
+		return false
+	}, 5*time.Second, 20*time.Millisecond)
+	for _, m := range store.stored() {
+		assert.Contains(t, []string{"zigbee_bedroom", "tasmota_sauna"}, m.SensorID)
+	}
+}
@@ -0,0 +1,123 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Webhook posts messages as JSON:
+//
+//	{"title": "...", "body": "...", "priority": "high", "key": "...", "time": "..."}
+type Webhook struct {
+	URL    string
+	Client *http.Client // optional
+}
+
+func (w *Webhook) Notify(ctx context.Context, m Message) error {
+	payload, err := json.Marshal(map[string]string{
+		"title":    m.Title,
+		"body":     m.Body,
+		"priority": m.Priority.String(),
+		"key":      m.Key,
+		"time":     time.Now().UTC().Format(time.RFC3339),
+	})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("webhook: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return send(w.Client, req, "webhook")
+}
+
+// Ntfy publishes messages to an ntfy topic (https://ntfy.sh or self-hosted).
+type Ntfy struct {
+	URL    string // topic URL, e.g. https://ntfy.sh/gohome
+	Token  string // optional access token
+	Client *http.Client
+}
+
+// ntfyPriorities maps priorities to ntfy's 1 (min) - 5 (max) scale.
+var ntfyPriorities = map[Priority]int{Low: 2, Normal: 3, High: 4}
+
+func (n *Ntfy) Notify(ctx context.Context, m Message) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.URL, strings.NewReader(m.Body))
+	if err != nil {
+		return fmt.Errorf("ntfy: %w", err)
+	}
+	req.Header.Set("Title", m.Title)
+	req.Header.Set("Priority", strconv.Itoa(ntfyPriorities[m.Priority]))
+	if m.Priority == High {
+		req.Header.Set("Tags", "warning")
+	}
+	if n.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+n.Token)
+	}
+	return send(n.Client, req, "ntfy")
+}
+
+// Telegram sends messages to a chat through the Telegram Bot API.
+type Telegram struct {
+	Token   string
+	ChatID  string
+	BaseURL string // defaults to https://api.telegram.org
+	Client  *http.Client
+}
+
+func (t *Telegram) Notify(ctx context.Context, m Message) error {
+	base := t.BaseURL
+	if base == "" {
+		base = "https://api.telegram.org"
+	}
+	text := m.Title
+	if m.Body != "" {
+		text += "\n\n" + m.Body
+	}
+	payload, err := json.Marshal(map[string]interface{}{
+		"chat_id":              t.ChatID,
+		"text":                 text,
+		"disable_notification": m.Priority == Low,
+	})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(base, "/")+"/bot"+t.Token+"/sendMessage", bytes.NewReader(payload))
+	if err != nil {
+		// The URL contains the token, keep it out of logs
+		return fmt.Errorf("telegram: invalid base URL %q", base)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return send(t.Client, req, "telegram")
+}
+
+// send performs the request and fails on a non-2xx response.
+func send(client *http.Client, req *http.Request, channel string) error {
+	if client == nil {
+		client = defaultHTTPClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		var uerr *url.Error
+		if errors.As(err, &uerr) {
+			err = uerr.Err // the URL may contain a token
+		}
+		return fmt.Errorf("%s: %w", channel, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s: status %d: %s", channel, resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingServer stores the last request and answers with status.
+type recordingServer struct {
+	status  int
+	path    string
+	headers http.Header
+	body    []byte
+}
+
+func (r *recordingServer) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.path = req.URL.Path
+	r.headers = req.Header.Clone()
+	r.body, _ = io.ReadAll(req.Body)
+	if r.status != 0 {
+		http.Error(w, "chat not found", r.status)
+		return
+	}
+	w.Write([]byte(`{"ok":true}`))
+}
+
+var alert = Message{Title: "Action failed: Heater", Body: "device offline", Priority: High, Key: "action_error:Heater"}
+
+func TestWebhook(t *testing.T) {
+	rec := &recordingServer{}
+	srv := httptest.NewServer(rec)
+	defer srv.Close()
+
+	require.NoError(t, (&Webhook{URL: srv.URL + "/hook"}).Notify(context.Background(), alert))
+	assert.Equal(t, "/hook", rec.path)
+	assert.Equal(t, "application/json", rec.headers.Get("Content-Type"))
+	var got map[string]string
+	require.NoError(t, json.Unmarshal(rec.body, &got))
+	assert.Equal(t, "Action failed: Heater", got["title"])
+	assert.Equal(t, "device offline", got["body"])
+	assert.Equal(t, "high", got["priority"])
+	assert.Equal(t, "action_error:Heater", got["key"])
+	assert.NotEmpty(t, got["time"])
+}
+
+func TestNtfy(t *testing.T) {
+	rec := &recordingServer{}
+	srv := httptest.NewServer(rec)
+	defer srv.Close()
+
+	require.NoError(t, (&Ntfy{URL: srv.URL + "/gohome", Token: "tk_secret"}).Notify(context.Background(), alert))
+	assert.Equal(t, "/gohome", rec.path)
+	assert.Equal(t, "device offline", string(rec.body))
+	assert.Equal(t, "Action failed: Heater", rec.headers.Get("Title"))
+	assert.Equal(t, "4", rec.headers.Get("Priority"))
+	assert.Equal(t, "warning", rec.headers.Get("Tags"))
+	assert.Equal(t, "Bearer tk_secret", rec.headers.Get("Authorization"))
+}
+
+func TestTelegram(t *testing.T) {
+	rec := &recordingServer{}
+	srv := httptest.NewServer(rec)
+	defer srv.Close()
+	tg := &Telegram{Token: "123:abc", ChatID: "-100200", BaseURL: srv.URL}
+
+	require.NoError(t, tg.Notify(context.Background(), alert))
+	assert.Equal(t, "/bot123:abc/sendMessage", rec.path)
+	var got map[string]interface{}
+	require.NoError(t, json.Unmarshal(rec.body, &got))
+	assert.Equal(t, "-100200", got["chat_id"])
+	assert.Equal(t, "Action failed: Heater\n\ndevice offline", got["text"])
+
+	rec.status = http.StatusBadRequest
+	err := tg.Notify(context.Background(), alert)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "status 400: chat not found")
+
+	srv.Close()
+	err = tg.Notify(context.Background(), alert)
+	require.Error(t, err)
+	assert.NotContains(t, err.Error(), "123:abc", "the token is not leaked into logs")
+}
+
+func TestFromEnv(t *testing.T) {
+	for _, name := range []string{"NOTIFY_WEBHOOK_URL", "NOTIFY_NTFY_URL", "NOTIFY_SMTP_ADDR", "NOTIFY_TELEGRAM_TOKEN", "NOTIFY_DEDUP_WINDOW", "NOTIFY_MAX_PER_HOUR"} {
+		t.Setenv(name, "")
+	}
+	n, err := FromEnv()
+	require.NoError(t, err)
+	assert.Equal(t, Nop{}, n)
+
+	t.Setenv("NOTIFY_NTFY_URL", "https://ntfy.sh/gohome")
+	t.Setenv("NOTIFY_WEBHOOK_URL", "http://10.0.0.2/hook")
+	n, err = FromEnv()
+	require.NoError(t, err)
+	l, ok := n.(*Limiter)
+	require.True(t, ok)
+	assert.Len(t, l.next, 2)
+
+	t.Setenv("NOTIFY_TELEGRAM_TOKEN", "123:abc")
+	_, err = FromEnv()
+	assert.ErrorContains(t, err, "NOTIFY_TELEGRAM_CHAT_ID")
+
+	t.Setenv("NOTIFY_TELEGRAM_CHAT_ID", "42")
+	t.Setenv("NOTIFY_MAX_PER_HOUR", "many")
+	_, err = FromEnv()
+	assert.ErrorContains(t, err, "NOTIFY_MAX_PER_HOUR")
+}
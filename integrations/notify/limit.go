@@ -0,0 +1,83 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Limiter keeps a failing device from sending a notification every minute.
+// A message is dropped when one with the same key was sent within Window, and
+// when Burst messages were already sent within Period. High priority messages
+// are only deduplicated, never rate limited. The next message with the key
+// tells how many repeats were dropped.
+type Limiter struct {
+	next   Notifier
+	window time.Duration
+	burst  int
+	period time.Duration
+	now    func() time.Time
+
+	mu         sync.Mutex
+	sent       map[string]time.Time // last send per key
+	suppressed map[string]int       // dropped repeats per key since then
+	recent     []time.Time          // sends within period
+}
+
+// NewLimiter wraps next. A zero window disables deduplication and a zero
+// burst the rate limit.
+func NewLimiter(next Notifier, window time.Duration, burst int, period time.Duration) *Limiter {
+	return &Limiter{
+		next:       next,
+		window:     window,
+		burst:      burst,
+		period:     period,
+		now:        time.Now,
+		sent:       make(map[string]time.Time),
+		suppressed: make(map[string]int),
+	}
+}
+
+func (l *Limiter) Notify(ctx context.Context, m Message) error {
+	l.mu.Lock()
+	now := l.now()
+	for key, at := range l.sent {
+		if now.Sub(at) >= l.window && l.suppressed[key] == 0 {
+			delete(l.sent, key)
+		}
+	}
+	if at, ok := l.sent[m.Key]; ok && m.Key != "" && now.Sub(at) < l.window {
+		l.suppressed[m.Key]++
+		l.mu.Unlock()
+		log.Debug().Str("event", "notification_suppressed").Str("key", m.Key).Msg("duplicate notification dropped")
+		return nil
+	}
+	kept := l.recent[:0]
+	for _, at := range l.recent {
+		if now.Sub(at) < l.period {
+			kept = append(kept, at)
+		}
+	}
+	l.recent = kept
+	if l.burst > 0 && len(l.recent) >= l.burst && m.Priority < High {
+		if m.Key != "" {
+			l.suppressed[m.Key]++
+		}
+		l.mu.Unlock()
+		log.Warn().Str("event", "notification_rate_limited").Str("key", m.Key).Str("title", m.Title).Msg("notification dropped by rate limit")
+		return nil
+	}
+	if n := l.suppressed[m.Key]; n > 0 {
+		m.Body += fmt.Sprintf("\n\n(%d similar notifications suppressed)", n)
+		delete(l.suppressed, m.Key)
+	}
+	if m.Key != "" {
+		l.sent[m.Key] = now
+	}
+	l.recent = append(l.recent, now)
+	l.mu.Unlock()
+	return l.next.Notify(ctx, m)
+}
@@ -0,0 +1,84 @@
+package notify
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type recorder struct {
+	mu       sync.Mutex
+	messages []Message
+}
+
+func (r *recorder) Notify(ctx context.Context, m Message) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.messages = append(r.messages, m)
+	return nil
+}
+
+func TestLimiter_Deduplicates(t *testing.T) {
+	rec := &recorder{}
+	now := time.Date(2025, 11, 3, 7, 0, 0, 0, time.UTC)
+	l := NewLimiter(rec, time.Hour, 0, 0)
+	l.now = func() time.Time { return now }
+	plugDown := Message{Title: "Action failed: Heater", Body: "device offline", Key: "action_error:Heater"}
+
+	for i := 0; i < 30; i++ { // every minute for half an hour
+		require.NoError(t, l.Notify(context.Background(), plugDown))
+		now = now.Add(time.Minute)
+	}
+	require.NoError(t, l.Notify(context.Background(), Message{Title: "Sync failed", Key: "sync_error:SPOT_PRICE"}))
+	require.NoError(t, l.Notify(context.Background(), Message{Title: "Unkeyed"}))
+	require.NoError(t, l.Notify(context.Background(), Message{Title: "Unkeyed"}))
+	require.Len(t, rec.messages, 4, "one per key, unkeyed ones always")
+
+	now = now.Add(30 * time.Minute)
+	require.NoError(t, l.Notify(context.Background(), plugDown))
+	require.Len(t, rec.messages, 5)
+	assert.Equal(t, "device offline\n\n(29 similar notifications suppressed)", rec.messages[4].Body)
+}
+
+func TestLimiter_RateLimit(t *testing.T) {
+	rec := &recorder{}
+	now := time.Date(2025, 11, 3, 7, 0, 0, 0, time.UTC)
+	l := NewLimiter(rec, time.Hour, 3, time.Hour)
+	l.now = func() time.Time { return now }
+
+	for _, key := range []string{"a", "b", "c", "d", "e"} {
+		require.NoError(t, l.Notify(context.Background(), Message{Title: key, Key: key}))
+		now = now.Add(time.Minute)
+	}
+	require.Len(t, rec.messages, 3)
+
+	now = now.Add(time.Hour)
+	require.NoError(t, l.Notify(context.Background(), Message{Title: "d", Body: "again", Key: "d"}))
+	require.Len(t, rec.messages, 4)
+	assert.Equal(t, "again\n\n(1 similar notifications suppressed)", rec.messages[3].Body)
+}
+
+func TestLimiter_HighPriorityBypassesRateLimit(t *testing.T) {
+	rec := &recorder{}
+	now := time.Date(2025, 11, 3, 7, 0, 0, 0, time.UTC)
+	l := NewLimiter(rec, time.Hour, 2, time.Hour)
+	l.now = func() time.Time { return now }
+
+	for _, key := range []string{"a", "b", "c"} {
+		require.NoError(t, l.Notify(context.Background(), Message{Title: key, Key: key}))
+	}
+	require.Len(t, rec.messages, 2)
+
+	gaveUp := Message{Title: "Sync gave up", Priority: High, Key: "sync_gave_up:SPOT_PRICE"}
+	require.NoError(t, l.Notify(context.Background(), gaveUp))
+	require.Len(t, rec.messages, 3, "high priority is sent despite the rate limit")
+	assert.Equal(t, "Sync gave up", rec.messages[2].Title)
+
+	now = now.Add(time.Minute)
+	require.NoError(t, l.Notify(context.Background(), gaveUp))
+	require.Len(t, rec.messages, 3, "high priority is still deduplicated")
+}
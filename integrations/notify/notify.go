@@ -0,0 +1,141 @@
+// Package notify sends alerts about failed actions and other notable events
+// to a webhook, an ntfy topic, email or a Telegram chat.
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Priority tells how urgent a message is. Channels that support it (ntfy)
+// pass it on.
+type Priority int
+
+const (
+	Low Priority = iota - 1
+	Normal
+	High
+)
+
+func (p Priority) String() string {
+	switch p {
+	case Low:
+		return "low"
+	case High:
+		return "high"
+	}
+	return "normal"
+}
+
+// Message is a single notification.
+type Message struct {
+	Title    string
+	Body     string
+	Priority Priority
+	// Key identifies repeats of the same event for deduplication, e.g.
+	// "action_error:Heater". Messages without a key are not deduplicated.
+	Key string
+}
+
+// Notifier delivers messages.
+type Notifier interface {
+	Notify(ctx context.Context, m Message) error
+}
+
+// Nop drops every message. It is used when no channel is configured.
+type Nop struct{}
+
+func (Nop) Notify(context.Context, Message) error { return nil }
+
+// Multi sends every message to all of its notifiers.
+type Multi []Notifier
+
+func (m Multi) Notify(ctx context.Context, msg Message) error {
+	var errs []error
+	for _, n := range m {
+		if err := n.Notify(ctx, msg); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// defaultHTTPClient is used by the HTTP channels without a client of their own.
+var defaultHTTPClient = &http.Client{Timeout: 10 * time.Second}
+
+// FromEnv returns the channels configured in the environment, limited with
+// NOTIFY_DEDUP_WINDOW (default 1h) and NOTIFY_MAX_PER_HOUR (default 20):
+//
+//	NOTIFY_WEBHOOK_URL                      generic JSON webhook
+//	NOTIFY_NTFY_URL, NOTIFY_NTFY_TOKEN      ntfy topic URL, e.g. https://ntfy.sh/gohome
+//	NOTIFY_SMTP_ADDR, NOTIFY_SMTP_FROM,     email over SMTP (host:port); NOTIFY_SMTP_TO
+//	NOTIFY_SMTP_TO, NOTIFY_SMTP_USERNAME,   is a comma separated list
+//	NOTIFY_SMTP_PASSWORD
+//	NOTIFY_TELEGRAM_TOKEN,                  Telegram bot
+//	NOTIFY_TELEGRAM_CHAT_ID
+//
+// Without any channel the result is Nop.
+func FromEnv() (Notifier, error) {
+	var channels Multi
+	if url := os.Getenv("NOTIFY_WEBHOOK_URL"); url != "" {
+		channels = append(channels, &Webhook{URL: url})
+	}
+	if url := os.Getenv("NOTIFY_NTFY_URL"); url != "" {
+		channels = append(channels, &Ntfy{URL: url, Token: os.Getenv("NOTIFY_NTFY_TOKEN")})
+	}
+	if addr := os.Getenv("NOTIFY_SMTP_ADDR"); addr != "" {
+		s := &SMTP{
+			Addr:     addr,
+			From:     os.Getenv("NOTIFY_SMTP_FROM"),
+			Username: os.Getenv("NOTIFY_SMTP_USERNAME"),
+			Password: os.Getenv("NOTIFY_SMTP_PASSWORD"),
+		}
+		for _, to := range strings.Split(os.Getenv("NOTIFY_SMTP_TO"), ",") {
+			if to = strings.TrimSpace(to); to != "" {
+				s.To = append(s.To, to)
+			}
+		}
+		if s.From == "" || len(s.To) == 0 {
+			return nil, errors.New("NOTIFY_SMTP_FROM and NOTIFY_SMTP_TO are required with NOTIFY_SMTP_ADDR")
+		}
+		channels = append(channels, s)
+	}
+	if token := os.Getenv("NOTIFY_TELEGRAM_TOKEN"); token != "" {
+		chat := os.Getenv("NOTIFY_TELEGRAM_CHAT_ID")
+		if chat == "" {
+			return nil, errors.New("NOTIFY_TELEGRAM_CHAT_ID is required with NOTIFY_TELEGRAM_TOKEN")
+		}
+		channels = append(channels, &Telegram{Token: token, ChatID: chat})
+	}
+	if len(channels) == 0 {
+		return Nop{}, nil
+	}
+
+	window := time.Hour
+	if s := os.Getenv("NOTIFY_DEDUP_WINDOW"); s != "" {
+		d, err := time.ParseDuration(s)
+		if err != nil || d < 0 {
+			return nil, fmt.Errorf("invalid NOTIFY_DEDUP_WINDOW %q", s)
+		}
+		window = d
+	}
+	perHour := 20
+	if s := os.Getenv("NOTIFY_MAX_PER_HOUR"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid NOTIFY_MAX_PER_HOUR %q", s)
+		}
+		perHour = n
+	}
+	var n Notifier = channels
+	if len(channels) == 1 {
+		n = channels[0]
+	}
+	return NewLimiter(n, window, perHour, time.Hour), nil
+}
@@ -0,0 +1,100 @@
+package notify
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"net"
+	"net/textproto"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeSMTP is a minimal SMTP server accepting a single mail.
+type fakeSMTP struct {
+	ln   net.Listener
+	auth string
+	from string
+	to   []string
+	data string
+	done chan struct{}
+}
+
+func newFakeSMTP(t *testing.T) *fakeSMTP {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	f := &fakeSMTP{ln: ln, done: make(chan struct{})}
+	t.Cleanup(func() { ln.Close() })
+	go f.serve()
+	return f
+}
+
+func (f *fakeSMTP) serve() {
+	defer close(f.done)
+	conn, err := f.ln.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	tp := textproto.NewConn(conn)
+	tp.PrintfLine("220 localhost ESMTP")
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+		cmd := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+		switch {
+		case cmd == "EHLO":
+			tp.PrintfLine("250-localhost")
+			tp.PrintfLine("250 AUTH PLAIN")
+		case cmd == "AUTH":
+			decoded, _ := base64.StdEncoding.DecodeString(strings.TrimPrefix(line, "AUTH PLAIN "))
+			f.auth = string(decoded)
+			tp.PrintfLine("235 Authentication successful")
+		case strings.HasPrefix(strings.ToUpper(line), "MAIL FROM:"):
+			f.from = line[len("MAIL FROM:"):]
+			tp.PrintfLine("250 OK")
+		case strings.HasPrefix(strings.ToUpper(line), "RCPT TO:"):
+			f.to = append(f.to, line[len("RCPT TO:"):])
+			tp.PrintfLine("250 OK")
+		case cmd == "DATA":
+			tp.PrintfLine("354 End data with <CR><LF>.<CR><LF>")
+			lines, _ := tp.ReadDotLines()
+			f.data = strings.Join(lines, "\n")
+			tp.PrintfLine("250 OK")
+		case cmd == "QUIT":
+			tp.PrintfLine("221 Bye")
+			return
+		default:
+			tp.PrintfLine("250 OK")
+		}
+	}
+}
+
+func TestSMTP(t *testing.T) {
+	f := newFakeSMTP(t)
+	s := &SMTP{Addr: f.ln.Addr().String(), From: "gohome@example.com", To: []string{"mika@example.com", "anna@example.com"}, Username: "gohome", Password: "secret"}
+
+	require.NoError(t, s.Notify(context.Background(), Message{Title: "Sync failed: SPOT_PRICE", Body: "ENTSO-E unavailable\nretrying in 5m", Priority: High}))
+	<-f.done
+	assert.Equal(t, "\x00gohome\x00secret", f.auth)
+	assert.Equal(t, "<gohome@example.com>", f.from)
+	assert.Equal(t, []string{"<mika@example.com>", "<anna@example.com>"}, f.to)
+	assert.Contains(t, f.data, "Subject: Sync failed: SPOT_PRICE\n")
+	assert.Contains(t, f.data, "To: mika@example.com, anna@example.com\n")
+	assert.Contains(t, f.data, "X-Priority: 1\n")
+	assert.True(t, strings.HasSuffix(f.data, "\nENTSO-E unavailable\nretrying in 5m"), f.data)
+}
+
+func TestSMTP_EncodesSubject(t *testing.T) {
+	msg := string((&SMTP{From: "a@example.com", To: []string{"b@example.com"}}).message(Message{Title: "Sähkö kallista"}))
+	assert.Contains(t, msg, "Subject: =?utf-8?q?S=C3=A4hk=C3=B6_kallista?=\r\n")
+	reader := textproto.NewReader(bufio.NewReader(strings.NewReader(msg)))
+	header, err := reader.ReadMIMEHeader()
+	require.NoError(t, err)
+	assert.Equal(t, "text/plain; charset=utf-8", header.Get("Content-Type"))
+}
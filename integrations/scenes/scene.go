@@ -0,0 +1,365 @@
+// Package scenes switches groups of devices with one command, e.g. an
+// "evening" scene that turns the garden lights and porch on and the sauna off.
+package scenes
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mikahozz/gohome/integrations/shelly"
+	"github.com/rs/zerolog/log"
+	"gopkg.in/yaml.v3"
+)
+
+// ErrUnknownScene is returned when a scene name is not configured.
+var ErrUnknownScene = errors.New("unknown scene")
+
+// DefaultStepTimeout limits a step when neither the step nor its scene sets
+// a timeout.
+const DefaultStepTimeout = 15 * time.Second
+
+// Devices switches devices by name. *shelly.Registry implements it.
+type Devices interface {
+	Has(name string) bool
+	Output(ctx context.Context, name string) (bool, error)
+	Set(ctx context.Context, name string, on bool) error
+}
+
+// Step switches one device.
+type Step struct {
+	Device  string
+	On      bool
+	Timeout time.Duration
+}
+
+// Scene is a named group of steps. Steps run in order unless Parallel is set.
+// With Rollback, a failed activation returns the devices already switched to
+// the state they had before.
+type Scene struct {
+	Name     string
+	Parallel bool
+	Rollback bool
+	Steps    []Step
+}
+
+// StepResult reports the outcome of a single step.
+type StepResult struct {
+	Device     string `json:"device"`
+	On         bool   `json:"on"`
+	Error      string `json:"error,omitempty"`
+	Skipped    bool   `json:"skipped,omitempty"`     // not run after an earlier step failed
+	RolledBack bool   `json:"rolled_back,omitempty"` // returned to its previous state
+	DurationMs int64  `json:"duration_ms"`
+}
+
+// Result reports the outcome of a scene activation.
+type Result struct {
+	Scene      string       `json:"scene"`
+	OK         bool         `json:"ok"`
+	RolledBack bool         `json:"rolled_back,omitempty"`
+	Steps      []StepResult `json:"steps"`
+}
+
+// StepConfig is the file format of a step. State is "on" or "off".
+type StepConfig struct {
+	Device  string `yaml:"device" json:"device"`
+	State   string `yaml:"state" json:"state"`
+	Timeout string `yaml:"timeout,omitempty" json:"timeout,omitempty"`
+}
+
+// SceneConfig is the file format of a scene. Timeout is the default of its
+// steps.
+type SceneConfig struct {
+	Name     string       `yaml:"name" json:"name"`
+	Parallel bool         `yaml:"parallel,omitempty" json:"parallel,omitempty"`
+	Rollback bool         `yaml:"rollback,omitempty" json:"rollback,omitempty"`
+	Timeout  string       `yaml:"timeout,omitempty" json:"timeout,omitempty"`
+	Steps    []StepConfig `yaml:"steps" json:"steps"`
+}
+
+// SceneFile is the on-disk format of the scene list.
+type SceneFile struct {
+	Scenes []SceneConfig `yaml:"scenes" json:"scenes"`
+}
+
+// Registry holds the configured scenes and the devices they switch.
+type Registry struct {
+	scenes  map[string]Scene
+	devices Devices
+}
+
+// NewRegistry validates the scenes against the devices.
+func NewRegistry(configs []SceneConfig, devices Devices) (*Registry, error) {
+	r := &Registry{scenes: make(map[string]Scene, len(configs)), devices: devices}
+	names := make(map[string]bool, len(configs))
+	var errs []error
+	for i, c := range configs {
+		scene, err := r.parse(c)
+		if err == nil && names[c.Name] {
+			err = errors.New("duplicate scene name")
+		}
+		names[c.Name] = true
+		if err != nil {
+			errs = append(errs, fmt.Errorf("scene #%d (%q): %w", i+1, c.Name, err))
+			continue
+		}
+		r.scenes[scene.Name] = scene
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return r, nil
+}
+
+func (r *Registry) parse(c SceneConfig) (Scene, error) {
+	if strings.TrimSpace(c.Name) == "" {
+		return Scene{}, errors.New("name is required")
+	}
+	if len(c.Steps) == 0 {
+		return Scene{}, errors.New("at least one step is required")
+	}
+	timeout, err := parseTimeout(c.Timeout, DefaultStepTimeout)
+	if err != nil {
+		return Scene{}, err
+	}
+	scene := Scene{Name: c.Name, Parallel: c.Parallel, Rollback: c.Rollback}
+	seen := make(map[string]bool, len(c.Steps))
+	var errs []error
+	for i, sc := range c.Steps {
+		step, err := r.parseStep(sc, timeout)
+		if err == nil && seen[step.Device] {
+			err = errors.New("device switched twice")
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("step #%d (%q): %w", i+1, sc.Device, err))
+			continue
+		}
+		seen[step.Device] = true
+		scene.Steps = append(scene.Steps, step)
+	}
+	return scene, errors.Join(errs...)
+}
+
+func (r *Registry) parseStep(c StepConfig, def time.Duration) (Step, error) {
+	if c.Device == "" {
+		return Step{}, errors.New("device is required")
+	}
+	if !r.devices.Has(c.Device) {
+		return Step{}, fmt.Errorf("%w %q", shelly.ErrUnknownDevice, c.Device)
+	}
+	step := Step{Device: c.Device}
+	switch strings.ToLower(c.State) {
+	case "on":
+		step.On = true
+	case "off":
+	default:
+		return Step{}, fmt.Errorf("invalid state %q, use on or off", c.State)
+	}
+	var err error
+	step.Timeout, err = parseTimeout(c.Timeout, def)
+	return step, err
+}
+
+func parseTimeout(s string, def time.Duration) (time.Duration, error) {
+	if s == "" {
+		return def, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid timeout %q", s)
+	}
+	return d, nil
+}
+
+// Load reads a YAML or JSON scene file. The devices are checked to exist.
+func Load(path string, devices Devices) (*Registry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading scene file: %w", err)
+	}
+	var file SceneFile
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		err = json.Unmarshal(data, &file)
+	} else {
+		err = yaml.Unmarshal(data, &file)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parsing scene file %s: %w", path, err)
+	}
+	r, err := NewRegistry(file.Scenes, devices)
+	if err != nil {
+		return nil, fmt.Errorf("invalid scene file %s: %w", path, err)
+	}
+	return r, nil
+}
+
+// Scene returns the named scene.
+func (r *Registry) Scene(name string) (Scene, error) {
+	scene, ok := r.scenes[name]
+	if !ok {
+		return Scene{}, fmt.Errorf("%w %q", ErrUnknownScene, name)
+	}
+	return scene, nil
+}
+
+// Scenes returns the configured scenes sorted by name.
+func (r *Registry) Scenes() []Scene {
+	scenes := make([]Scene, 0, len(r.scenes))
+	for _, s := range r.scenes {
+		scenes = append(scenes, s)
+	}
+	sort.Slice(scenes, func(i, j int) bool { return scenes[i].Name < scenes[j].Name })
+	return scenes
+}
+
+// Has reports whether a scene with the name is configured.
+func (r *Registry) Has(name string) bool {
+	_, ok := r.scenes[name]
+	return ok
+}
+
+// Activate switches the devices of the named scene. The error is set when any
+// step failed; the result tells which ones and whether they were rolled back.
+func (r *Registry) Activate(ctx context.Context, name string) (Result, error) {
+	scene, err := r.Scene(name)
+	if err != nil {
+		return Result{Scene: name}, err
+	}
+	log.Info().Str("event", "scene_activate").Str("scene", name).Bool("parallel", scene.Parallel).Msg("activating scene")
+	res := Result{Scene: name, Steps: make([]StepResult, len(scene.Steps))}
+	// previous holds each device's state before its step, for rollback
+	previous := make([]*bool, len(scene.Steps))
+	stepErrs := make([]error, len(scene.Steps))
+	if scene.Parallel {
+		var wg sync.WaitGroup
+		for i := range scene.Steps {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				res.Steps[i], previous[i], stepErrs[i] = r.runStep(ctx, scene, scene.Steps[i])
+			}(i)
+		}
+		wg.Wait()
+	} else {
+		failed := false
+		for i, step := range scene.Steps {
+			if failed {
+				res.Steps[i] = StepResult{Device: step.Device, On: step.On, Skipped: true}
+				continue
+			}
+			res.Steps[i], previous[i], stepErrs[i] = r.runStep(ctx, scene, step)
+			failed = stepErrs[i] != nil
+		}
+	}
+
+	var errs []error
+	for i, err := range stepErrs {
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", scene.Steps[i].Device, err))
+		}
+	}
+	if len(errs) == 0 {
+		res.OK = true
+		log.Info().Str("event", "scene_activated").Str("scene", name).Msg("scene activated")
+		return res, nil
+	}
+	err = fmt.Errorf("scene %q: %w", name, errors.Join(errs...))
+	log.Error().Err(err).Str("event", "scene_activate_error").Str("scene", name).Msg("scene activation failed")
+	if scene.Rollback {
+		// Restore the devices even when the caller's context ended, as that
+		// is a common reason for the failure; each step keeps its timeout
+		r.rollback(context.WithoutCancel(ctx), scene, &res, previous)
+	}
+	return res, err
+}
+
+// runStep switches one device within the step's timeout. With rollback the
+// previous state is read first; a step whose state cannot be read is not run,
+// as it could not be restored.
+func (r *Registry) runStep(ctx context.Context, scene Scene, step Step) (StepResult, *bool, error) {
+	start := time.Now()
+	res := StepResult{Device: step.Device, On: step.On}
+	ctx, cancel := context.WithTimeout(ctx, step.Timeout)
+	defer cancel()
+	var previous *bool
+	if scene.Rollback {
+		on, err := r.devices.Output(ctx, step.Device)
+		if err != nil {
+			err = fmt.Errorf("reading state for rollback: %w", err)
+			res.Error = err.Error()
+			res.DurationMs = time.Since(start).Milliseconds()
+			return res, nil, err
+		}
+		previous = &on
+	}
+	err := r.devices.Set(ctx, step.Device, step.On)
+	if err != nil {
+		res.Error = err.Error()
+		previous = nil // not switched, nothing to restore
+		log.Error().Err(err).Str("event", "scene_step_error").Str("scene", scene.Name).Str("device", step.Device).Msg("scene step failed")
+	}
+	res.DurationMs = time.Since(start).Milliseconds()
+	return res, previous, err
+}
+
+// rollback returns the devices of successful steps to their previous state,
+// in reverse order. Devices already in the scene's state are left alone.
+func (r *Registry) rollback(ctx context.Context, scene Scene, res *Result, previous []*bool) {
+	res.RolledBack = true
+	for i := len(scene.Steps) - 1; i >= 0; i-- {
+		step := scene.Steps[i]
+		if previous[i] == nil {
+			continue
+		}
+		if *previous[i] == step.On {
+			res.Steps[i].RolledBack = true // nothing changed
+			continue
+		}
+		stepCtx, cancel := context.WithTimeout(ctx, step.Timeout)
+		err := r.devices.Set(stepCtx, step.Device, *previous[i])
+		cancel()
+		if err != nil {
+			res.RolledBack = false
+			log.Error().Err(err).Str("event", "scene_rollback_error").Str("scene", scene.Name).Str("device", step.Device).Msg("failed to restore device state")
+			continue
+		}
+		res.Steps[i].RolledBack = true
+		log.Info().Str("event", "scene_rollback").Str("scene", scene.Name).Str("device", step.Device).Bool("on", *previous[i]).Msg("device state restored")
+	}
+}
+
+var (
+	defaultOnce     sync.Once
+	defaultRegistry *Registry
+	defaultErr      error
+)
+
+// Default returns the scenes loaded from SCENES (default "scenes.yaml") for
+// the default Shelly device registry.
+func Default() (*Registry, error) {
+	defaultOnce.Do(func() {
+		path := os.Getenv("SCENES")
+		if path == "" {
+			path = "scenes.yaml"
+		}
+		if _, err := os.Stat(path); err != nil {
+			defaultErr = fmt.Errorf("no scenes configured: %s not found", path)
+			return
+		}
+		devices, err := shelly.Default()
+		if err != nil {
+			defaultErr = err
+			return
+		}
+		defaultRegistry, defaultErr = Load(path, devices)
+	})
+	return defaultRegistry, defaultErr
+}
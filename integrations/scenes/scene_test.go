@@ -0,0 +1,202 @@
+package scenes
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mikahozz/gohome/integrations/shelly"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeDevices records switches and fails or blocks the devices listed.
+type fakeDevices struct {
+	mu      sync.Mutex
+	outputs map[string]bool
+	fail    map[string]bool
+	hang    map[string]bool
+	calls   []string
+}
+
+func newFakeDevices(outputs map[string]bool) *fakeDevices {
+	return &fakeDevices{outputs: outputs, fail: map[string]bool{}, hang: map[string]bool{}}
+}
+
+func (f *fakeDevices) Has(name string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	_, ok := f.outputs[name]
+	return ok
+}
+
+func (f *fakeDevices) Output(ctx context.Context, name string) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.outputs[name], nil
+}
+
+func (f *fakeDevices) Set(ctx context.Context, name string, on bool) error {
+	f.mu.Lock()
+	hang, fail := f.hang[name], f.fail[name]
+	f.mu.Unlock()
+	if hang {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	if fail {
+		return errors.New("device offline")
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.outputs[name] = on
+	state := "off"
+	if on {
+		state = "on"
+	}
+	f.calls = append(f.calls, name+" "+state)
+	return nil
+}
+
+var evening = SceneConfig{
+	Name: "evening",
+	Steps: []StepConfig{
+		{Device: "garden-lights", State: "on"},
+		{Device: "porch", State: "on"},
+		{Device: "sauna", State: "off"},
+	},
+}
+
+func TestActivate_Sequential(t *testing.T) {
+	devices := newFakeDevices(map[string]bool{"garden-lights": false, "porch": false, "sauna": true})
+	r, err := NewRegistry([]SceneConfig{evening}, devices)
+	require.NoError(t, err)
+
+	res, err := r.Activate(context.Background(), "evening")
+	require.NoError(t, err)
+	assert.True(t, res.OK)
+	assert.Equal(t, []string{"garden-lights on", "porch on", "sauna off"}, devices.calls)
+
+	_, err = r.Activate(context.Background(), "morning")
+	assert.ErrorIs(t, err, ErrUnknownScene)
+}
+
+func TestActivate_Rollback(t *testing.T) {
+	devices := newFakeDevices(map[string]bool{"garden-lights": false, "porch": true, "sauna": true})
+	devices.fail["sauna"] = true
+	scene := evening
+	scene.Rollback = true
+	r, err := NewRegistry([]SceneConfig{scene}, devices)
+	require.NoError(t, err)
+
+	res, err := r.Activate(context.Background(), "evening")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "sauna: device offline")
+	assert.False(t, res.OK)
+	assert.True(t, res.RolledBack)
+	assert.Equal(t, []string{"garden-lights on", "porch on", "garden-lights off"}, devices.calls, "porch was already on")
+	assert.Equal(t, map[string]bool{"garden-lights": false, "porch": true, "sauna": true}, devices.outputs)
+}
+
+func TestActivate_RollbackAfterCallerTimeout(t *testing.T) {
+	devices := newFakeDevices(map[string]bool{"garden-lights": false, "porch": true, "sauna": true})
+	devices.hang["sauna"] = true
+	scene := evening
+	scene.Rollback = true
+	r, err := NewRegistry([]SceneConfig{scene}, devices)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	res, err := r.Activate(ctx, "evening")
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.True(t, res.RolledBack, "rollback runs after the caller's deadline")
+	assert.Equal(t, false, devices.outputs["garden-lights"])
+}
+
+func TestActivate_SequentialStopsAtFailure(t *testing.T) {
+	devices := newFakeDevices(map[string]bool{"garden-lights": false, "porch": false, "sauna": true})
+	devices.fail["garden-lights"] = true
+	r, err := NewRegistry([]SceneConfig{evening}, devices)
+	require.NoError(t, err)
+
+	res, err := r.Activate(context.Background(), "evening")
+	require.Error(t, err)
+	assert.Empty(t, devices.calls)
+	assert.True(t, res.Steps[1].Skipped)
+	assert.True(t, res.Steps[2].Skipped)
+	assert.False(t, res.RolledBack)
+}
+
+func TestActivate_ParallelStepTimeout(t *testing.T) {
+	devices := newFakeDevices(map[string]bool{"garden-lights": false, "porch": false, "sauna": true})
+	devices.hang["porch"] = true
+	scene := evening
+	scene.Parallel = true
+	scene.Steps = append([]StepConfig(nil), evening.Steps...)
+	scene.Steps[1].Timeout = "50ms"
+	r, err := NewRegistry([]SceneConfig{scene}, devices)
+	require.NoError(t, err)
+
+	start := time.Now()
+	res, err := r.Activate(context.Background(), "evening")
+	assert.Less(t, time.Since(start), time.Second)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.ElementsMatch(t, []string{"garden-lights on", "sauna off"}, devices.calls, "the other steps still run")
+	assert.Equal(t, context.DeadlineExceeded.Error(), res.Steps[1].Error)
+}
+
+func TestNewRegistry_Validation(t *testing.T) {
+	devices := newFakeDevices(map[string]bool{"porch": false})
+	_, err := NewRegistry([]SceneConfig{
+		{Name: "empty"},
+		{Name: "a", Steps: []StepConfig{{Device: "attic", State: "on"}}},
+		{Name: "a", Steps: []StepConfig{{Device: "porch", State: "on"}}},
+		{Name: "b", Steps: []StepConfig{{Device: "porch", State: "dim"}}},
+		{Name: "c", Timeout: "-1s", Steps: []StepConfig{{Device: "porch", State: "on"}}},
+		{Name: "d", Steps: []StepConfig{{Device: "porch", State: "on"}, {Device: "porch", State: "off"}}},
+	}, devices)
+	require.Error(t, err)
+	assert.ErrorIs(t, err, shelly.ErrUnknownDevice)
+	for _, want := range []string{"at least one step", "duplicate scene name", `invalid state "dim"`, `invalid timeout "-1s"`, "device switched twice"} {
+		assert.Contains(t, err.Error(), want)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "scenes.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+scenes:
+  - name: evening
+    rollback: true
+    timeout: 5s
+    steps:
+      - device: porch
+        state: on
+        timeout: 2s
+      - device: sauna
+        state: off
+`), 0o644))
+	r, err := Load(path, newFakeDevices(map[string]bool{"porch": false, "sauna": true}))
+	require.NoError(t, err)
+	scene, err := r.Scene("evening")
+	require.NoError(t, err)
+	assert.True(t, scene.Rollback)
+	assert.Equal(t, []Step{{Device: "porch", On: true, Timeout: 2 * time.Second}, {Device: "sauna", Timeout: 5 * time.Second}}, scene.Steps)
+	assert.Len(t, r.Scenes(), 1)
+}
+
+func TestLoad_Example(t *testing.T) {
+	devices, err := shelly.LoadRegistry("../../shelly_devices.example.yaml")
+	require.NoError(t, err)
+	r, err := Load("../../scenes.example.yaml", devices)
+	require.NoError(t, err, "the example scenes refer to the example devices")
+	assert.Len(t, r.Scenes(), 2)
+}
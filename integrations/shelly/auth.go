@@ -0,0 +1,206 @@
+package shelly
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// authTransport authenticates requests to a Shelly device. Gen2 devices use
+// HTTP digest auth (RFC 7616), Gen1 devices basic auth; the scheme is taken
+// from the challenge of the first 401 response, which is remembered so
+// following requests authenticate directly.
+type authTransport struct {
+	username string
+	password string
+	base     http.RoundTripper
+
+	mu        sync.Mutex
+	basic     bool
+	challenge map[string]string
+	nc        int
+}
+
+func newAuthTransport(username, password string, base http.RoundTripper) *authTransport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &authTransport{username: username, password: password, base: base}
+}
+
+func (t *authTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// Shelly requests carry no body, but buffer one so the retry can resend it.
+	var body []byte
+	if req.Body != nil {
+		var err error
+		if body, err = io.ReadAll(req.Body); err != nil {
+			return nil, err
+		}
+		req.Body.Close()
+	}
+
+	if auth, ok := t.authorization(req); ok {
+		resp, err := t.base.RoundTrip(withAuth(req, body, auth))
+		if err != nil || resp.StatusCode != http.StatusUnauthorized {
+			return resp, err
+		}
+		// Stale nonce, fall through to a fresh challenge
+		t.setChallenge(resp)
+		resp.Body.Close()
+	} else {
+		resp, err := t.base.RoundTrip(withAuth(req, body, ""))
+		if err != nil || resp.StatusCode != http.StatusUnauthorized {
+			return resp, err
+		}
+		if !t.setChallenge(resp) {
+			return resp, nil
+		}
+		resp.Body.Close()
+	}
+
+	auth, ok := t.authorization(req)
+	if !ok {
+		return nil, fmt.Errorf("unsupported authentication challenge")
+	}
+	return t.base.RoundTrip(withAuth(req, body, auth))
+}
+
+func withAuth(req *http.Request, body []byte, auth string) *http.Request {
+	r := req.Clone(req.Context())
+	if body != nil {
+		r.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	if auth != "" {
+		r.Header.Set("Authorization", auth)
+	}
+	return r
+}
+
+// setChallenge stores the basic or digest challenge of a 401 response.
+func (t *authTransport) setChallenge(resp *http.Response) bool {
+	header := resp.Header.Get("WWW-Authenticate")
+	scheme := strings.ToLower(header)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	switch {
+	case strings.HasPrefix(scheme, "basic"):
+		t.basic = true
+		t.challenge = map[string]string{}
+	case strings.HasPrefix(scheme, "digest "):
+		t.basic = false
+		t.challenge = parseDigestChallenge(header[len("digest "):])
+	default:
+		return false
+	}
+	t.nc = 0
+	return true
+}
+
+// authorization builds the Authorization header from the stored challenge.
+func (t *authTransport) authorization(req *http.Request) (string, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.challenge == nil {
+		return "", false
+	}
+	if t.basic {
+		return "Basic " + base64.StdEncoding.EncodeToString([]byte(t.username+":"+t.password)), true
+	}
+	c := t.challenge
+	var newHash func() hash.Hash
+	switch strings.ToUpper(c["algorithm"]) {
+	case "", "MD5":
+		newHash = md5.New
+	case "SHA-256":
+		newHash = sha256.New
+	default:
+		return "", false
+	}
+	h := func(s string) string {
+		sum := newHash()
+		sum.Write([]byte(s))
+		return hex.EncodeToString(sum.Sum(nil))
+	}
+
+	t.nc++
+	nc := fmt.Sprintf("%08x", t.nc)
+	cnonce := randomHex(8)
+	uri := req.URL.RequestURI()
+	ha1 := h(t.username + ":" + c["realm"] + ":" + t.password)
+	ha2 := h(req.Method + ":" + uri)
+
+	var response string
+	qop := ""
+	if c["qop"] != "" {
+		qop = "auth"
+		response = h(strings.Join([]string{ha1, c["nonce"], nc, cnonce, qop, ha2}, ":"))
+	} else {
+		response = h(ha1 + ":" + c["nonce"] + ":" + ha2)
+	}
+
+	parts := []string{
+		fmt.Sprintf(`username="%s"`, t.username),
+		fmt.Sprintf(`realm="%s"`, c["realm"]),
+		fmt.Sprintf(`nonce="%s"`, c["nonce"]),
+		fmt.Sprintf(`uri="%s"`, uri),
+		fmt.Sprintf(`response="%s"`, response),
+	}
+	if c["algorithm"] != "" {
+		parts = append(parts, "algorithm="+c["algorithm"])
+	}
+	if qop != "" {
+		parts = append(parts, "qop="+qop, "nc="+nc, fmt.Sprintf(`cnonce="%s"`, cnonce))
+	}
+	if c["opaque"] != "" {
+		parts = append(parts, fmt.Sprintf(`opaque="%s"`, c["opaque"]))
+	}
+	return "Digest " + strings.Join(parts, ", "), true
+}
+
+// parseDigestChallenge parses the comma separated key=value pairs of a
+// WWW-Authenticate digest challenge.
+func parseDigestChallenge(s string) map[string]string {
+	result := make(map[string]string)
+	for len(s) > 0 {
+		s = strings.TrimLeft(s, " ,")
+		eq := strings.IndexByte(s, '=')
+		if eq < 0 {
+			break
+		}
+		key := strings.ToLower(strings.TrimSpace(s[:eq]))
+		s = s[eq+1:]
+		var value string
+		if strings.HasPrefix(s, `"`) {
+			end := strings.IndexByte(s[1:], '"')
+			if end < 0 {
+				value, s = s[1:], ""
+			} else {
+				value, s = s[1:end+1], s[end+2:]
+			}
+		} else {
+			end := strings.IndexByte(s, ',')
+			if end < 0 {
+				value, s = s, ""
+			} else {
+				value, s = s[:end], s[end:]
+			}
+		}
+		result[key] = strings.TrimSpace(value)
+	}
+	return result
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,203 @@
+package shelly
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeDevice holds the relay state of a fake Shelly. A state change becomes
+// visible after lag status reads, like a relay that takes a moment to switch.
+type fakeDevice struct {
+	mu      sync.Mutex
+	lag     int
+	outputs map[string]bool
+	pending map[string]int
+	paths   []string
+	// power meter readings
+	power    float64
+	energyWh float64
+}
+
+func (f *fakeDevice) set(id string, on bool) {
+	f.outputs[id] = on
+	f.pending[id] = f.lag
+}
+
+func (f *fakeDevice) output(id string) bool {
+	if f.pending[id] > 0 {
+		f.pending[id]--
+		return !f.outputs[id]
+	}
+	return f.outputs[id]
+}
+
+func (f *fakeDevice) record(r *http.Request) {
+	f.paths = append(f.paths, r.URL.Path)
+}
+
+// fakeGen2 emulates the RPC API of a multi-channel Gen2 device.
+type fakeGen2 struct{ fakeDevice }
+
+func newFakeGen2() *fakeGen2 {
+	return &fakeGen2{fakeDevice{outputs: map[string]bool{}, pending: map[string]int{}}}
+}
+
+func (f *fakeGen2) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.record(r)
+	id := r.URL.Query().Get("id")
+	switch r.URL.Path {
+	case "/shelly":
+		fmt.Fprint(w, `{"id":"shellyplus2pm-a8032ab12345","model":"SNSW-102P16EU","gen":2,"fw_id":"20231107-164738/1.0.8-g8c7bb8d","auth_en":false}`)
+	case "/rpc/Switch.Set":
+		wasOn := f.outputs[id]
+		f.set(id, r.URL.Query().Get("on") == "true")
+		fmt.Fprintf(w, `{"was_on":%t}`, wasOn)
+	case "/rpc/Switch.GetStatus":
+		fmt.Fprintf(w, `{"id":%s,"source":"http","output":%t,"apower":%g,"voltage":231.2,"current":0.043,"aenergy":{"total":%g,"by_minute":[150.1,148.9,149.5],"minute_ts":1731072000},"temperature":{"tC":41.5,"tF":106.7}}`,
+			id, f.output(id), f.power, f.energyWh)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// fakeGen1 emulates the HTTP API of a Gen1 device with relays relays.
+type fakeGen1 struct {
+	fakeDevice
+	relays  int
+	metered bool
+}
+
+func newFakeGen1(relays int) *fakeGen1 {
+	return &fakeGen1{fakeDevice: fakeDevice{outputs: map[string]bool{}, pending: map[string]int{}}, relays: relays}
+}
+
+func (f *fakeGen1) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.record(r)
+	switch {
+	case r.URL.Path == "/shelly":
+		fmt.Fprint(w, `{"type":"SHPLG-S","mac":"A4CF12F45678","auth":false,"fw":"20230913-114008/v1.14.0-gcb84623","num_outputs":1}`)
+	case r.URL.Path == "/status":
+		relays := make([]string, f.relays)
+		for i := range relays {
+			relays[i] = fmt.Sprintf(`{"ison":%t,"has_timer":false,"source":"http"}`, f.output(strconv.Itoa(i)))
+		}
+		meters := ""
+		if f.metered {
+			// Gen1 meters count watt-minutes
+			meters = fmt.Sprintf(`,"meters":[{"power":%g,"is_valid":true,"total":%g}]`, f.power, f.energyWh*60)
+		}
+		fmt.Fprintf(w, `{"wifi_sta":{"connected":true},"relays":[%s]%s,"temperature":38.2}`, strings.Join(relays, ","), meters)
+	case strings.HasPrefix(r.URL.Path, "/relay/"):
+		id := strings.TrimPrefix(r.URL.Path, "/relay/")
+		if n, err := strconv.Atoi(id); err != nil || n >= f.relays {
+			http.NotFound(w, r)
+			return
+		}
+		switch r.URL.Query().Get("turn") {
+		case "on":
+			f.set(id, true)
+		case "off":
+			f.set(id, false)
+		}
+		fmt.Fprintf(w, `{"ison":%t,"has_timer":false}`, f.outputs[id])
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func TestShellyClient_Generations(t *testing.T) {
+	gen1 := newFakeGen1(2)
+	gen2 := newFakeGen2()
+	for _, tc := range []struct {
+		name   string
+		device http.Handler
+		fake   *fakeDevice
+		gen    int
+		set    string
+	}{
+		{"gen1", gen1, &gen1.fakeDevice, 1, "/relay/1"},
+		{"gen2", gen2, &gen2.fakeDevice, 2, "/rpc/Switch.Set"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			srv := httptest.NewServer(tc.device)
+			defer srv.Close()
+			tc.fake.lag = 2
+			c := NewDeviceClient(Device{URL: srv.URL, SwitchID: 1}, srv.Client())
+			c.pollInt = time.Millisecond
+			ctx := context.Background()
+
+			gen, err := c.Generation(ctx)
+			require.NoError(t, err)
+			assert.Equal(t, tc.gen, gen)
+
+			st, err := c.GetStatus(ctx)
+			require.NoError(t, err)
+			assert.False(t, st.Output)
+			assert.Equal(t, 1, st.ID)
+
+			st, err = c.Set(ctx, true, true, time.Second)
+			require.NoError(t, err)
+			assert.True(t, st.Output)
+			assert.Equal(t, map[string]bool{"1": true}, tc.fake.outputs)
+
+			// The relay has not switched yet when verification is skipped
+			tc.fake.lag = 1000
+			st, err = c.Set(ctx, false, false, time.Second)
+			require.NoError(t, err)
+			assert.True(t, st.Output)
+
+			_, err = c.Set(ctx, false, true, 20*time.Millisecond)
+			assert.ErrorContains(t, err, "verification timeout")
+
+			assert.Contains(t, tc.fake.paths, tc.set)
+			assert.Equal(t, 1, strings.Count(strings.Join(tc.fake.paths, " "), "/shelly"), "generation is detected once")
+		})
+	}
+}
+
+func TestShellyClient_ConfiguredGeneration(t *testing.T) {
+	fake := newFakeGen1(1)
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	c := NewDeviceClient(Device{URL: srv.URL, Generation: 1}, srv.Client())
+	_, err := c.Set(context.Background(), true, true, time.Second)
+	require.NoError(t, err)
+	assert.NotContains(t, fake.paths, "/shelly")
+
+	missing := NewDeviceClient(Device{URL: srv.URL, SwitchID: 3}, srv.Client())
+	_, err = missing.GetStatus(context.Background())
+	assert.ErrorContains(t, err, "relay 3 not found")
+}
+
+func TestShellyClient_Gen1BasicAuth(t *testing.T) {
+	fake := newFakeGen1(1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, ok := r.BasicAuth(); r.URL.Path != "/shelly" && (!ok || user != "admin" || pass != "secret") {
+			w.Header().Set("WWW-Authenticate", `Basic realm="shelly1-A4CF12F45678"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fake.ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+
+	c := NewDeviceClient(Device{URL: srv.URL, Password: "secret"}, srv.Client())
+	st, err := c.Set(context.Background(), true, true, time.Second)
+	require.NoError(t, err)
+	assert.True(t, st.Output)
+}
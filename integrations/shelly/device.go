@@ -0,0 +1,228 @@
+package shelly
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"gopkg.in/yaml.v3"
+)
+
+// ErrUnknownDevice is returned when a device name is not in the registry.
+var ErrUnknownDevice = errors.New("unknown shelly device")
+
+// DefaultDeviceName is the name of the device configured with SHELLY_BASE_URL.
+const DefaultDeviceName = "default"
+
+// Device describes a single switch channel of a Shelly device. Multi-channel
+// relays (e.g. Plus 2PM, Pro 4PM) are configured as one Device per channel
+// sharing the same URL with different SwitchIDs.
+type Device struct {
+	Name     string `yaml:"name" json:"name"`
+	URL      string `yaml:"url" json:"url"`
+	SwitchID int    `yaml:"switch_id" json:"switch_id"`
+	// Generation is 1 for the original devices (HTTP API) and 2 for Plus/Pro
+	// devices (RPC API). 0 detects it from the device on first use.
+	Generation int    `yaml:"generation" json:"generation"`
+	Username   string `yaml:"username" json:"username,omitempty"`
+	Password   string `yaml:"password" json:"-"`
+}
+
+// DeviceFile is the on-disk format of the device registry.
+type DeviceFile struct {
+	Devices []Device `yaml:"devices" json:"devices"`
+}
+
+// Registry maps friendly device names to clients.
+type Registry struct {
+	devices map[string]Device
+	clients map[string]*ShellyClient
+	http    *http.Client
+}
+
+// NewRegistry validates devices and creates a client for each. httpClient
+// may be nil (defaults applied).
+func NewRegistry(devices []Device, httpClient *http.Client) (*Registry, error) {
+	r := &Registry{
+		devices: make(map[string]Device, len(devices)),
+		clients: make(map[string]*ShellyClient, len(devices)),
+		http:    httpClient,
+	}
+	var errs []error
+	for i, d := range devices {
+		if err := r.add(d); err != nil {
+			errs = append(errs, fmt.Errorf("device #%d (%q): %w", i+1, d.Name, err))
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return r, nil
+}
+
+func (r *Registry) add(d Device) error {
+	if strings.TrimSpace(d.Name) == "" {
+		return errors.New("name is required")
+	}
+	// Power readings are stored under PowerSensorID(name) in a varchar(50) column
+	if len(PowerSensorID(d.Name)) > 50 {
+		return fmt.Errorf("name longer than %d characters", 50-len(PowerSensorID("")))
+	}
+	if _, ok := r.devices[d.Name]; ok {
+		return errors.New("duplicate device name")
+	}
+	if d.URL == "" {
+		return errors.New("url is required")
+	}
+	if d.SwitchID < 0 {
+		return fmt.Errorf("invalid switch_id %d", d.SwitchID)
+	}
+	if d.Generation < 0 || d.Generation > 2 {
+		return fmt.Errorf("unsupported generation %d", d.Generation)
+	}
+	r.devices[d.Name] = d
+	r.clients[d.Name] = NewDeviceClient(d, r.http)
+	return nil
+}
+
+// LoadRegistry reads a YAML or JSON device file. Environment variables in the
+// file (e.g. password: ${SHELLY_GARDEN_PASSWORD}) are expanded.
+func LoadRegistry(path string) (*Registry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading shelly device file: %w", err)
+	}
+	data = []byte(os.ExpandEnv(string(data)))
+	var file DeviceFile
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		err = json.Unmarshal(data, &file)
+	} else {
+		err = yaml.Unmarshal(data, &file)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parsing shelly device file %s: %w", path, err)
+	}
+	r, err := NewRegistry(file.Devices, nil)
+	if err != nil {
+		return nil, fmt.Errorf("invalid shelly device file %s: %w", path, err)
+	}
+	return r, nil
+}
+
+// Client returns the client of the named device.
+func (r *Registry) Client(name string) (*ShellyClient, error) {
+	c, ok := r.clients[name]
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnknownDevice, name)
+	}
+	return c, nil
+}
+
+// Devices returns the registered devices sorted by name.
+func (r *Registry) Devices() []Device {
+	devices := make([]Device, 0, len(r.devices))
+	for _, d := range r.devices {
+		devices = append(devices, d)
+	}
+	sort.Slice(devices, func(i, j int) bool { return devices[i].Name < devices[j].Name })
+	return devices
+}
+
+// Has reports whether a device with the name is registered.
+func (r *Registry) Has(name string) bool {
+	_, ok := r.devices[name]
+	return ok
+}
+
+// Output reports whether the named device is switched on.
+func (r *Registry) Output(ctx context.Context, name string) (bool, error) {
+	c, err := r.Client(name)
+	if err != nil {
+		return false, err
+	}
+	status, err := c.GetStatus(ctx)
+	if err != nil {
+		return false, err
+	}
+	return status.Output, nil
+}
+
+// Set switches the named device on or off and waits until it reports the state.
+func (r *Registry) Set(ctx context.Context, name string, on bool) error {
+	c, err := r.Client(name)
+	if err != nil {
+		return err
+	}
+	state := "OFF"
+	if on {
+		state = "ON"
+	}
+	if _, err := c.Set(ctx, on, true, 10*time.Second); err != nil {
+		log.Error().Err(err).Str("event", "shelly_set_error").Str("device", name).Msg("failed to turn " + state + " Shelly device")
+		return err
+	}
+	log.Info().Str("event", "shelly_set_success").Str("device", name).Bool("on", on).Msg("Shelly device turned " + state)
+	return nil
+}
+
+var (
+	defaultOnce     sync.Once
+	defaultRegistry *Registry
+	defaultErr      error
+)
+
+// Default returns the registry loaded from SHELLY_DEVICES (default
+// "shelly_devices.yaml"). When that file does not exist and SHELLY_BASE_URL
+// is set, the registry holds a single device named "default".
+func Default() (*Registry, error) {
+	defaultOnce.Do(func() {
+		path := os.Getenv("SHELLY_DEVICES")
+		if path == "" {
+			path = "shelly_devices.yaml"
+		}
+		if _, err := os.Stat(path); err == nil {
+			defaultRegistry, defaultErr = LoadRegistry(path)
+			return
+		}
+		baseURL := os.Getenv("SHELLY_BASE_URL")
+		if baseURL == "" {
+			defaultErr = fmt.Errorf("no shelly devices configured: %s not found and SHELLY_BASE_URL not set", path)
+			return
+		}
+		defaultRegistry, defaultErr = NewRegistry([]Device{{Name: DefaultDeviceName, URL: baseURL}}, nil)
+	})
+	return defaultRegistry, defaultErr
+}
+
+// SetDefault replaces the registry used by On and Off.
+func SetDefault(r *Registry) {
+	defaultOnce.Do(func() {})
+	defaultRegistry, defaultErr = r, nil
+}
+
+// On turns the named device on using the default registry.
+func On(ctx context.Context, name string) error {
+	r, err := Default()
+	if err != nil {
+		return err
+	}
+	return r.Set(ctx, name, true)
+}
+
+// Off turns the named device off using the default registry.
+func Off(ctx context.Context, name string) error {
+	r, err := Default()
+	if err != nil {
+		return err
+	}
+	return r.Set(ctx, name, false)
+}
@@ -0,0 +1,130 @@
+package shelly
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistry_SwitchChannels(t *testing.T) {
+	fake := newFakeGen2()
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	r, err := NewRegistry([]Device{
+		{Name: "garden-lights", URL: srv.URL, SwitchID: 0},
+		{Name: "sauna", URL: srv.URL + "/", SwitchID: 1},
+	}, srv.Client())
+	require.NoError(t, err)
+
+	require.NoError(t, r.Set(context.Background(), "sauna", true))
+	assert.Equal(t, map[string]bool{"1": true}, fake.outputs)
+
+	c, err := r.Client("garden-lights")
+	require.NoError(t, err)
+	st, err := c.GetStatus(context.Background())
+	require.NoError(t, err)
+	assert.False(t, st.Output)
+	on, err := r.Output(context.Background(), "sauna")
+	require.NoError(t, err)
+	assert.True(t, on)
+
+	err = r.Set(context.Background(), "attic", true)
+	assert.ErrorIs(t, err, ErrUnknownDevice)
+	assert.True(t, r.Has("sauna"))
+	assert.False(t, r.Has("attic"))
+
+	devices := r.Devices()
+	require.Len(t, devices, 2)
+	assert.Equal(t, "garden-lights", devices[0].Name)
+}
+
+func TestRegistry_Validation(t *testing.T) {
+	_, err := NewRegistry([]Device{
+		{Name: "a", URL: "http://10.0.0.2"},
+		{Name: "a", URL: "http://10.0.0.3"},
+		{Name: "no-url"},
+		{Name: "negative", URL: "http://10.0.0.4", SwitchID: -1},
+		{Name: "gen3", URL: "http://10.0.0.5", Generation: 3},
+	}, nil)
+	require.Error(t, err)
+	for _, want := range []string{"duplicate device name", "url is required", "invalid switch_id", "unsupported generation 3"} {
+		assert.Contains(t, err.Error(), want)
+	}
+}
+
+func TestLoadRegistry_YAML(t *testing.T) {
+	t.Setenv("TEST_SHELLY_PASSWORD", "secret")
+	path := filepath.Join(t.TempDir(), "shelly_devices.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+devices:
+  - name: garden-lights
+    url: http://192.168.1.20
+  - name: sauna
+    url: http://192.168.1.21
+    switch_id: 1
+    password: ${TEST_SHELLY_PASSWORD}
+`), 0o644))
+
+	r, err := LoadRegistry(path)
+	require.NoError(t, err)
+	devices := r.Devices()
+	require.Len(t, devices, 2)
+	assert.Equal(t, 1, devices[1].SwitchID)
+	assert.Equal(t, "secret", devices[1].Password)
+
+	_, err = LoadRegistry(filepath.Join(t.TempDir(), "missing.yaml"))
+	assert.Error(t, err)
+}
+
+func TestDigestAuth(t *testing.T) {
+	const realm, nonce = "shellyplus2pm-a8032ab12345", "5f1c2a"
+	var challenges, authorized int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth := r.Header.Get("Authorization")
+		if !strings.HasPrefix(auth, "Digest ") {
+			challenges++
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Digest qop="auth", realm="%s", nonce="%s", algorithm=SHA-256`, realm, nonce))
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		p := parseDigestChallenge(auth[len("Digest "):])
+		h := func(s string) string {
+			sum := sha256.Sum256([]byte(s))
+			return hex.EncodeToString(sum[:])
+		}
+		ha1 := h("admin:" + realm + ":secret")
+		ha2 := h(r.Method + ":" + p["uri"])
+		want := h(strings.Join([]string{ha1, nonce, p["nc"], p["cnonce"], p["qop"], ha2}, ":"))
+		if p["username"] != "admin" || p["response"] != want {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		authorized++
+		fmt.Fprint(w, `{"id":0,"output":true}`)
+	}))
+	defer srv.Close()
+
+	c := NewDeviceClient(Device{URL: srv.URL, Generation: 2, Password: "secret"}, srv.Client())
+	for i := 0; i < 2; i++ {
+		st, err := c.GetStatus(context.Background())
+		require.NoError(t, err)
+		assert.True(t, st.Output)
+	}
+	assert.Equal(t, 1, challenges, "challenge is reused for following requests")
+	assert.Equal(t, 2, authorized)
+
+	wrong := NewDeviceClient(Device{URL: srv.URL, Generation: 2, Password: "wrong"}, srv.Client())
+	_, err := wrong.GetStatus(context.Background())
+	assert.ErrorContains(t, err, "401")
+}
@@ -1,20 +1,25 @@
-// Package shelly provides a Shelly Outdoor Plug integration for checking the status of the plug and switching it on or off.
-// RPC API reference (Shelly Gen2 devices):
+// Package shelly provides a Shelly plug / relay integration for checking the status of a switch and switching it on or off.
+// Devices are addressed by name through a Registry (see device.go).
 //
-//	Get status:  /rpc/Switch.GetStatus?id=<switchID>
+// Both device generations are supported. The generation is detected from the
+// /shelly endpoint (Gen2 devices report "gen":2 there) unless configured.
+//
+// Gen2 (Plus/Pro) RPC API:
+//
+//	Get status:  /rpc/Switch.GetStatus?id=<switchID>   -> {"id":0, "output":true, "apower":8.9, "aenergy":{"total":1234.5}, ...}
 //	Set state:   /rpc/Switch.Set?id=<switchID>&on={true|false}
 //
-// A typical status response contains fields like:
+// Gen1 HTTP API:
 //
-//	{"id":0, "output":true, ...}
+//	Get status:  /status                               -> {"relays":[{"ison":true, ...}], "meters":[{"power":8.9, "total":74070}], ...}
+//	Set state:   /relay/<switchID>?turn={on|off}
 //
-// Only the "output" field is used here.
+// Besides the output state the power metering fields are read on devices that
+// measure power. Gen1 meters report energy in watt-minutes; it is converted to
+// Wh so both generations report the same units.
 //
 // The client exposes GetStatus and Set methods. Set can optionally verify the state
 // by polling until the device reports the desired value or a timeout occurs.
-//
-// NOTE: This implementation assumes Shelly Gen2 RPC endpoints. Adjust endpoints or
-// JSON parsing if using a different firmware/device generation.
 package shelly
 
 import (
@@ -32,18 +37,46 @@ import (
 	"github.com/rs/zerolog/log"
 )
 
-// SwitchStatus represents the minimal status information we care about.
+// SwitchStatus is the state of a switch channel. The metering fields are nil
+// on devices without power metering.
 type SwitchStatus struct {
-	ID     int  `json:"id"`
-	Output bool `json:"output"`
+	ID          int          `json:"id"`
+	Output      bool         `json:"output"`
+	APower      *float64     `json:"apower,omitempty"`  // active power, W
+	Voltage     *float64     `json:"voltage,omitempty"` // V
+	Current     *float64     `json:"current,omitempty"` // A
+	Temperature *Temperature `json:"temperature,omitempty"`
+	AEnergy     *Energy      `json:"aenergy,omitempty"`
+}
+
+// Temperature of the device.
+type Temperature struct {
+	C *float64 `json:"tC"`
+	F *float64 `json:"tF"`
+}
+
+// Energy is the active energy counter of a switch channel.
+type Energy struct {
+	Total    float64   `json:"total"`               // Wh since the counter was last reset
+	ByMinute []float64 `json:"by_minute,omitempty"` // mWh in each of the last three full minutes
+	MinuteTs int64     `json:"minute_ts,omitempty"` // unix time of the current minute
 }
 
-// ShellyClient encapsulates interaction with a Shelly outdoor plug / switch.
+// Metered reports whether the status includes power readings.
+func (s SwitchStatus) Metered() bool {
+	return s.APower != nil
+}
+
+// ShellyClient encapsulates interaction with one switch channel of a Shelly device.
 type ShellyClient struct {
-	baseURL string
-	http    *http.Client
-	pollInt time.Duration
-	mu      sync.Mutex // serialize Set operations to avoid overlapping state changes
+	baseURL  string
+	switchID int
+	http     *http.Client
+	pollInt  time.Duration
+	mu       sync.Mutex // serialize Set operations to avoid overlapping state changes
+
+	genMu      sync.Mutex
+	generation int // 0 until detected
 }
 
 func TurnOff(ctx context.Context) error {
@@ -89,12 +122,28 @@ func GetClient() *ShellyClient {
 	return NewShellyClient(baseURL, nil)
 }
 
-// NewShellyClient constructs a new client. httpClient may be nil (defaults applied).
+// NewShellyClient constructs a new client for switch 0. httpClient may be nil (defaults applied).
 func NewShellyClient(baseURL string, httpClient *http.Client) *ShellyClient {
+	return NewDeviceClient(Device{URL: baseURL}, httpClient)
+}
+
+// NewDeviceClient constructs a client for the device's switch channel. When
+// the device has credentials requests authenticate (digest auth on Gen2, basic auth on Gen1). httpClient may be nil
+// (defaults applied).
+func NewDeviceClient(d Device, httpClient *http.Client) *ShellyClient {
 	if httpClient == nil {
 		httpClient = &http.Client{Timeout: 5 * time.Second}
 	}
-	return &ShellyClient{baseURL: trimTrailingSlash(baseURL), http: httpClient, pollInt: 200 * time.Millisecond}
+	if d.Password != "" {
+		username := d.Username
+		if username == "" {
+			username = "admin" // Gen2 devices only accept the admin user, also the Gen1 default
+		}
+		authed := *httpClient
+		authed.Transport = newAuthTransport(username, d.Password, httpClient.Transport)
+		httpClient = &authed
+	}
+	return &ShellyClient{baseURL: trimTrailingSlash(d.URL), switchID: d.SwitchID, http: httpClient, pollInt: 200 * time.Millisecond, generation: d.Generation}
 }
 
 func trimTrailingSlash(s string) string {
@@ -104,27 +153,105 @@ func trimTrailingSlash(s string) string {
 	return s
 }
 
+// Generation returns the device generation (1 or 2), detecting it from the
+// /shelly endpoint on first use.
+func (c *ShellyClient) Generation(ctx context.Context) (int, error) {
+	c.genMu.Lock()
+	defer c.genMu.Unlock()
+	if c.generation != 0 {
+		return c.generation, nil
+	}
+	var info struct {
+		Gen int `json:"gen"`
+	}
+	if err := c.getJSON(ctx, c.baseURL+"/shelly", &info); err != nil {
+		return 0, fmt.Errorf("detecting shelly generation: %w", err)
+	}
+	c.generation = 1 // Gen1 devices do not report a generation
+	if info.Gen >= 2 {
+		c.generation = 2
+	}
+	log.Info().Str("event", "shelly_generation").Str("url", c.baseURL).Int("generation", c.generation).Msg("Detected Shelly device generation")
+	return c.generation, nil
+}
+
 // GetStatus retrieves the current switch status.
 func (c *ShellyClient) GetStatus(ctx context.Context) (SwitchStatus, error) {
-	endpoint := fmt.Sprintf("%s/rpc/Switch.GetStatus?id=0", c.baseURL)
+	gen, err := c.Generation(ctx)
+	if err != nil {
+		return SwitchStatus{}, err
+	}
+	if gen == 1 {
+		endpoint := c.baseURL + "/status"
+		log.Info().Str("event", "shelly_get_status").Msg("Sending query to Shelly: " + endpoint)
+		var status gen1Status
+		if err := c.getJSON(ctx, endpoint, &status); err != nil {
+			return SwitchStatus{}, err
+		}
+		return status.switchStatus(c.switchID)
+	}
+	endpoint := fmt.Sprintf("%s/rpc/Switch.GetStatus?id=%d", c.baseURL, c.switchID)
 	log.Info().Str("event", "shelly_get_status").Msg("Sending query to Shelly: " + endpoint)
+	var status SwitchStatus
+	if err := c.getJSON(ctx, endpoint, &status); err != nil {
+		return SwitchStatus{}, err
+	}
+	return status, nil
+}
+
+// gen1Status is the part of the Gen1 /status response used here.
+type gen1Status struct {
+	Relays []struct {
+		IsOn bool `json:"ison"`
+	} `json:"relays"`
+	Meters []struct {
+		Power   float64 `json:"power"` // W
+		Total   float64 `json:"total"` // watt-minutes
+		IsValid bool    `json:"is_valid"`
+	} `json:"meters"`
+	Temperature *float64 `json:"temperature"`
+	Voltage     *float64 `json:"voltage"`
+}
+
+func (s gen1Status) switchStatus(id int) (SwitchStatus, error) {
+	if id >= len(s.Relays) {
+		return SwitchStatus{}, fmt.Errorf("relay %d not found, device has %d relays", id, len(s.Relays))
+	}
+	status := SwitchStatus{ID: id, Output: s.Relays[id].IsOn, Voltage: s.Voltage}
+	if s.Temperature != nil {
+		status.Temperature = &Temperature{C: s.Temperature}
+	}
+	// Only relays with their own meter report power; a single meter of a
+	// multi-relay device does not measure the other relays
+	if id < len(s.Meters) {
+		if m := s.Meters[id]; m.IsValid {
+			power := m.Power
+			status.APower = &power
+			status.AEnergy = &Energy{Total: m.Total / 60}
+		}
+	}
+	return status, nil
+}
+
+// getJSON sends a GET request and decodes the JSON response into v. A nil v
+// discards the body.
+func (c *ShellyClient) getJSON(ctx context.Context, endpoint string, v interface{}) error {
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
 	if err != nil {
-		return SwitchStatus{}, err
+		return err
 	}
 	resp, err := c.http.Do(req)
 	if err != nil {
-		return SwitchStatus{}, err
+		return err
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode != http.StatusOK {
-		return SwitchStatus{}, fmt.Errorf("unexpected status code %d", resp.StatusCode)
+		return fmt.Errorf("unexpected status code %d", resp.StatusCode)
 	}
-	var status SwitchStatus
-	if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
-		return SwitchStatus{}, err
+	if v == nil {
+		return nil
 	}
-	return status, nil
+	return json.NewDecoder(resp.Body).Decode(v)
 }
 
 // Set changes the switch output state. If verify is true, it polls until the
@@ -133,31 +260,38 @@ func (c *ShellyClient) Set(ctx context.Context, on bool, verify bool, timeout ti
 	// Ensure only one Set (including verification polling) runs at a time to avoid races
 	c.mu.Lock()
 	defer c.mu.Unlock()
-	endpoint := fmt.Sprintf("%s/rpc/Switch.Set?id=0", c.baseURL)
-	u, err := url.Parse(endpoint)
+	gen, err := c.Generation(ctx)
 	if err != nil {
 		return SwitchStatus{}, err
 	}
-	q := u.Query()
-	if on {
-		q.Set("on", "true")
+	var u *url.URL
+	if gen == 1 {
+		u, err = url.Parse(fmt.Sprintf("%s/relay/%d", c.baseURL, c.switchID))
+		if err != nil {
+			return SwitchStatus{}, err
+		}
+		turn := "off"
+		if on {
+			turn = "on"
+		}
+		u.RawQuery = url.Values{"turn": {turn}}.Encode()
 	} else {
-		q.Set("on", "false")
+		u, err = url.Parse(fmt.Sprintf("%s/rpc/Switch.Set?id=%d", c.baseURL, c.switchID))
+		if err != nil {
+			return SwitchStatus{}, err
+		}
+		q := u.Query()
+		if on {
+			q.Set("on", "true")
+		} else {
+			q.Set("on", "false")
+		}
+		u.RawQuery = q.Encode()
 	}
-	u.RawQuery = q.Encode()
 	log.Info().Str("event", "shelly_set").Msg("Sending query to Shelly: " + u.String())
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
-	if err != nil {
+	if err := c.getJSON(ctx, u.String(), nil); err != nil {
 		return SwitchStatus{}, err
 	}
-	resp, err := c.http.Do(req)
-	if err != nil {
-		return SwitchStatus{}, err
-	}
-	defer resp.Body.Close()
-	if resp.StatusCode != http.StatusOK {
-		return SwitchStatus{}, fmt.Errorf("unexpected status code %d", resp.StatusCode)
-	}
 	// We ignore the response body of Set since status will be fetched below.
 	if !verify {
 		return c.GetStatus(ctx)
@@ -0,0 +1,211 @@
+package shelly
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/mikahozz/gohome/db"
+	"github.com/rs/zerolog/log"
+)
+
+// PowerSensorID is the sensor_id power readings of a device are stored under
+// in the measurements table.
+func PowerSensorID(device string) string {
+	return "shelly_power_" + device
+}
+
+// PowerReading is a power meter sample of a switch channel.
+type PowerReading struct {
+	Time         time.Time `json:"time"`
+	PowerW       float64   `json:"power_w"`
+	VoltageV     *float64  `json:"voltage_v,omitempty"`
+	CurrentA     *float64  `json:"current_a,omitempty"`
+	TemperatureC *float64  `json:"temperature_c,omitempty"`
+	// EnergyWh is the device's energy counter. It only grows, except when the
+	// device restarts or the counter is reset.
+	EnergyWh float64 `json:"energy_wh"`
+}
+
+// NewPowerReading converts a metered status to a reading taken at t.
+func NewPowerReading(t time.Time, st SwitchStatus) (PowerReading, bool) {
+	if !st.Metered() {
+		return PowerReading{}, false
+	}
+	r := PowerReading{Time: t, PowerW: *st.APower, VoltageV: st.Voltage, CurrentA: st.Current}
+	if st.Temperature != nil {
+		r.TemperatureC = st.Temperature.C
+	}
+	if st.AEnergy != nil {
+		r.EnergyWh = st.AEnergy.Total
+	}
+	return r, true
+}
+
+// EnergyUse is the energy used during a slot.
+type EnergyUse struct {
+	Start time.Time `json:"start"`
+	Wh    float64   `json:"wh"`
+}
+
+// EnergyUseBy sums the growth of the energy counter into slots of the given
+// length (e.g. 15 minutes to match spot price intervals). Readings must be
+// sorted by time. The energy between two readings is counted in the slot of
+// the later one; when the counter went backwards (device restart) the new
+// counter value is counted instead. Slots without readings are left out.
+func EnergyUseBy(readings []PowerReading, slot time.Duration) []EnergyUse {
+	var use []EnergyUse
+	for i := 1; i < len(readings); i++ {
+		delta := readings[i].EnergyWh - readings[i-1].EnergyWh
+		if delta < 0 {
+			delta = readings[i].EnergyWh
+		}
+		// Readings exactly on a slot boundary close the previous slot
+		start := readings[i].Time.Add(-time.Nanosecond).Truncate(slot)
+		if len(use) == 0 || !use[len(use)-1].Start.Equal(start) {
+			use = append(use, EnergyUse{Start: start})
+		}
+		use[len(use)-1].Wh += delta
+	}
+	return use
+}
+
+// DailyEnergy is the energy used during one local day.
+type DailyEnergy struct {
+	Date string  `json:"date"` // YYYY-MM-DD
+	KWh  float64 `json:"kwh"`
+}
+
+// DailyEnergyUse sums the energy used per day in location, counted like
+// EnergyUseBy.
+func DailyEnergyUse(readings []PowerReading, location *time.Location) []DailyEnergy {
+	var days []DailyEnergy
+	for _, use := range EnergyUseBy(readings, time.Minute) {
+		date := use.Start.In(location).Format("2006-01-02")
+		if len(days) == 0 || days[len(days)-1].Date != date {
+			days = append(days, DailyEnergy{Date: date})
+		}
+		days[len(days)-1].KWh += use.Wh / 1000
+	}
+	return days
+}
+
+// PowerStore stores power readings. *PowerRepository implements it.
+type PowerStore interface {
+	StorePower(ctx context.Context, device string, readings []PowerReading) error
+}
+
+// PowerRepository keeps power readings in the measurements table.
+type PowerRepository struct {
+	db *sql.DB
+}
+
+func NewPowerRepository(db *sql.DB) *PowerRepository {
+	return &PowerRepository{db: db}
+}
+
+// StorePower stores readings of a device, replacing readings with the same timestamps.
+func (r *PowerRepository) StorePower(ctx context.Context, device string, readings []PowerReading) error {
+	measurements := make([]db.Measurement, len(readings))
+	for i, reading := range readings {
+		power := reading.PowerW
+		measurements[i] = db.Measurement{
+			Timestamp: reading.Time,
+			SensorID:  PowerSensorID(device),
+			MainValue: &power,
+			Value:     reading,
+		}
+	}
+	return db.UpsertMeasurements(ctx, r.db, measurements)
+}
+
+// Readings returns the readings of a device between start and end (both
+// inclusive), oldest first.
+func (r *PowerRepository) Readings(ctx context.Context, device string, start, end time.Time) ([]PowerReading, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT timestamp, value FROM measurements
+		WHERE sensor_id = $1 AND timestamp >= $2 AND timestamp <= $3
+		ORDER BY timestamp`, PowerSensorID(device), start.UTC(), end.UTC())
+	if err != nil {
+		return nil, fmt.Errorf("querying power readings: %w", err)
+	}
+	defer rows.Close()
+
+	var readings []PowerReading
+	for rows.Next() {
+		var ts time.Time
+		var value []byte
+		if err := rows.Scan(&ts, &value); err != nil {
+			return nil, fmt.Errorf("scanning power reading: %w", err)
+		}
+		var reading PowerReading
+		if err := json.Unmarshal(value, &reading); err != nil {
+			return nil, fmt.Errorf("decoding power reading at %s: %w", ts, err)
+		}
+		reading.Time = ts
+		readings = append(readings, reading)
+	}
+	return readings, rows.Err()
+}
+
+// PowerPoller periodically reads the power meters of all devices in a
+// registry and stores the readings. Devices without metering are skipped.
+type PowerPoller struct {
+	registry *Registry
+	store    PowerStore
+	now      func() time.Time
+}
+
+func NewPowerPoller(registry *Registry, store PowerStore) *PowerPoller {
+	return &PowerPoller{registry: registry, store: store, now: time.Now}
+}
+
+// Poll reads and stores one sample per metered device. A device that cannot
+// be read does not stop the others; all errors are returned together.
+func (p *PowerPoller) Poll(ctx context.Context) error {
+	now := p.now().Truncate(time.Second)
+	var errs []error
+	for _, d := range p.registry.Devices() {
+		c, err := p.registry.Client(d.Name)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		st, err := c.GetStatus(ctx)
+		if err != nil {
+			log.Error().Err(err).Str("event", "shelly_power_error").Str("device", d.Name).Msg("failed to read Shelly power meter")
+			errs = append(errs, fmt.Errorf("%s: %w", d.Name, err))
+			continue
+		}
+		reading, ok := NewPowerReading(now, st)
+		if !ok {
+			log.Debug().Str("event", "shelly_power_unmetered").Str("device", d.Name).Msg("Shelly device has no power meter")
+			continue
+		}
+		if err := p.store.StorePower(ctx, d.Name, []PowerReading{reading}); err != nil {
+			errs = append(errs, fmt.Errorf("storing %s power: %w", d.Name, err))
+			continue
+		}
+		log.Debug().Str("event", "shelly_power_reading").Str("device", d.Name).Float64("power_w", reading.PowerW).Float64("energy_wh", reading.EnergyWh).Msg("Stored Shelly power reading")
+	}
+	return errors.Join(errs...)
+}
+
+// Run polls every interval until ctx is cancelled.
+func (p *PowerPoller) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := p.Poll(ctx); err != nil {
+			log.Error().Err(err).Str("event", "shelly_power_poll_error").Msg("Shelly power poll failed")
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,51 @@
+//go:build integration
+
+package shelly
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mikahozz/gohome/config"
+	"github.com/mikahozz/gohome/db"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPowerRepository_StoreAndRead(t *testing.T) {
+	config.LoadEnv()
+	conn, err := db.Open()
+	if err != nil {
+		t.Skipf("database not available: %v", err)
+	}
+	defer conn.Close()
+
+	const device = "integration-test"
+	cleanup := func() {
+		conn.Exec(`DELETE FROM measurements WHERE sensor_id = $1`, PowerSensorID(device))
+	}
+	cleanup()
+	defer cleanup()
+
+	ctx := context.Background()
+	repo := NewPowerRepository(conn)
+	start := time.Date(2001, 1, 1, 0, 0, 0, 0, time.UTC)
+	voltage := 230.5
+	readings := []PowerReading{
+		{Time: start, PowerW: 10, EnergyWh: 100, VoltageV: &voltage},
+		{Time: start.Add(time.Minute), PowerW: 12, EnergyWh: 100.2},
+	}
+	require.NoError(t, repo.StorePower(ctx, device, readings))
+	// Storing the same timestamp again replaces the reading
+	readings[1].PowerW = 13
+	require.NoError(t, repo.StorePower(ctx, device, readings[1:]))
+
+	stored, err := repo.Readings(ctx, device, start, start.Add(time.Hour))
+	require.NoError(t, err)
+	require.Len(t, stored, 2)
+	assert.True(t, stored[0].Time.Equal(start))
+	require.NotNil(t, stored[0].VoltageV)
+	assert.Equal(t, 230.5, *stored[0].VoltageV)
+	assert.Equal(t, 13.0, stored[1].PowerW)
+}
@@ -0,0 +1,147 @@
+package shelly
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetStatus_PowerMetering(t *testing.T) {
+	gen1 := newFakeGen1(1)
+	gen1.metered, gen1.power, gen1.energyWh = true, 8.5, 1234.5
+	gen2 := newFakeGen2()
+	gen2.power, gen2.energyWh = 8.5, 1234.5
+
+	for name, device := range map[string]*httptest.Server{"gen1": httptest.NewServer(gen1), "gen2": httptest.NewServer(gen2)} {
+		t.Run(name, func(t *testing.T) {
+			defer device.Close()
+			st, err := NewDeviceClient(Device{URL: device.URL}, device.Client()).GetStatus(context.Background())
+			require.NoError(t, err)
+			require.True(t, st.Metered())
+			assert.Equal(t, 8.5, *st.APower)
+			require.NotNil(t, st.AEnergy)
+			assert.InDelta(t, 1234.5, st.AEnergy.Total, 1e-9, "energy is reported in Wh for both generations")
+			require.NotNil(t, st.Temperature)
+			assert.NotNil(t, st.Temperature.C)
+		})
+	}
+}
+
+func TestGetStatus_Gen1MeterPerRelay(t *testing.T) {
+	// Two relays and one meter, which belongs to relay 0
+	gen1 := newFakeGen1(2)
+	gen1.metered, gen1.power = true, 8.5
+	device := httptest.NewServer(gen1)
+	defer device.Close()
+
+	st, err := NewDeviceClient(Device{URL: device.URL, SwitchID: 0}, device.Client()).GetStatus(context.Background())
+	require.NoError(t, err)
+	assert.True(t, st.Metered())
+
+	st, err = NewDeviceClient(Device{URL: device.URL, SwitchID: 1}, device.Client()).GetStatus(context.Background())
+	require.NoError(t, err)
+	assert.False(t, st.Metered(), "relay 1 has no meter of its own")
+	assert.Nil(t, st.AEnergy)
+}
+
+func TestDailyEnergyUse(t *testing.T) {
+	at := func(day, hour int) time.Time { return time.Date(2025, 11, day, hour, 0, 0, 0, helsinki(t)) }
+	readings := []PowerReading{
+		{Time: at(8, 18), EnergyWh: 1000},
+		{Time: at(8, 23), EnergyWh: 1300},
+		{Time: at(9, 1), EnergyWh: 1500}, // 200 Wh counted on the 9th
+		{Time: at(9, 6), EnergyWh: 50},   // device restarted
+		{Time: at(9, 12), EnergyWh: 150},
+	}
+	days := DailyEnergyUse(readings, helsinki(t))
+	require.Len(t, days, 2)
+	assert.Equal(t, "2025-11-08", days[0].Date)
+	assert.InDelta(t, 0.3, days[0].KWh, 1e-9)
+	assert.Equal(t, "2025-11-09", days[1].Date)
+	assert.InDelta(t, 0.35, days[1].KWh, 1e-9)
+
+	assert.Empty(t, DailyEnergyUse(readings[:1], helsinki(t)))
+}
+
+type fakePowerStore struct {
+	readings map[string][]PowerReading
+	err      error
+}
+
+func (f *fakePowerStore) StorePower(ctx context.Context, device string, readings []PowerReading) error {
+	if f.err != nil {
+		return f.err
+	}
+	f.readings[device] = append(f.readings[device], readings...)
+	return nil
+}
+
+func TestPowerPoller_Poll(t *testing.T) {
+	metered := newFakeGen2()
+	metered.power, metered.energyWh = 12, 300
+	meteredSrv := httptest.NewServer(metered)
+	defer meteredSrv.Close()
+	// A Gen1 relay without meters, e.g. Shelly 1
+	plain := httptest.NewServer(newFakeGen1(1))
+	defer plain.Close()
+
+	registry, err := NewRegistry([]Device{
+		{Name: "night-lights", URL: meteredSrv.URL},
+		{Name: "porch", URL: plain.URL, Generation: 1},
+		{Name: "offline", URL: "http://127.0.0.1:59999", Generation: 2},
+	}, meteredSrv.Client())
+	require.NoError(t, err)
+
+	store := &fakePowerStore{readings: map[string][]PowerReading{}}
+	poller := NewPowerPoller(registry, store)
+	now := time.Date(2025, 11, 8, 18, 0, 0, 500, time.UTC)
+	poller.now = func() time.Time { return now }
+
+	err = poller.Poll(context.Background())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "offline")
+	assert.NotContains(t, store.readings, "porch")
+
+	require.Len(t, store.readings["night-lights"], 1)
+	r := store.readings["night-lights"][0]
+	assert.True(t, r.Time.Equal(now.Truncate(time.Second)))
+	assert.Equal(t, 12.0, r.PowerW)
+	assert.Equal(t, 300.0, r.EnergyWh)
+	require.NotNil(t, r.VoltageV)
+	assert.Equal(t, 231.2, *r.VoltageV)
+
+	store.err = errors.New("database down")
+	err = poller.Poll(context.Background())
+	assert.ErrorContains(t, err, "storing night-lights power: database down")
+}
+
+func helsinki(t *testing.T) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation("Europe/Helsinki")
+	require.NoError(t, err)
+	return loc
+}
+
+func TestEnergyUseBy(t *testing.T) {
+	at := func(hour, min int) time.Time { return time.Date(2025, 11, 8, hour, min, 0, 0, time.UTC) }
+	readings := []PowerReading{
+		{Time: at(18, 0), EnergyWh: 100},
+		{Time: at(18, 10), EnergyWh: 110},
+		{Time: at(18, 15), EnergyWh: 130}, // closes the 18:00 slot
+		{Time: at(18, 40), EnergyWh: 160},
+		{Time: at(19, 5), EnergyWh: 5}, // device restarted
+	}
+	use := EnergyUseBy(readings, 15*time.Minute)
+	require.Len(t, use, 3)
+	assert.True(t, use[0].Start.Equal(at(18, 0)))
+	assert.Equal(t, 30.0, use[0].Wh)
+	assert.True(t, use[1].Start.Equal(at(18, 30)))
+	assert.Equal(t, 30.0, use[1].Wh)
+	assert.True(t, use[2].Start.Equal(at(19, 0)))
+	assert.Equal(t, 5.0, use[2].Wh)
+}
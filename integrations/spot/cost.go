@@ -0,0 +1,184 @@
+package spot
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Tariff holds what is paid per kWh on top of the spot price. The fees are
+// given without VAT; VAT is added with the rate in force at the time of use.
+type Tariff struct {
+	TransferCkwh float64 `json:"transfer_ckwh"`
+	MarginCkwh   float64 `json:"margin_ckwh"`
+}
+
+// TariffFromEnv reads ELECTRICITY_TRANSFER_CKWH and ELECTRICITY_MARGIN_CKWH
+// (c/kWh without VAT). Unset values are zero.
+func TariffFromEnv() (Tariff, error) {
+	var t Tariff
+	for name, target := range map[string]*float64{
+		"ELECTRICITY_TRANSFER_CKWH": &t.TransferCkwh,
+		"ELECTRICITY_MARGIN_CKWH":   &t.MarginCkwh,
+	} {
+		s := os.Getenv(name)
+		if s == "" {
+			continue
+		}
+		v, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return Tariff{}, fmt.Errorf("invalid %s %q: %w", name, s, err)
+		}
+		*target = v
+	}
+	return t, nil
+}
+
+// Consumption is energy used during the slot starting at Start.
+type Consumption struct {
+	Start time.Time
+	Wh    float64
+}
+
+// Cost is the price of one consumption slot, VAT included. Energy is the
+// spot price part, Transfer and Margin the tariff fees.
+type Cost struct {
+	Start       time.Time
+	KWh         float64
+	SpotCkwh    float64
+	EnergyEur   float64
+	TransferEur float64
+	MarginEur   float64
+}
+
+// TotalEur is the full cost of the slot.
+func (c Cost) TotalEur() float64 {
+	return c.EnergyEur + c.TransferEur + c.MarginEur
+}
+
+// maxPriceInterval is how long a price applies when no later price follows.
+const maxPriceInterval = time.Hour
+
+// Costs prices each consumption slot with the spot price in force at its start
+// (prices from ConvertToSpotPriceList already include VAT) plus the tariff.
+// Consumption without a known price is returned separately as unpriced.
+func (t Tariff) Costs(consumption []Consumption, prices []SpotPrice) (costs []Cost, unpriced []Consumption) {
+	sorted := make([]SpotPrice, len(prices))
+	copy(sorted, prices)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].DateTime.Before(sorted[j].DateTime) })
+
+	for _, c := range consumption {
+		// Last price starting at or before the slot
+		i := sort.Search(len(sorted), func(i int) bool { return sorted[i].DateTime.After(c.Start) }) - 1
+		if i < 0 || !c.Start.Before(sorted[i].DateTime.Add(maxPriceInterval)) {
+			unpriced = append(unpriced, c)
+			continue
+		}
+		kwh := c.Wh / 1000
+		spot := sorted[i].PriceCkwh
+		costs = append(costs, Cost{
+			Start:       c.Start,
+			KWh:         kwh,
+			SpotCkwh:    spot,
+			EnergyEur:   kwh * spot / 100,
+			TransferEur: kwh * t.TransferCkwh * vatMultiplier(t.TransferCkwh, c.Start) / 100,
+			MarginEur:   kwh * t.MarginCkwh * vatMultiplier(t.MarginCkwh, c.Start) / 100,
+		})
+	}
+	return costs, unpriced
+}
+
+// CostPeriod is the grouping of a cost summary.
+type CostPeriod string
+
+const (
+	CostPeriodHour  CostPeriod = "hour"
+	CostPeriodDay   CostPeriod = "day"
+	CostPeriodMonth CostPeriod = "month"
+)
+
+// Validate reports whether p is a known period.
+func (p CostPeriod) Validate() error {
+	_, err := p.layout()
+	return err
+}
+
+func (p CostPeriod) layout() (string, error) {
+	switch p {
+	case CostPeriodHour:
+		return "2006-01-02T15", nil
+	case CostPeriodDay:
+		return "2006-01-02", nil
+	case CostPeriodMonth:
+		return "2006-01", nil
+	}
+	return "", fmt.Errorf("invalid cost period %q (want hour, day or month)", p)
+}
+
+// CostSummary is the cost of one period. Amounts are rounded to 1/100 cent.
+type CostSummary struct {
+	Period      string  `json:"period"`
+	KWh         float64 `json:"kwh"`
+	EnergyEur   float64 `json:"energy_eur"`
+	TransferEur float64 `json:"transfer_eur"`
+	MarginEur   float64 `json:"margin_eur"`
+	TotalEur    float64 `json:"total_eur"`
+	// AvgCkwh is the consumption weighted average price, fees included.
+	AvgCkwh float64 `json:"avg_ckwh"`
+	// UnpricedKWh is consumption with no known spot price, not in the totals.
+	UnpricedKWh float64 `json:"unpriced_kwh,omitempty"`
+}
+
+// SummarizeCosts groups costs and unpriced consumption into periods of
+// location's calendar, ordered by period.
+func SummarizeCosts(costs []Cost, unpriced []Consumption, period CostPeriod, location *time.Location) ([]CostSummary, error) {
+	layout, err := period.layout()
+	if err != nil {
+		return nil, err
+	}
+	byPeriod := make(map[string]*CostSummary)
+	get := func(t time.Time) *CostSummary {
+		key := t.In(location).Format(layout)
+		s, ok := byPeriod[key]
+		if !ok {
+			s = &CostSummary{Period: key}
+			byPeriod[key] = s
+		}
+		return s
+	}
+	for _, c := range costs {
+		s := get(c.Start)
+		s.KWh += c.KWh
+		s.EnergyEur += c.EnergyEur
+		s.TransferEur += c.TransferEur
+		s.MarginEur += c.MarginEur
+	}
+	for _, c := range unpriced {
+		get(c.Start).UnpricedKWh += c.Wh / 1000
+	}
+
+	summaries := make([]CostSummary, 0, len(byPeriod))
+	for _, s := range byPeriod {
+		s.TotalEur = s.EnergyEur + s.TransferEur + s.MarginEur
+		if s.KWh > 0 {
+			s.AvgCkwh = round(s.TotalEur*100/s.KWh, 3)
+		}
+		s.KWh = round(s.KWh, 4)
+		s.UnpricedKWh = round(s.UnpricedKWh, 4)
+		s.EnergyEur = round(s.EnergyEur, 4)
+		s.TransferEur = round(s.TransferEur, 4)
+		s.MarginEur = round(s.MarginEur, 4)
+		s.TotalEur = round(s.TotalEur, 4)
+		summaries = append(summaries, *s)
+	}
+	sort.Slice(summaries, func(i, j int) bool { return summaries[i].Period < summaries[j].Period })
+	return summaries, nil
+}
+
+func round(v float64, decimals int) float64 {
+	p := math.Pow(10, float64(decimals))
+	return math.Round(v*p) / p
+}
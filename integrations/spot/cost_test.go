@@ -0,0 +1,79 @@
+package spot
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTariffCosts(t *testing.T) {
+	helsinki, _ := time.LoadLocation("Europe/Helsinki")
+	at := func(day, hour, min int) time.Time { return time.Date(2025, 11, day, hour, min, 0, 0, helsinki) }
+	prices := []SpotPrice{
+		{DateTime: at(8, 19, 0), PriceCkwh: 10},
+		{DateTime: at(8, 18, 0), PriceCkwh: 20},
+		{DateTime: at(8, 20, 0), PriceCkwh: -1},
+	}
+	tariff := Tariff{TransferCkwh: 4, MarginCkwh: 0.4}
+	costs, unpriced := tariff.Costs([]Consumption{
+		{Start: at(8, 17, 45), Wh: 100}, // before the first price
+		{Start: at(8, 18, 0), Wh: 1000},
+		{Start: at(8, 18, 45), Wh: 500},
+		{Start: at(8, 19, 15), Wh: 2000},
+		{Start: at(8, 20, 0), Wh: 1000},
+		{Start: at(8, 21, 0), Wh: 300}, // the last price only covers an hour
+	}, prices)
+
+	require.Len(t, costs, 4)
+	require.Len(t, unpriced, 2)
+	assert.True(t, unpriced[0].Start.Equal(at(8, 17, 45)))
+	assert.True(t, unpriced[1].Start.Equal(at(8, 21, 0)))
+
+	c := costs[0]
+	assert.Equal(t, 20.0, c.SpotCkwh)
+	assert.InDelta(t, 0.20, c.EnergyEur, 1e-9)
+	// Fees get 25.5 % VAT from September 2024
+	assert.InDelta(t, 0.04*1.255, c.TransferEur, 1e-9)
+	assert.InDelta(t, 0.004*1.255, c.MarginEur, 1e-9)
+	assert.InDelta(t, 0.20+0.044*1.255, c.TotalEur(), 1e-9)
+
+	assert.Equal(t, 20.0, costs[1].SpotCkwh, "quarter-hour slots use the hourly price")
+	assert.Equal(t, 10.0, costs[2].SpotCkwh)
+	assert.InDelta(t, -0.01, costs[3].EnergyEur, 1e-9, "negative prices reduce the cost")
+}
+
+func TestSummarizeCosts(t *testing.T) {
+	helsinki, _ := time.LoadLocation("Europe/Helsinki")
+	at := func(month time.Month, day, hour int) time.Time {
+		return time.Date(2025, month, day, hour, 0, 0, 0, helsinki)
+	}
+	costs := []Cost{
+		{Start: at(10, 31, 23), KWh: 1, EnergyEur: 0.1, TransferEur: 0.05},
+		{Start: at(11, 1, 0), KWh: 2, EnergyEur: 0.2, TransferEur: 0.1},
+		{Start: at(11, 1, 22), KWh: 1, EnergyEur: 0.3, TransferEur: 0.05, MarginEur: 0.01},
+	}
+	unpriced := []Consumption{{Start: at(11, 2, 10), Wh: 1500}}
+
+	days, err := SummarizeCosts(costs, unpriced, CostPeriodDay, helsinki)
+	require.NoError(t, err)
+	require.Len(t, days, 3)
+	assert.Equal(t, "2025-10-31", days[0].Period)
+	assert.Equal(t, "2025-11-01", days[1].Period)
+	assert.Equal(t, 3.0, days[1].KWh)
+	assert.InDelta(t, 0.66, days[1].TotalEur, 1e-9)
+	assert.InDelta(t, 22.0, days[1].AvgCkwh, 1e-9)
+	assert.Equal(t, "2025-11-02", days[2].Period)
+	assert.Equal(t, 1.5, days[2].UnpricedKWh)
+	assert.Zero(t, days[2].TotalEur)
+
+	months, err := SummarizeCosts(costs, unpriced, CostPeriodMonth, helsinki)
+	require.NoError(t, err)
+	require.Len(t, months, 2)
+	assert.Equal(t, "2025-10", months[0].Period)
+	assert.Equal(t, 3.0, months[1].KWh)
+
+	_, err = SummarizeCosts(costs, nil, "week", helsinki)
+	assert.Error(t, err)
+}
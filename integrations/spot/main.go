@@ -13,7 +13,9 @@ const (
 	apiEndpoint = "https://web-api.tp.entsoe.eu/api"
 )
 
-func GetPrices(start, end time.Time, location *time.Location) (*SpotPriceList, error) {
+// NewSpotServiceFromEnv returns a SpotService for the ENTSO-E API using
+// SPOT_API_KEY from the environment.
+func NewSpotServiceFromEnv() (*SpotService, error) {
 	err := godotenv.Load()
 	if err != nil {
 		log.Printf("Error loading .env file: %v", err)
@@ -24,12 +26,19 @@ func GetPrices(start, end time.Time, location *time.Location) (*SpotPriceList, e
 		return nil, fmt.Errorf("SPOT_API_KEY not set in environment")
 	}
 
+	client := NewDefaultHTTPClient(apiKey)
+	return NewSpotService(client, apiEndpoint), nil
+}
+
+func GetPrices(start, end time.Time, location *time.Location) (*SpotPriceList, error) {
+	spotService, err := NewSpotServiceFromEnv()
+	if err != nil {
+		return nil, err
+	}
+
 	start = start.In(location)
 	end = end.In(location)
 
-	client := NewDefaultHTTPClient(apiKey)
-	spotService := NewSpotService(client, apiEndpoint)
-
 	prices, err := spotService.GetSpotPrices(start, end)
 	if err != nil {
 		return nil, err
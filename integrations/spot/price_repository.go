@@ -0,0 +1,150 @@
+package spot
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/mikahozz/gohome/db"
+	"github.com/rs/zerolog/log"
+)
+
+// PriceSensorID is the sensor_id spot prices are stored under in the measurements table.
+const PriceSensorID = "spot_price"
+
+// PriceSource fetches prices from the upstream API. *SpotService implements it.
+type PriceSource interface {
+	GetSpotPrices(periodStart, periodEnd time.Time) (*SpotPriceList, error)
+}
+
+// PriceRepository serves spot prices from the measurements table. Intervals
+// missing from the database are fetched from the source and stored first.
+type PriceRepository struct {
+	db     *sql.DB
+	source PriceSource
+	// Resolution is the expected spacing of prices, used to detect gaps.
+	Resolution time.Duration
+}
+
+func NewPriceRepository(db *sql.DB, source PriceSource) *PriceRepository {
+	return &PriceRepository{db: db, source: source, Resolution: time.Hour}
+}
+
+// priceGap is a closed range [Start, End] of slots with no stored price.
+type priceGap struct {
+	Start time.Time
+	End   time.Time
+}
+
+// GetPrices returns prices between start and end (both inclusive) in location.
+// Missing intervals are backfilled from the source; intervals the source has
+// no data for yet (e.g. tomorrow before the day-ahead auction) are left out.
+// Without a source only stored prices are returned.
+func (r *PriceRepository) GetPrices(ctx context.Context, start, end time.Time, location *time.Location) (*SpotPriceList, error) {
+	stored, err := r.load(ctx, start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	var gaps []priceGap
+	if r.source != nil {
+		gaps = missingIntervals(stored, start, end, r.Resolution)
+	}
+	fetchedAny := false
+	for _, gap := range gaps {
+		log.Info().Str("event", "price_backfill").Time("start", gap.Start).Time("end", gap.End).Msg("fetching missing spot prices")
+		// Request one slot past the gap so single-slot gaps are a non-empty period
+		fetched, err := r.source.GetSpotPrices(gap.Start.In(location), gap.End.Add(r.Resolution).In(location))
+		if err != nil {
+			var noData *NoDataError
+			if errors.As(err, &noData) {
+				log.Info().Str("event", "price_backfill_no_data").Time("start", gap.Start).Msg(noData.Message)
+				continue
+			}
+			return nil, fmt.Errorf("fetching prices %s - %s: %w", gap.Start, gap.End, err)
+		}
+		if err := r.Upsert(ctx, fetched.Prices); err != nil {
+			return nil, err
+		}
+		fetchedAny = fetchedAny || len(fetched.Prices) > 0
+	}
+
+	if fetchedAny {
+		if stored, err = r.load(ctx, start, end); err != nil {
+			return nil, err
+		}
+	}
+	for i := range stored {
+		stored[i].DateTime = stored[i].DateTime.In(location)
+	}
+	return &SpotPriceList{Prices: stored}, nil
+}
+
+// Upsert stores prices, replacing existing values for the same timestamps.
+func (r *PriceRepository) Upsert(ctx context.Context, prices []SpotPrice) error {
+	measurements := make([]db.Measurement, len(prices))
+	for i, p := range prices {
+		price := p.PriceCkwh
+		measurements[i] = db.Measurement{
+			Timestamp: p.DateTime,
+			SensorID:  PriceSensorID,
+			MainValue: &price,
+			Value:     map[string]float64{"price_ckwh": price},
+		}
+	}
+	return db.UpsertMeasurements(ctx, r.db, measurements)
+}
+
+func (r *PriceRepository) load(ctx context.Context, start, end time.Time) ([]SpotPrice, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT timestamp, main_value FROM measurements
+		WHERE sensor_id = $1 AND timestamp >= $2 AND timestamp <= $3
+		ORDER BY timestamp`, PriceSensorID, start.UTC(), end.UTC())
+	if err != nil {
+		return nil, fmt.Errorf("querying prices: %w", err)
+	}
+	defer rows.Close()
+
+	var prices []SpotPrice
+	for rows.Next() {
+		var p SpotPrice
+		if err := rows.Scan(&p.DateTime, &p.PriceCkwh); err != nil {
+			return nil, fmt.Errorf("scanning price: %w", err)
+		}
+		prices = append(prices, p)
+	}
+	return prices, rows.Err()
+}
+
+// missingIntervals returns the ranges between start and end (inclusive) that
+// have no price on the resolution grid. Adjacent missing slots are merged so
+// each gap costs a single upstream request.
+func missingIntervals(prices []SpotPrice, start, end time.Time, resolution time.Duration) []priceGap {
+	have := make(map[int64]bool, len(prices))
+	for _, p := range prices {
+		have[p.DateTime.Unix()] = true
+	}
+
+	first := start.Truncate(resolution)
+	if first.Before(start) {
+		first = first.Add(resolution)
+	}
+
+	var gaps []priceGap
+	var current *priceGap
+	for t := first; !t.After(end); t = t.Add(resolution) {
+		if have[t.Unix()] {
+			current = nil
+			continue
+		}
+		if current == nil {
+			gaps = append(gaps, priceGap{Start: t, End: t})
+			current = &gaps[len(gaps)-1]
+			continue
+		}
+		current.End = t
+	}
+	return gaps
+}
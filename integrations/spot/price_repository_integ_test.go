@@ -0,0 +1,117 @@
+//go:build integration
+
+package spot
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mikahozz/gohome/config"
+	"github.com/mikahozz/gohome/db"
+)
+
+type fakePriceSource struct {
+	calls  []priceGap
+	prices func(start, end time.Time) []SpotPrice
+}
+
+func (f *fakePriceSource) GetSpotPrices(start, end time.Time) (*SpotPriceList, error) {
+	f.calls = append(f.calls, priceGap{Start: start, End: end})
+	return &SpotPriceList{Prices: f.prices(start, end)}, nil
+}
+
+// hourlyPrices returns a price for every hour in [start, end], priced by the hour of day.
+func hourlyPrices(start, end time.Time) []SpotPrice {
+	var prices []SpotPrice
+	for t := start; !t.After(end); t = t.Add(time.Hour) {
+		prices = append(prices, SpotPrice{DateTime: t, PriceCkwh: float64(t.UTC().Hour()) / 10})
+	}
+	return prices
+}
+
+func TestPriceRepository_Backfill(t *testing.T) {
+	config.LoadEnv()
+	conn, err := db.Open()
+	if err != nil {
+		t.Skipf("database not available: %v", err)
+	}
+	defer conn.Close()
+
+	// Use a day far in the past so real data is not touched
+	start := time.Date(2001, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := start.Add(23 * time.Hour)
+	cleanup := func() {
+		conn.Exec(`DELETE FROM measurements WHERE sensor_id = $1 AND timestamp >= $2 AND timestamp <= $3`,
+			PriceSensorID, start, end.Add(time.Hour))
+	}
+	cleanup()
+	defer cleanup()
+
+	ctx := context.Background()
+	source := &fakePriceSource{prices: hourlyPrices}
+	repo := NewPriceRepository(conn, source)
+
+	// Pre-store a few hours in the middle of the day
+	if err := repo.Upsert(ctx, hourlyPrices(start.Add(10*time.Hour), start.Add(12*time.Hour))); err != nil {
+		t.Fatalf("Upsert failed: %v", err)
+	}
+
+	helsinki, _ := time.LoadLocation("Europe/Helsinki")
+	prices, err := repo.GetPrices(ctx, start, end, helsinki)
+	if err != nil {
+		t.Fatalf("GetPrices failed: %v", err)
+	}
+	if len(source.calls) != 2 {
+		t.Errorf("Expected 2 backfill requests (before and after stored hours), got %d", len(source.calls))
+	}
+	if len(prices.Prices) != 24 {
+		t.Fatalf("Expected 24 prices, got %d", len(prices.Prices))
+	}
+	for i, p := range prices.Prices {
+		want := start.Add(time.Duration(i) * time.Hour)
+		if !p.DateTime.Equal(want) || p.DateTime.Location() != helsinki {
+			t.Errorf("Price[%d] at %s, want %s in Helsinki time", i, p.DateTime, want)
+		}
+		if p.PriceCkwh != float64(want.Hour())/10 {
+			t.Errorf("Price[%d] = %v, want %v", i, p.PriceCkwh, float64(want.Hour())/10)
+		}
+	}
+
+	// Everything is stored now, so the source must not be called again
+	source.calls = nil
+	if _, err := repo.GetPrices(ctx, start, end, time.UTC); err != nil {
+		t.Fatalf("GetPrices failed: %v", err)
+	}
+	if len(source.calls) != 0 {
+		t.Errorf("Expected no backfill requests, got %+v", source.calls)
+	}
+}
+
+func TestPriceRepository_UpsertReplaces(t *testing.T) {
+	config.LoadEnv()
+	conn, err := db.Open()
+	if err != nil {
+		t.Skipf("database not available: %v", err)
+	}
+	defer conn.Close()
+
+	ts := time.Date(2001, 1, 2, 0, 0, 0, 0, time.UTC)
+	defer conn.Exec(`DELETE FROM measurements WHERE sensor_id = $1 AND timestamp = $2`, PriceSensorID, ts)
+
+	ctx := context.Background()
+	repo := NewPriceRepository(conn, &fakePriceSource{prices: hourlyPrices})
+	if err := repo.Upsert(ctx, []SpotPrice{{DateTime: ts, PriceCkwh: 1.5}}); err != nil {
+		t.Fatalf("Upsert failed: %v", err)
+	}
+	if err := repo.Upsert(ctx, []SpotPrice{{DateTime: ts, PriceCkwh: 2.5}}); err != nil {
+		t.Fatalf("Upsert failed: %v", err)
+	}
+	prices, err := repo.GetPrices(ctx, ts, ts, time.UTC)
+	if err != nil {
+		t.Fatalf("GetPrices failed: %v", err)
+	}
+	if len(prices.Prices) != 1 || prices.Prices[0].PriceCkwh != 2.5 {
+		t.Errorf("Expected single updated price 2.5, got %+v", prices.Prices)
+	}
+}
@@ -0,0 +1,55 @@
+package spot
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMissingIntervals(t *testing.T) {
+	start, _ := time.Parse(time.RFC3339, "2024-10-22T21:00:00Z")
+	end := start.Add(5 * time.Hour)
+	at := func(h int) SpotPrice { return SpotPrice{DateTime: start.Add(time.Duration(h) * time.Hour)} }
+
+	t.Run("empty database is one gap", func(t *testing.T) {
+		gaps := missingIntervals(nil, start, end, time.Hour)
+		if len(gaps) != 1 || !gaps[0].Start.Equal(start) || !gaps[0].End.Equal(end) {
+			t.Errorf("Expected single gap %s - %s, got %+v", start, end, gaps)
+		}
+	})
+
+	t.Run("complete range has no gaps", func(t *testing.T) {
+		prices := []SpotPrice{at(0), at(1), at(2), at(3), at(4), at(5)}
+		if gaps := missingIntervals(prices, start, end, time.Hour); len(gaps) != 0 {
+			t.Errorf("Expected no gaps, got %+v", gaps)
+		}
+	})
+
+	t.Run("adjacent missing slots are merged", func(t *testing.T) {
+		prices := []SpotPrice{at(0), at(3), at(5)}
+		gaps := missingIntervals(prices, start, end, time.Hour)
+		if len(gaps) != 2 {
+			t.Fatalf("Expected 2 gaps, got %+v", gaps)
+		}
+		if !gaps[0].Start.Equal(at(1).DateTime) || !gaps[0].End.Equal(at(2).DateTime) {
+			t.Errorf("Unexpected first gap %+v", gaps[0])
+		}
+		if !gaps[1].Start.Equal(at(4).DateTime) || !gaps[1].End.Equal(at(4).DateTime) {
+			t.Errorf("Unexpected second gap %+v", gaps[1])
+		}
+	})
+
+	t.Run("start between slots rounds up", func(t *testing.T) {
+		gaps := missingIntervals([]SpotPrice{at(2)}, start.Add(30*time.Minute), at(2).DateTime, time.Hour)
+		if len(gaps) != 1 || !gaps[0].Start.Equal(at(1).DateTime) || !gaps[0].End.Equal(at(1).DateTime) {
+			t.Errorf("Expected gap at %s only, got %+v", at(1).DateTime, gaps)
+		}
+	})
+
+	t.Run("prices in other timezones match", func(t *testing.T) {
+		helsinki, _ := time.LoadLocation("Europe/Helsinki")
+		prices := []SpotPrice{{DateTime: start.In(helsinki)}}
+		if gaps := missingIntervals(prices, start, start, time.Hour); len(gaps) != 0 {
+			t.Errorf("Expected no gaps, got %+v", gaps)
+		}
+	})
+}
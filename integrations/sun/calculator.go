@@ -0,0 +1,226 @@
+package sun
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Coordinates of Helsinki city centre, the location of the embedded sun table.
+const (
+	HelsinkiLatitude  = 60.1699
+	HelsinkiLongitude = 24.9384
+)
+
+// ConfiguredCoordinates returns the location from SUN_LATITUDE and
+// SUN_LONGITUDE (decimal degrees), Helsinki when unset.
+func ConfiguredCoordinates() (latitude, longitude float64, err error) {
+	latitude, longitude = HelsinkiLatitude, HelsinkiLongitude
+	if s := os.Getenv("SUN_LATITUDE"); s != "" {
+		if latitude, err = strconv.ParseFloat(s, 64); err != nil || latitude < -90 || latitude > 90 {
+			return 0, 0, fmt.Errorf("invalid SUN_LATITUDE %q", s)
+		}
+	}
+	if s := os.Getenv("SUN_LONGITUDE"); s != "" {
+		if longitude, err = strconv.ParseFloat(s, 64); err != nil || longitude < -180 || longitude > 180 {
+			return 0, 0, fmt.Errorf("invalid SUN_LONGITUDE %q", s)
+		}
+	}
+	return latitude, longitude, nil
+}
+
+// Sun altitudes (degrees) defining the events of a day. Sunrise and sunset
+// account for atmospheric refraction and the radius of the solar disc.
+const (
+	altitudeSunrise      = -0.833
+	altitudeCivil        = -6.0
+	altitudeNautical     = -12.0
+	altitudeAstronomical = -18.0
+	altitudeGoldenHour   = 6.0
+)
+
+// Provider returns sun data for dates. Both the embedded table (SunData) and
+// the astronomical Calculator implement it.
+type Provider interface {
+	GetSunDataForDateRange(startDate time.Time, endDate time.Time) []DailySunData
+	GetSunDataForSingleDate(date time.Time) *DailySunData
+}
+
+var (
+	_ Provider = (*SunData)(nil)
+	_ Provider = (*Calculator)(nil)
+)
+
+// Calculator computes sun events astronomically for any coordinates using the
+// NOAA solar position equations. Accuracy is within a minute or two for
+// latitudes below the polar circles; closer to the poles events become very
+// sensitive to refraction.
+type Calculator struct {
+	Latitude  float64
+	Longitude float64
+	Location  *time.Location
+}
+
+// NewCalculator returns a calculator for the given coordinates (decimal degrees,
+// east and north positive). Times are returned in loc.
+func NewCalculator(latitude, longitude float64, loc *time.Location) *Calculator {
+	if loc == nil {
+		loc = time.UTC
+	}
+	return &Calculator{Latitude: latitude, Longitude: longitude, Location: loc}
+}
+
+// GetSunDataForDateRange returns sun data for each day from startDate to endDate.
+// If endDate is zero value or equal to startDate, returns only startDate.
+func (c *Calculator) GetSunDataForDateRange(startDate time.Time, endDate time.Time) []DailySunData {
+	if endDate.IsZero() || endDate.Equal(startDate) {
+		endDate = startDate
+	}
+	var results []DailySunData
+	for d := startDate; !d.After(endDate); d = d.AddDate(0, 0, 1) {
+		results = append(results, c.calculate(d.Year(), d.Month(), d.Day()))
+	}
+	return results
+}
+
+func (c *Calculator) GetSunDataForSingleDate(date time.Time) *DailySunData {
+	data := c.calculate(date.Year(), date.Month(), date.Day())
+	return &data
+}
+
+// calculate computes the sun events of a calendar day. The date is taken as
+// given, like the table lookup, so callers decide which timezone's day they mean.
+func (c *Calculator) calculate(year int, month time.Month, day int) DailySunData {
+	noon := c.solarNoon(year, month, day)
+	_, offset := noon.In(c.Location).Zone()
+	data := DailySunData{
+		Date:      fmt.Sprintf("%04d-%02d-%02d", year, month, day),
+		SolarNoon: noon.In(c.Location).Truncate(time.Second),
+		Timezone:  c.Location.String(),
+		UTCOffset: offset / 60,
+	}
+
+	var ok bool
+	var state polarState
+	data.Sunrise, state, ok = c.eventTime(year, month, day, altitudeSunrise, true)
+	if ok {
+		data.Sunset, _, _ = c.eventTime(year, month, day, altitudeSunrise, false)
+		data.DayLength = formatDayLength(data.Sunset.Sub(data.Sunrise))
+	} else if state == alwaysAbove {
+		data.PolarDay = true
+		data.DayLength = formatDayLength(24 * time.Hour)
+	} else {
+		data.PolarNight = true
+		data.DayLength = formatDayLength(0)
+	}
+
+	data.Dawn, _, _ = c.eventTime(year, month, day, altitudeCivil, true)
+	data.Dusk, _, _ = c.eventTime(year, month, day, altitudeCivil, false)
+	data.NauticalDawn, _, _ = c.eventTime(year, month, day, altitudeNautical, true)
+	data.NauticalDusk, _, _ = c.eventTime(year, month, day, altitudeNautical, false)
+	data.AstronomicalDawn, _, _ = c.eventTime(year, month, day, altitudeAstronomical, true)
+	data.AstronomicalDusk, _, _ = c.eventTime(year, month, day, altitudeAstronomical, false)
+
+	// Evening golden hour starts when the sun descends below 6°. When it never
+	// climbs that high the whole day is golden hour, reported from solar noon.
+	golden, state, ok := c.eventTime(year, month, day, altitudeGoldenHour, false)
+	switch {
+	case ok:
+		data.GoldenHour = golden
+	case state == alwaysBelow && !data.PolarNight:
+		data.GoldenHour = data.SolarNoon
+	}
+	return data
+}
+
+type polarState int
+
+const (
+	crosses polarState = iota
+	alwaysAbove
+	alwaysBelow
+)
+
+// solarNoon returns the instant the sun transits the meridian.
+func (c *Calculator) solarNoon(year int, month time.Month, day int) time.Time {
+	midnight := time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+	// First estimate from longitude only, then refine with the equation of time.
+	t := midnight.Add(time.Duration((720 - 4*c.Longitude) * float64(time.Minute)))
+	for i := 0; i < 2; i++ {
+		_, eqTime := solarParameters(t)
+		t = midnight.Add(time.Duration((720 - 4*c.Longitude - eqTime) * float64(time.Minute)))
+	}
+	return t
+}
+
+// eventTime returns when the sun crosses altitude (degrees) while rising or
+// setting. ok is false when the sun stays above or below it all day.
+func (c *Calculator) eventTime(year int, month time.Month, day int, altitude float64, rising bool) (time.Time, polarState, bool) {
+	midnight := time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+	t := c.solarNoon(year, month, day)
+	latRad := degToRad(c.Latitude)
+	// Iterate so the sun's declination is evaluated at the event itself.
+	for i := 0; i < 3; i++ {
+		decl, eqTime := solarParameters(t)
+		cosH := (math.Sin(degToRad(altitude)) - math.Sin(latRad)*math.Sin(decl)) /
+			(math.Cos(latRad) * math.Cos(decl))
+		if cosH > 1 {
+			return time.Time{}, alwaysBelow, false
+		}
+		if cosH < -1 {
+			return time.Time{}, alwaysAbove, false
+		}
+		hourAngle := radToDeg(math.Acos(cosH))
+		if rising {
+			hourAngle = -hourAngle
+		}
+		minutes := 720 - 4*(c.Longitude-hourAngle) - eqTime
+		t = midnight.Add(time.Duration(minutes * float64(time.Minute)))
+	}
+	return t.In(c.Location).Truncate(time.Second), crosses, true
+}
+
+// solarParameters returns the sun's declination (radians) and the equation of
+// time (minutes) at instant t.
+func solarParameters(t time.Time) (float64, float64) {
+	jd := float64(t.UTC().UnixNano())/float64(24*time.Hour) + 2440587.5
+	T := (jd - 2451545.0) / 36525.0
+
+	l0 := math.Mod(280.46646+T*(36000.76983+T*0.0003032), 360)
+	m := 357.52911 + T*(35999.05029-0.0001537*T)
+	e := 0.016708634 - T*(0.000042037+0.0000001267*T)
+	mRad := degToRad(m)
+	center := math.Sin(mRad)*(1.914602-T*(0.004817+0.000014*T)) +
+		math.Sin(2*mRad)*(0.019993-0.000101*T) +
+		math.Sin(3*mRad)*0.000289
+	trueLong := l0 + center
+	omega := degToRad(125.04 - 1934.136*T)
+	apparentLong := trueLong - 0.00569 - 0.00478*math.Sin(omega)
+
+	meanObliquity := 23 + (26+(21.448-T*(46.815+T*(0.00059-T*0.001813)))/60)/60
+	obliquity := degToRad(meanObliquity + 0.00256*math.Cos(omega))
+	decl := math.Asin(math.Sin(obliquity) * math.Sin(degToRad(apparentLong)))
+
+	y := math.Pow(math.Tan(obliquity/2), 2)
+	l0Rad := degToRad(l0)
+	eqTime := y*math.Sin(2*l0Rad) -
+		2*e*math.Sin(mRad) +
+		4*e*y*math.Sin(mRad)*math.Cos(2*l0Rad) -
+		0.5*y*y*math.Sin(4*l0Rad) -
+		1.25*e*e*math.Sin(2*mRad)
+	return decl, 4 * radToDeg(eqTime)
+}
+
+// formatDayLength formats a duration like the table's day_length ("5:59:08").
+func formatDayLength(d time.Duration) string {
+	d = d.Round(time.Second)
+	h := int(d.Hours())
+	m := int(d.Minutes()) % 60
+	s := int(d.Seconds()) % 60
+	return fmt.Sprintf("%d:%02d:%02d", h, m, s)
+}
+
+func degToRad(d float64) float64 { return d * math.Pi / 180 }
+func radToDeg(r float64) float64 { return r * 180 / math.Pi }
@@ -0,0 +1,152 @@
+package sun
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func minutesApart(a, b time.Time) float64 {
+	return math.Abs(a.Sub(b).Minutes())
+}
+
+// TestCalculatorMatchesEmbeddedTable cross-checks the calculator against every
+// day of the embedded Helsinki table.
+func TestCalculatorMatchesEmbeddedTable(t *testing.T) {
+	table, err := NewSunData()
+	if err != nil {
+		t.Fatalf("NewSunData failed: %v", err)
+	}
+	helsinki, _ := time.LoadLocation("Europe/Helsinki")
+	calc := NewCalculator(HelsinkiLatitude, HelsinkiLongitude, helsinki)
+
+	const tolerance = 5.0 // minutes
+	for d := time.Date(2025, 1, 1, 0, 0, 0, 0, helsinki); d.Year() == 2025; d = d.AddDate(0, 0, 1) {
+		want := table.GetSunDataForSingleDate(d)
+		got := calc.GetSunDataForSingleDate(d)
+		if got.Date != want.Date {
+			t.Fatalf("date mismatch: got %s want %s", got.Date, want.Date)
+		}
+		checks := []struct {
+			name      string
+			want, got time.Time
+		}{
+			{"sunrise", want.Sunrise, got.Sunrise},
+			{"sunset", want.Sunset, got.Sunset},
+			{"solar noon", want.SolarNoon, got.SolarNoon},
+			{"golden hour", want.GoldenHour, got.GoldenHour},
+			{"dawn", want.Dawn, got.Dawn},
+			{"dusk", want.Dusk, got.Dusk},
+		}
+		for _, c := range checks {
+			// Around midsummer civil twilight lasts all night and the table wraps
+			// dusk past midnight; those days are not comparable.
+			if c.got.IsZero() || c.want.Sub(c.got).Abs() > 12*time.Hour {
+				continue
+			}
+			if diff := minutesApart(c.want, c.got); diff > tolerance {
+				t.Errorf("%s %s: table %s, calculated %s (%.1f min apart)",
+					want.Date, c.name, c.want.Format("15:04:05"), c.got.Format("15:04:05"), diff)
+			}
+		}
+	}
+}
+
+func TestCalculatorPolarDayAndNight(t *testing.T) {
+	helsinki, _ := time.LoadLocation("Europe/Helsinki")
+	utsjoki := NewCalculator(69.9078, 27.0265, helsinki)
+
+	summer := utsjoki.GetSunDataForSingleDate(time.Date(2025, 6, 21, 0, 0, 0, 0, helsinki))
+	if !summer.PolarDay || summer.PolarNight {
+		t.Fatalf("expected polar day at midsummer, got %+v", summer)
+	}
+	if !summer.Sunrise.IsZero() || !summer.Sunset.IsZero() {
+		t.Errorf("polar day must not have sunrise/sunset: %v %v", summer.Sunrise, summer.Sunset)
+	}
+	if summer.DayLength != "24:00:00" {
+		t.Errorf("polar day length, got %s", summer.DayLength)
+	}
+
+	winter := utsjoki.GetSunDataForSingleDate(time.Date(2025, 12, 21, 0, 0, 0, 0, helsinki))
+	if !winter.PolarNight || winter.PolarDay {
+		t.Fatalf("expected polar night at midwinter, got %+v", winter)
+	}
+	if !winter.Sunrise.IsZero() || !winter.GoldenHour.IsZero() {
+		t.Errorf("polar night must not have sunrise or golden hour")
+	}
+	// Civil twilight still happens around noon during polar night
+	if winter.Dawn.IsZero() || winter.Dusk.IsZero() || !winter.Dawn.Before(winter.SolarNoon) || !winter.Dusk.After(winter.SolarNoon) {
+		t.Errorf("expected civil twilight around solar noon, dawn %v noon %v dusk %v", winter.Dawn, winter.SolarNoon, winter.Dusk)
+	}
+	if winter.DayLength != "0:00:00" {
+		t.Errorf("polar night length, got %s", winter.DayLength)
+	}
+}
+
+func TestCalculatorOtherLocations(t *testing.T) {
+	sydneyLoc, err := time.LoadLocation("Australia/Sydney")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sydney := NewCalculator(-33.8688, 151.2093, sydneyLoc)
+	data := sydney.GetSunDataForSingleDate(time.Date(2025, 1, 1, 0, 0, 0, 0, sydneyLoc))
+
+	// Reference values from NOAA's solar calculator
+	wantRise := time.Date(2025, 1, 1, 5, 47, 0, 0, sydneyLoc)
+	wantSet := time.Date(2025, 1, 1, 20, 9, 0, 0, sydneyLoc)
+	if minutesApart(data.Sunrise, wantRise) > 2 {
+		t.Errorf("Sydney sunrise, got %s want ~%s", data.Sunrise.Format("15:04"), wantRise.Format("15:04"))
+	}
+	if minutesApart(data.Sunset, wantSet) > 2 {
+		t.Errorf("Sydney sunset, got %s want ~%s", data.Sunset.Format("15:04"), wantSet.Format("15:04"))
+	}
+	if data.UTCOffset != 660 || data.Timezone != "Australia/Sydney" {
+		t.Errorf("unexpected zone info %s %d", data.Timezone, data.UTCOffset)
+	}
+
+	ordered := []time.Time{
+		data.AstronomicalDawn, data.NauticalDawn, data.Dawn, data.Sunrise,
+		data.SolarNoon, data.GoldenHour, data.Sunset, data.Dusk, data.NauticalDusk, data.AstronomicalDusk,
+	}
+	for i := 1; i < len(ordered); i++ {
+		if !ordered[i].After(ordered[i-1]) {
+			t.Errorf("events out of order at %d: %v not after %v", i, ordered[i], ordered[i-1])
+		}
+	}
+}
+
+func TestCalculatorDateRange(t *testing.T) {
+	calc := NewCalculator(HelsinkiLatitude, HelsinkiLongitude, nil)
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	results := calc.GetSunDataForDateRange(start, start.AddDate(0, 0, 2))
+	if len(results) != 3 {
+		t.Fatalf("Expected 3 results, got %d", len(results))
+	}
+	if results[2].Date != "2026-01-03" || results[0].Timezone != "UTC" {
+		t.Errorf("unexpected range results: %s %s", results[2].Date, results[0].Timezone)
+	}
+	if single := calc.GetSunDataForDateRange(start, time.Time{}); len(single) != 1 {
+		t.Errorf("Expected 1 result for single day query, got %d", len(single))
+	}
+}
+
+func TestConfiguredCoordinates(t *testing.T) {
+	t.Setenv("SUN_LATITUDE", "")
+	t.Setenv("SUN_LONGITUDE", "")
+	lat, lon, err := ConfiguredCoordinates()
+	if err != nil || lat != HelsinkiLatitude || lon != HelsinkiLongitude {
+		t.Fatalf("default = %v, %v, %v; want Helsinki", lat, lon, err)
+	}
+
+	t.Setenv("SUN_LATITUDE", "69.9078")
+	t.Setenv("SUN_LONGITUDE", "27.0265")
+	lat, lon, err = ConfiguredCoordinates()
+	if err != nil || lat != 69.9078 || lon != 27.0265 {
+		t.Fatalf("configured = %v, %v, %v; want 69.9078, 27.0265", lat, lon, err)
+	}
+
+	t.Setenv("SUN_LATITUDE", "91")
+	if _, _, err := ConfiguredCoordinates(); err == nil {
+		t.Fatal("expected an error for latitude 91")
+	}
+}
@@ -46,6 +46,14 @@ type DailySunData struct {
 	DayLength  string    `json:"day_length"` // Keep as string, it's a duration like "10:23:53"
 	Timezone   string    `json:"timezone"`
 	UTCOffset  int       `json:"utc_offset"`
+
+	// Only filled in by Calculator; the embedded table has civil dawn/dusk only.
+	NauticalDawn     time.Time `json:"nautical_dawn"`
+	NauticalDusk     time.Time `json:"nautical_dusk"`
+	AstronomicalDawn time.Time `json:"astronomical_dawn"`
+	AstronomicalDusk time.Time `json:"astronomical_dusk"`
+	PolarDay         bool      `json:"polar_day,omitempty"`   // sun never sets; Sunrise and Sunset are zero
+	PolarNight       bool      `json:"polar_night,omitempty"` // sun never rises; Sunrise and Sunset are zero
 }
 
 //go:embed sun_helsinki.json